@@ -0,0 +1,103 @@
+package main
+
+import (
+	"Portfolio/internal/data"
+	"Portfolio/internal/importer"
+	"Portfolio/internal/uploads"
+	"database/sql"
+	"flag"
+	"fmt"
+	_ "github.com/lib/pq"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+func main() {
+
+	// setting the configuration variables
+	var (
+		dsn     string
+		source  string
+		path    string
+		author  string
+		dryRun  bool
+		storage uploads.StorageConfig
+	)
+
+	flag.StringVar(&dsn, "dsn", "", "PostgreSQL Database DSN")
+	flag.StringVar(&source, "source", "", fmt.Sprintf("Export format (%s)", strings.Join(importer.Sources, "|")))
+	flag.StringVar(&path, "path", "", "WXR file for WordPress, site directory for Hugo and Jekyll")
+	flag.StringVar(&author, "author", "", "Slug of the author of the posts (the default author if empty)")
+	flag.BoolVar(&dryRun, "dry-run", false, "Report what would be created and skipped without writing anything")
+	storage.Flags(flag.CommandLine, "")
+
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	// checking the arguments
+	if dsn == "" || source == "" || path == "" {
+		logger.Error("dsn, source and path are required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// connecting to the database
+	db, err := openDB(dsn)
+	if err != nil {
+		logger.Error(fmt.Errorf("openDB error: %w", err).Error())
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	models := data.NewModels(db)
+
+	// initializing the uploads storage to copy the images
+	store, err := storage.Open()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	err = uploads.Init(models.UploadModel, store)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// getting the author the posts are attributed to
+	postAuthor, err := models.AuthorModel.Get()
+	if author != "" {
+		postAuthor, err = models.AuthorModel.GetBySlug(author)
+	}
+	if err != nil {
+		logger.Error(fmt.Errorf("error getting the author %q: %w", author, err).Error())
+		os.Exit(1)
+	}
+
+	// importing the posts
+	report, err := importer.New(models.PostModel, postAuthor.ID, dryRun).Run(source, path)
+	if report != nil {
+		report.WriteTo(os.Stdout)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"Portfolio/internal/uploads"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+func main() {
+
+	// setting the configuration variables
+	var from, to uploads.StorageConfig
+
+	from.Flags(flag.CommandLine, "from-")
+	to.Flags(flag.CommandLine, "to-")
+
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	// opening both backends
+	src, err := from.Open()
+	if err != nil {
+		logger.Error(fmt.Errorf("source storage: %w", err).Error())
+		os.Exit(1)
+	}
+	dst, err := to.Open()
+	if err != nil {
+		logger.Error(fmt.Errorf("destination storage: %w", err).Error())
+		os.Exit(1)
+	}
+
+	// copying the files
+	copied, skipped, err := uploads.Migrate(src, dst)
+	fmt.Printf("%d files copied, %d already present\n", copied, skipped)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
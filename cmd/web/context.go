@@ -3,6 +3,7 @@ package main
 type contextKey string
 
 const (
-	isAuthenticatedContextKey = contextKey("isAuthenticated")
-	nonceContextKey           = contextKey("nonce")
+	isAuthenticatedContextKey   = contextKey("isAuthenticated")
+	authenticatedUserContextKey = contextKey("authenticatedUser")
+	nonceContextKey             = contextKey("nonce")
 )
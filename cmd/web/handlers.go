@@ -1,15 +1,26 @@
 package main
 
 import (
+	"Portfolio/internal/cv"
 	"Portfolio/internal/data"
+	"Portfolio/internal/qrcode"
+	"Portfolio/internal/resume"
+	"Portfolio/internal/totp"
 	"Portfolio/internal/uploads"
 	"Portfolio/internal/validator"
+	"Portfolio/internal/webauthn"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/alexedwards/flow"
+	"mime"
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -51,6 +62,21 @@ func (app *application) index(w http.ResponseWriter, r *http.Request) {
 	// setting the contact form
 	tmplData.Form = newContactForm()
 
+	// getting the featured projects
+	var err error
+	tmplData.Projects.List, err = app.models.ProjectModel.GetFeatured(3)
+	if err != nil {
+		app.logger.Error(fmt.Errorf("error getting featured projects: %w", err).Error())
+	}
+
+	// getting the approved testimonials
+	if tmplData.Author != nil {
+		tmplData.Testimonials, err = app.models.TestimonialModel.GetApproved(tmplData.Author.ID)
+		if err != nil {
+			app.logger.Error(fmt.Errorf("error getting testimonials: %w", err).Error())
+		}
+	}
+
 	// rendering the template
 	app.render(w, r, http.StatusOK, "home.tmpl", tmplData)
 }
@@ -152,6 +178,170 @@ func (app *application) postGet(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, http.StatusOK, "post.tmpl", tmplData)
 }
 
+func (app *application) projects(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Projects"
+
+	// retrieving the technology filter
+	tmplData.Projects.Tech = r.URL.Query().Get("tech")
+
+	// getting the projects and their technologies
+	var err error
+	tmplData.Projects.List, err = app.models.ProjectModel.Get(tmplData.Projects.Tech)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	tmplData.Projects.Techs, err = app.models.ProjectModel.GetTechs()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "projects.tmpl", tmplData)
+}
+
+func (app *application) projectGet(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the project ID
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+
+	// fetching the project
+	tmplData.Project, err = app.models.ProjectModel.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	tmplData.Title = fmt.Sprintf("Antoine de Barbarin - %s", tmplData.Project.Title)
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "project.tmpl", tmplData)
+}
+
+func (app *application) authorGet(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the author
+	author, err := app.models.AuthorModel.GetBySlug(flow.Param(r.Context(), "slug"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// rendering the profile of the author as the home page
+	app.render(w, r, http.StatusOK, "home.tmpl", app.profileData(r, author))
+}
+
+func (app *application) authorCV(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the author, the default one without slug
+	var (
+		author *data.Author
+		err    error
+	)
+	if slug := flow.Param(r.Context(), "slug"); slug != "" {
+		author, err = app.models.AuthorModel.GetBySlug(slug)
+	} else {
+		author, err = app.models.AuthorModel.Get()
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// sending the CV generated from the profile
+	err = cv.Write(w, r, author)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) resumeJSON(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the author
+	author, projects := app.exportedAuthor(w, r)
+	if author == nil {
+		return
+	}
+
+	// exporting the profile in the JSON Resume schema
+	jsonData, err := json.MarshalIndent(resume.New(author, projects, app.siteURL()), "", "  ")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	_, err = w.Write(jsonData)
+	if err != nil {
+		app.logger.Error(err.Error())
+	}
+}
+
+func (app *application) contactVCard(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the author
+	author, _ := app.exportedAuthor(w, r)
+	if author == nil {
+		return
+	}
+
+	// sending the contact card as a download
+	w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": author.Slug + ".vcf"}))
+	_, err := w.Write(resume.VCard(author, app.siteURL()))
+	if err != nil {
+		app.logger.Error(err.Error())
+	}
+}
+
+func (app *application) contactQRCode(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the author
+	author, _ := app.exportedAuthor(w, r)
+	if author == nil {
+		return
+	}
+
+	// encoding the contact card as a QR code
+	code, err := qrcode.Encode(resume.VCard(author, app.siteURL()))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	err = code.WritePNG(w, 6)
+	if err != nil {
+		app.logger.Error(err.Error())
+	}
+}
+
 func (app *application) contact(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving the form data
@@ -165,6 +355,23 @@ func (app *application) contact(w http.ResponseWriter, r *http.Request) {
 	// DEBUG
 	app.logger.Debug(fmt.Sprintf("form: %+v", form))
 
+	// retrieving the author the message is for (the default one without slug)
+	var author *data.Author
+	if form.Author != "" {
+		author, err = app.models.AuthorModel.GetBySlug(form.Author)
+	} else {
+		author, err = app.models.AuthorModel.Get()
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusBadRequest)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
 	// checking the form data
 	form.StringCheck(form.Name, 2, 70, true, "name")
 	form.ValidateEmail(form.Email)
@@ -172,14 +379,13 @@ func (app *application) contact(w http.ResponseWriter, r *http.Request) {
 
 	// redirect if the data is invalid
 	if !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "home.tmpl")
-		return
-	}
-
-	// retrieving the author email address
-	author, err := app.models.AuthorModel.Get()
-	if err != nil {
-		app.serverError(w, r, err)
+		if form.Author == "" {
+			app.failedValidationError(w, r, form, &form.Validator, "home.tmpl")
+			return
+		}
+		tmplData := app.profileData(r, author)
+		tmplData.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "home.tmpl", tmplData)
 		return
 	}
 
@@ -192,331 +398,505 @@ func (app *application) contact(w http.ResponseWriter, r *http.Request) {
 		}
 	})
 
-	// notifying the user with a flash message and redirecting to the home page
+	// notifying the user with a flash message and redirecting to the page of the author
 	app.sessionManager.Put(r.Context(), "flash", "Your message has been sent successfully!")
+	if form.Author != "" {
+		http.Redirect(w, r, author.URL(), http.StatusSeeOther)
+		return
+	}
 	http.Redirect(w, r, "/home", http.StatusSeeOther)
 }
 
-/* #############################################################################
-/*	USER ACCESS
-/* #############################################################################*/
+// testimonialTokenTTL is the time the submitters of the testimonials have to verify their email
+const testimonialTokenTTL = 48 * time.Hour
 
-func (app *application) register(w http.ResponseWriter, r *http.Request) {
+// publicAuthor returns the author of the slug (the default one without slug), the errors being sent
+func (app *application) publicAuthor(w http.ResponseWriter, r *http.Request, slug string) *data.Author {
+
+	var (
+		author *data.Author
+		err    error
+	)
+	if slug != "" {
+		author, err = app.models.AuthorModel.GetBySlug(slug)
+	} else {
+		author, err = app.models.AuthorModel.Get()
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil
+	}
+
+	return author
+}
+
+func (app *application) testimonial(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the author to recommend
+	slug := flow.Param(r.Context(), "slug")
+	author := app.publicAuthor(w, r, slug)
+	if author == nil {
+		return
+	}
 
 	// retrieving basic template data
 	tmplData := app.newTemplateData(r)
-	tmplData.Title = "Antoine de Barbarin - Register"
+	tmplData.Title = author.Name + " - Write a testimonial"
+	tmplData.Author = author
+	tmplData.IsProfileView = slug != ""
 
 	// filling the form with empty values
-	tmplData.Form = newUserRegisterForm()
+	form := newTestimonialForm()
+	form.Author = slug
+	tmplData.Form = form
 
 	// rendering the template
-	app.render(w, r, http.StatusOK, "register.tmpl", tmplData)
+	app.render(w, r, http.StatusOK, "testimonial.tmpl", tmplData)
 }
 
-func (app *application) registerPost(w http.ResponseWriter, r *http.Request) {
+func (app *application) testimonialPost(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving the form data
-	form := newUserRegisterForm()
-	err := app.decodePostForm(r, &form)
+	form := newTestimonialForm()
+	err := app.decodePostForm(r, form)
 	if err != nil {
 		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	// DEBUG
-	app.logger.Debug(fmt.Sprintf("form: %+v", form))
-
-	// checking the data from the user
-	form.StringCheck(form.Username, 2, 70, true, "username")
-	form.ValidateEmail(form.Email)
-	form.ValidateRegisterPassword(form.Password, form.ConfirmPassword)
+	// retrieving the author the testimonial is for (the default one without slug)
+	author := app.publicAuthor(w, r, form.Author)
+	if author == nil {
+		return
+	}
 
-	// return to register page if there is an error
+	// checking the form data
+	testimonial := &data.Testimonial{
+		AuthorID:     author.ID,
+		Name:         form.Name,
+		Email:        form.Email,
+		Role:         form.Role,
+		Company:      form.Company,
+		Relationship: form.Relationship,
+		Message:      form.Message,
+	}
+	testimonial.Validate(&form.Validator)
+
+	// return to the testimonial page if the data is invalid
 	if !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "register.tmpl")
+		tmplData := app.newTemplateData(r)
+		tmplData.Title = author.Name + " - Write a testimonial"
+		tmplData.Author = author
+		tmplData.IsProfileView = form.Author != ""
+		tmplData.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "testimonial.tmpl", tmplData)
 		return
 	}
 
-	// creating the user
-	user := &data.User{
-		Name:   form.Username,
-		Email:  form.Email,
-		Avatar: "/static/img/avatar.png", // TODO -> implement avatar in all user handlers
-		Status: data.UserToActivate,
+	// recording the testimonial until its submitter verifies their email
+	err = app.models.TestimonialModel.Insert(testimonial)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
-
-	// setting the password hash
-	err = user.Password.Set(form.Password)
+	token, err := app.models.TokenModel.NewForTestimonial(testimonial.ID, testimonialTokenTTL, data.TokenTestimonial)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	// verifying the user data
-	if data.ValidateUser(&form.Validator, user); !form.Valid() {
+	// sending the verification mail
+	app.background(func() {
 
-		// redirect to login page with errors
-		app.failedValidationError(w, r, form, &form.Validator, "register.tmpl")
+		mailData := map[string]any{
+			"name":              testimonial.Name,
+			"authorName":        author.Name,
+			"verificationToken": token.Plaintext,
+		}
+
+		err = app.mailer.Send(testimonial.Email, "testimonial_verification.tmpl", mailData)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	// notifying the user with a flash message and redirecting to the page of the author
+	app.sessionManager.Put(r.Context(), "flash", "Thank you! Please confirm your testimonial with the link we've sent you by mail.")
+	if form.Author != "" {
+		http.Redirect(w, r, author.URL(), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/home", http.StatusSeeOther)
+}
+
+func (app *application) verifyTestimonial(w http.ResponseWriter, r *http.Request) {
+
+	// checking the verification token
+	token := flow.Param(r.Context(), "token")
+	v := validator.New()
+	if v.ValidateToken(token); !v.Valid() {
+		app.clientError(w, r, http.StatusNotFound)
 		return
 	}
 
-	// inserting the user in the DB
-	err = app.models.UserModel.Insert(user)
+	// fetching the testimonial of the token
+	testimonial, err := app.models.TestimonialModel.GetForToken(data.TokenTestimonial, token)
 	if err != nil {
 		switch {
-		case errors.Is(err, data.ErrDuplicateEmail):
-			form.AddFieldError("email", "a user with this email address already exists")
-			app.failedValidationError(w, r, form, &form.Validator, "register.tmpl")
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.sessionManager.Put(r.Context(), "flash", "This link is invalid or has expired, please submit your testimonial again.")
+			http.Redirect(w, r, "/home", http.StatusSeeOther)
 		default:
 			app.serverError(w, r, err)
 		}
 		return
 	}
 
-	// Generating an activation token to send it via mail to the user
-	token, err := app.models.TokenModel.New(user.ID, 3*24*time.Hour, data.TokenActivation)
+	// submitting the testimonial to the approval of the author (the link being used only once)
+	err = app.models.TestimonialModel.Verify(testimonial.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.sessionManager.Put(r.Context(), "flash", "Your testimonial has already been confirmed!")
+			http.Redirect(w, r, "/home", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	author, err := app.models.AuthorModel.GetByID(testimonial.AuthorID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	// notifying the author
 	app.background(func() {
 
-		mailData := map[string]any{
-			"userID":          user.ID,
-			"username":        user.Name,
-			"activationToken": token.Plaintext,
-		}
-
-		err = app.mailer.Send(user.Email, "user_welcome.tmpl", mailData)
+		err = app.mailer.Send(author.Email, "testimonial_pending.tmpl", testimonial)
 		if err != nil {
 			app.logger.Error(err.Error())
 		}
 	})
 
-	app.sessionManager.Put(r.Context(), "flash", "We've sent you a confirmation email!")
-
-	http.Redirect(w, r, "/login", http.StatusSeeOther)
+	app.sessionManager.Put(r.Context(), "flash", "Your email is confirmed! Your testimonial will appear once approved.")
+	http.Redirect(w, r, author.URL(), http.StatusSeeOther)
 }
 
-func (app *application) activate(w http.ResponseWriter, r *http.Request) {
-
-	// retrieving basic template data
-	tmplData := app.newTemplateData(r)
-	tmplData.Title = "Antoine de Barbarin - Activation"
+func (app *application) booking(w http.ResponseWriter, r *http.Request) {
 
-	// retrieving the activation token from the URL and checking it
-	form := newUserActivationForm()
-	form.ActivationToken = flow.Param(r.Context(), "token")
-	if form.ValidateToken(form.ActivationToken); !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "activation.tmpl")
+	// fetching the author to meet
+	slug := flow.Param(r.Context(), "slug")
+	author := app.publicAuthor(w, r, slug)
+	if author == nil {
 		return
 	}
 
-	// putting the form in the template data
-	tmplData.Form = form
+	// retrieving the template data with the free slots
+	form := newBookingForm()
+	form.Author = slug
+	tmplData, err := app.bookingData(r, author, form)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	// rendering the template
-	app.render(w, r, http.StatusOK, "activation.tmpl", tmplData)
+	app.render(w, r, http.StatusOK, "booking.tmpl", tmplData)
 }
 
-func (app *application) activatePost(w http.ResponseWriter, r *http.Request) {
+func (app *application) bookingPost(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving the form data
-	form := newUserActivationForm()
-	err := app.decodePostForm(r, &form)
+	form := newBookingForm()
+	err := app.decodePostForm(r, form)
 	if err != nil {
 		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	// checking the data from the user and return to activation page if there is an error
-	if form.ValidateToken(form.ActivationToken); !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "activation.tmpl")
+	// retrieving the author to meet (the default one without slug)
+	author := app.publicAuthor(w, r, form.Author)
+	if author == nil {
 		return
 	}
 
-	// fetching the user with the token
-	user, err := app.models.UserModel.GetForToken(data.TokenActivation, form.ActivationToken)
-	if err != nil {
+	// checking the form data (an invalid slot being left unset)
+	start, _ := time.Parse(time.RFC3339, form.Slot)
+	booking := &data.Booking{
+		AuthorID: author.ID,
+		Name:     form.Name,
+		Email:    form.Email,
+		Message:  form.Message,
+		StartsAt: start,
+		EndsAt:   start.Add(app.config.booking.duration),
+	}
+	booking.Validate(&form.Validator)
+
+	// checking that the slot is still free
+	if form.Valid() {
+		slots, err := app.freeSlots(author)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		form.Check(slices.ContainsFunc(slots, start.Equal), "slot", "is no longer available, please pick another one")
+	}
+
+	// recording the booking, unless its slot was taken meanwhile
+	if form.Valid() {
+		err = app.models.BookingModel.Insert(booking)
 		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			form.AddFieldError("token", "invalid or expired activation link")
-			app.failedValidationError(w, r, form, &form.Validator, "activation.tmpl")
-		default:
+		case errors.Is(err, data.ErrSlotTaken):
+			form.AddFieldError("slot", "is no longer available, please pick another one")
+		case err != nil:
 			app.serverError(w, r, err)
+			return
 		}
-		return
 	}
 
-	// activating the user
-	user.Status = data.UserActivated
+	// return to the booking page if the data is invalid
+	if !form.Valid() {
+		tmplData, err := app.bookingData(r, author, form)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.render(w, r, http.StatusUnprocessableEntity, "booking.tmpl", tmplData)
+		return
+	}
 
-	// updating the user data
-	err = app.models.UserModel.Update(user)
+	// creating the cancellation link of the visitor, valid until the meeting
+	token, err := app.models.TokenModel.NewForBooking(booking.ID, time.Until(booking.StartsAt), data.TokenBookingCancel)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your session has been activated successfully!")
-	http.Redirect(w, r, "/login", http.StatusSeeOther)
+	// sending the invites
+	app.mailBooking(author, booking, token.Plaintext)
+
+	// notifying the user with a flash message and redirecting to the page of the author
+	app.sessionManager.Put(r.Context(), "flash", "Your meeting is booked! We've sent you the invite by mail.")
+	if form.Author != "" {
+		http.Redirect(w, r, author.URL(), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/home", http.StatusSeeOther)
 }
 
-func (app *application) login(w http.ResponseWriter, r *http.Request) {
+func (app *application) cancelBooking(w http.ResponseWriter, r *http.Request) {
+
+	// checking the cancellation token
+	token := flow.Param(r.Context(), "token")
+	v := validator.New()
+	if v.ValidateToken(token); !v.Valid() {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// fetching the booking of the token
+	booking, err := app.models.BookingModel.GetForToken(data.TokenBookingCancel, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.sessionManager.Put(r.Context(), "flash", "This link is invalid or the meeting has already been cancelled.")
+			http.Redirect(w, r, "/home", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	author, err := app.models.AuthorModel.GetByID(booking.AuthorID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	// retrieving basic template data
 	tmplData := app.newTemplateData(r)
-	tmplData.Title = "Antoine de Barbarin - Login"
-
-	// filling the form with empty values
-	tmplData.Form = newUserLoginForm()
+	tmplData.Title = author.Name + " - Cancel a meeting"
+	tmplData.Author = author
+	booking.StartsAt = booking.StartsAt.In(app.config.booking.location)
+	tmplData.Booking = booking
+	tmplData.Timezone = app.config.booking.timezone
+	tmplData.Form = bookingCancelForm{Token: token}
 
 	// rendering the template
-	app.render(w, r, http.StatusOK, "login.tmpl", tmplData)
+	app.render(w, r, http.StatusOK, "booking-cancel.tmpl", tmplData)
 }
 
-func (app *application) loginPost(w http.ResponseWriter, r *http.Request) {
+func (app *application) cancelBookingPost(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving the form data
-	form := newUserLoginForm()
+	form := bookingCancelForm{Validator: *validator.New()}
 	err := app.decodePostForm(r, &form)
 	if err != nil {
 		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
-
-	// checking the data from the user
-	form.Check(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.Check(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
-	if form.ValidatePassword(form.Password); !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "login.tmpl")
+	if form.ValidateToken(form.Token); !form.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	// fetching the user with the mail address
-	user, err := app.models.UserModel.GetByEmail(form.Email)
+	// fetching the booking of the token
+	booking, err := app.models.BookingModel.GetForToken(data.TokenBookingCancel, form.Token)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			form.AddNonFieldError("invalid credentials")
-			app.failedValidationError(w, r, form, &form.Validator, "login.tmpl")
+			app.sessionManager.Put(r.Context(), "flash", "This link is invalid or the meeting has already been cancelled.")
+			http.Redirect(w, r, "/home", http.StatusSeeOther)
 		default:
 			app.serverError(w, r, err)
 		}
 		return
 	}
 
-	// matching the password
-	match, err := user.Password.Matches(form.Password)
+	// cancelling the booking (the link being used only once)
+	err = app.models.BookingModel.Cancel(booking, 0)
 	if err != nil {
-		app.serverError(w, r, err)
-		return
-	}
-
-	// checking the password match
-	if !match {
-		form.AddNonFieldError("invalid credentials")
-		app.failedValidationError(w, r, form, &form.Validator, "login.tmpl")
+		switch {
+		case errors.Is(err, data.ErrBookingNotModified):
+			app.sessionManager.Put(r.Context(), "flash", "Your meeting has already been cancelled.")
+			http.Redirect(w, r, "/home", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
 		return
 	}
-
-	// renewing the user session
-	err = app.sessionManager.RenewToken(r.Context())
+	author, err := app.models.AuthorModel.GetByID(booking.AuthorID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	// storing the user id in the user session
-	app.sessionManager.Put(r.Context(), authenticatedUserIDSessionManager, user.ID)
+	// sending the cancellations
+	app.mailBooking(author, booking, "")
 
-	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	app.sessionManager.Put(r.Context(), "flash", "Your meeting has been cancelled.")
+	http.Redirect(w, r, author.URL(), http.StatusSeeOther)
 }
 
-func (app *application) forgotPassword(w http.ResponseWriter, r *http.Request) {
+/* #############################################################################
+/*	USER ACCESS
+/* #############################################################################*/
+
+func (app *application) register(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving basic template data
 	tmplData := app.newTemplateData(r)
-	tmplData.Title = "Antoine de Barbarin - Forgot password"
+	tmplData.Title = "Antoine de Barbarin - Register"
 
 	// filling the form with empty values
-	tmplData.Form = newForgotPasswordForm()
+	tmplData.Form = newUserRegisterForm()
 
 	// rendering the template
-	app.render(w, r, http.StatusOK, "forgot-password.tmpl", tmplData)
+	app.render(w, r, http.StatusOK, "register.tmpl", tmplData)
 }
 
-func (app *application) forgotPasswordPost(w http.ResponseWriter, r *http.Request) {
+func (app *application) registerPost(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving the form data
-	form := newForgotPasswordForm()
+	form := newUserRegisterForm()
 	err := app.decodePostForm(r, &form)
 	if err != nil {
 		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
+	// DEBUG
+	app.logger.Debug(fmt.Sprintf("form: %+v", form))
+
 	// checking the data from the user
+	form.StringCheck(form.Username, 2, 70, true, "username")
 	form.ValidateEmail(form.Email)
+	form.ValidateRegisterPassword(form.Password, form.ConfirmPassword)
 
-	// return to forgot-password page if there is an error
+	// return to register page if there is an error
 	if !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "forgot-password.tmpl")
+		app.failedValidationError(w, r, form, &form.Validator, "register.tmpl")
 		return
 	}
 
-	// fetching the user
-	user, err := app.models.UserModel.GetByEmail(form.Email)
-
-	// if the user exists
-	if nil == err {
+	// creating the user
+	user := &data.User{
+		Name:   form.Username,
+		Email:  form.Email,
+		Avatar: "/static/img/avatar.png", // TODO -> implement avatar in all user handlers
+		Status: data.UserToActivate,
+	}
 
-		// Generate a reset token and send a mail if the user exists
-		token, err := app.models.TokenModel.New(user.ID, 24*time.Hour, data.TokenReset)
-		if err != nil {
-			app.serverError(w, r, err)
-			return
-		}
+	// setting the password hash
+	err = user.Password.Set(form.Password)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-		app.background(func() {
+	// verifying the user data
+	if data.ValidateUser(&form.Validator, user); !form.Valid() {
 
-			mailData := map[string]any{
-				"userID":     user.ID,
-				"username":   user.Name,
-				"resetToken": token.Plaintext,
-			}
+		// redirect to login page with errors
+		app.failedValidationError(w, r, form, &form.Validator, "register.tmpl")
+		return
+	}
 
-			err = app.mailer.Send(user.Email, "forgot_password.tmpl", mailData)
-			if err != nil {
-				app.logger.Error(err.Error())
-			}
-		})
+	// inserting the user in the DB
+	err = app.models.UserModel.Insert(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			form.AddFieldError("email", "a user with this email address already exists")
+			app.failedValidationError(w, r, form, &form.Validator, "register.tmpl")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
 
-	} else if !errors.Is(err, data.ErrRecordNotFound) {
+	// Generating an activation token to send it via mail to the user
+	token, err := app.models.TokenModel.New(user.ID, 3*24*time.Hour, data.TokenActivation)
+	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	// do this anyway (even if the user doesn't exist)
-	app.sessionManager.Put(r.Context(), "flash", "We've sent you a mail to reset your password!")
+	app.background(func() {
+
+		mailData := map[string]any{
+			"userID":          user.ID,
+			"username":        user.Name,
+			"activationToken": token.Plaintext,
+		}
+
+		err = app.mailer.Send(user.Email, "user_welcome.tmpl", mailData)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	app.sessionManager.Put(r.Context(), "flash", "We've sent you a confirmation email!")
+
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
-func (app *application) resetPassword(w http.ResponseWriter, r *http.Request) {
+func (app *application) activate(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving basic template data
 	tmplData := app.newTemplateData(r)
-	tmplData.Title = "Antoine de Barbarin - Reset password"
-
-	// filling the form with empty values
-	form := newResetPasswordForm()
+	tmplData.Title = "Antoine de Barbarin - Activation"
 
-	// retrieving the reset token from the URL
-	form.Token = flow.Param(r.Context(), "token")
-	if form.ValidateToken(form.Token); !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "reset-password.tmpl")
+	// retrieving the activation token from the URL and checking it
+	form := newUserActivationForm()
+	form.ActivationToken = flow.Param(r.Context(), "token")
+	if form.ValidateToken(form.ActivationToken); !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "activation.tmpl")
 		return
 	}
 
@@ -524,547 +904,3129 @@ func (app *application) resetPassword(w http.ResponseWriter, r *http.Request) {
 	tmplData.Form = form
 
 	// rendering the template
-	app.render(w, r, http.StatusOK, "reset-password.tmpl", tmplData)
+	app.render(w, r, http.StatusOK, "activation.tmpl", tmplData)
 }
 
-func (app *application) resetPasswordPost(w http.ResponseWriter, r *http.Request) {
+func (app *application) activatePost(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving the form data
-	form := newResetPasswordForm()
+	form := newUserActivationForm()
 	err := app.decodePostForm(r, &form)
 	if err != nil {
 		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	// checking the data from the user and return to reset password page if there is an error
-	form.ValidateNewPassword(form.NewPassword, form.ConfirmPassword)
-	if form.ValidateToken(form.Token); !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "reset-password.tmpl")
+	// checking the data from the user and return to activation page if there is an error
+	if form.ValidateToken(form.ActivationToken); !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "activation.tmpl")
 		return
 	}
 
 	// fetching the user with the token
-	user, err := app.models.UserModel.GetForToken(data.TokenReset, form.Token)
+	user, err := app.models.UserModel.GetForToken(data.TokenActivation, form.ActivationToken)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			form.AddFieldError("token", "invalid or expired link")
-			app.failedValidationError(w, r, form, &form.Validator, "reset-password.tmpl")
+			form.AddFieldError("token", "invalid or expired activation link")
+			app.failedValidationError(w, r, form, &form.Validator, "activation.tmpl")
 		default:
 			app.serverError(w, r, err)
 		}
 		return
 	}
 
-	// setting the new password
-	err = user.Password.Set(form.NewPassword)
-	if err != nil {
-		app.serverError(w, r, err)
-		return
-	}
+	// activating the user
+	user.Status = data.UserActivated
 
-	// updating the user with the new password
+	// updating the user data
 	err = app.models.UserModel.Update(user)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated successfully!")
+	app.sessionManager.Put(r.Context(), "flash", "Your session has been activated successfully!")
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
-/* #############################################################################
-/*	RESTRICTED
-/* #############################################################################*/
-
-func (app *application) dashboard(w http.ResponseWriter, r *http.Request) {
+func (app *application) invitation(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving basic template data
 	tmplData := app.newTemplateData(r)
-	tmplData.Title = "Antoine de Barbarin - Dashboard"
+	tmplData.Title = "Antoine de Barbarin - Invitation"
+
+	// retrieving the invitation token from the URL and checking it
+	form := newInvitationForm()
+	form.Token = flow.Param(r.Context(), "token")
+	if form.ValidateToken(form.Token); !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "invitation.tmpl")
+		return
+	}
+
+	// putting the form in the template data
+	tmplData.Form = form
 
 	// rendering the template
-	app.render(w, r, http.StatusOK, "dashboard.tmpl", tmplData)
+	app.render(w, r, http.StatusOK, "invitation.tmpl", tmplData)
 }
 
-func (app *application) logoutPost(w http.ResponseWriter, r *http.Request) {
+func (app *application) invitationPost(w http.ResponseWriter, r *http.Request) {
 
-	// logging the user out
-	err := app.logout(r)
+	// retrieving the form data
+	form := newInvitationForm()
+	err := app.decodePostForm(r, &form)
 	if err != nil {
-
-		// DEBUG
-		app.logger.Debug(fmt.Sprintf("error: %s", err.Error()))
-
-		app.serverError(w, r, err)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
-
-	http.Redirect(w, r, "/", http.StatusSeeOther)
-}
+	// checking the data from the user and return to invitation page if there is an error
+	form.StringCheck(form.Username, 2, 70, true, "username")
+	form.ValidateNewPassword(form.NewPassword, form.ConfirmPassword)
+	if form.ValidateToken(form.Token); !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "invitation.tmpl")
+		return
+	}
 
-func (app *application) updateAuthor(w http.ResponseWriter, r *http.Request) {
+	// delaying the attempts of the client after their failed ones
+	ip := clientIP(r)
+	wait, err := app.attemptWait("", ip)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		form.AddNonFieldError(waitMessage(wait))
+		app.tooManyAttempts(w, r, form, "invitation.tmpl", wait)
+		return
+	}
 
-	// retrieving basic template data
-	tmplData := app.newTemplateData(r)
-	tmplData.Title = "Antoine de Barbarin - Update Author"
+	// fetching the invited user with the token, the failed attempt being recorded
+	user, err := app.models.UserModel.GetForToken(data.TokenInvitation, form.Token)
+	if err == nil && user.Status != data.UserInvited {
+		err = data.ErrRecordNotFound
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			err = app.failedAttempt(data.AttemptInvitation, "", ip)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			form.AddFieldError("token", "invalid or expired invitation link")
+			app.failedValidationError(w, r, form, &form.Validator, "invitation.tmpl")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
 
-	// filling the form with values
-	author, err := app.models.AuthorModel.Get()
+	// setting up the account of the user
+	user.Name = form.Username
+	user.Status = data.UserActivated
+	err = user.Password.Set(form.NewPassword)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
-	form := app.newAuthorUpdateForm()
-	form.Name = &author.Name
-	form.Email = &author.Email
-	form.Avatar = &author.Avatar
-	form.Presentation = author.Presentation
-	form.Experiences = author.Experiences
-	form.Formations = author.Formations
-	form.Location = &author.Location
-	form.Birth = &author.Birth
-	form.Tags = author.Tags
-	form.CVFile = &author.CVFile
-	form.StatusActivity = &author.StatusActivity
-	tmplData.Form = form
 
-	app.render(w, r, http.StatusOK, "author-update.tmpl", tmplData)
+	// updating the user and forgetting their invitation
+	err = app.models.UserModel.Update(user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.models.TokenModel.DeleteAllForUser(data.TokenInvitation, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your account is ready, you can sign in!")
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
-func (app *application) updateAuthorPost(w http.ResponseWriter, r *http.Request) {
+const (
+	// twoFactorTimeout is the time the users have to type the code of their authenticator app after their password
+	twoFactorTimeout = 5 * time.Minute
+
+	// maxTwoFactorAttempts is the number of invalid codes after which the users type their password again
+	maxTwoFactorAttempts = 5
+
+	// trustedDeviceLifetime is the time a trusted device skips the two-factor authentication
+	trustedDeviceLifetime = 30 * 24 * time.Hour
+
+	// totpIssuer is the name of the site in the authenticator apps
+	totpIssuer = "Antoine's Portfolio"
+
+	// freeAttempts is the number of failed attempts of an account or a client IP before the next ones are delayed,
+	// by attemptDelay and then twice as long after each failed one, up to maxAttemptDelay
+	freeAttempts    = 3
+	attemptDelay    = time.Second
+	maxAttemptDelay = time.Minute
+
+	// invitationLifetime is the time the invited users have to set up their account
+	invitationLifetime = 7 * 24 * time.Hour
+
+	// sessionSeenInterval is how often the last time a session was seen is recorded
+	sessionSeenInterval = time.Minute
+
+	// maxUserAgentSize is the size of the longest user agent recorded with the sessions
+	maxUserAgentSize = 500
+)
+
+func (app *application) login(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Login"
+
+	// filling the form with empty values
+	tmplData.Form = newUserLoginForm()
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "login.tmpl", tmplData)
+}
+
+func (app *application) loginPost(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving the form data
-	form := app.newAuthorUpdateForm()
+	form := newUserLoginForm()
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.serverError(w, r, err)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	// getting the author data
-	author, err := app.models.AuthorModel.Get()
-	if err != nil {
-		app.serverError(w, r, err)
+	// checking the data from the user
+	form.Check(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.Check(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	if form.ValidatePassword(form.Password); !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "login.tmpl")
 		return
 	}
 
-	// checking the data from the user
-	if form.Name != nil {
-		author.Name = *form.Name
-	}
-	if form.Email != nil {
-		author.Email = *form.Email
-	}
-	if form.Avatar != nil {
-		author.Avatar = *form.Avatar
-	}
-	author.Presentation = form.Presentation
-	if form.Location != nil {
-		author.Location = *form.Location
-	}
-	if form.Birth != nil {
-		author.Birth = *form.Birth
-	}
-	author.Formations = form.Formations
-	author.Experiences = form.Experiences
-	author.Tags = form.Tags
-	if form.CVFile != nil {
-		author.CVFile = *form.CVFile
+	// delaying the attempts of the account and of the client after their failed ones
+	ip := clientIP(r)
+	wait, err := app.attemptWait(form.Email, ip)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
-	if form.StatusActivity != nil {
-		author.StatusActivity = *form.StatusActivity
+	if wait > 0 {
+		form.AddNonFieldError(waitMessage(wait))
+		app.tooManyAttempts(w, r, form, "login.tmpl", wait)
+		return
 	}
 
-	if author.Validate(&form.Validator); !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "author-update.tmpl")
+	// fetching the user with the mail address
+	user, err := app.models.UserModel.GetByEmail(form.Email)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverError(w, r, err)
 		return
 	}
 
-	// updating the user
-	err = app.models.AuthorModel.Update(author)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.clientError(w, r, http.StatusNotFound)
-		default:
+	// matching the password
+	match := false
+	if user != nil {
+		match, err = user.Password.Matches(form.Password)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// checking the password match, the failed attempt being recorded
+	if !match {
+		err = app.failedAttempt(data.AttemptLogin, form.Email, ip)
+		if err != nil {
 			app.serverError(w, r, err)
+			return
 		}
+		form.AddNonFieldError("invalid credentials")
+		app.failedValidationError(w, r, form, &form.Validator, "login.tmpl")
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "The author data has been updated successfully!")
-	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
-}
+	// refusing the accounts disabled by an admin
+	if !user.IsActive() {
+		form.AddNonFieldError("this account is disabled")
+		app.failedValidationError(w, r, form, &form.Validator, "login.tmpl")
+		return
+	}
 
-func (app *application) updateUser(w http.ResponseWriter, r *http.Request) {
+	// asking for the code of the authenticator app of the user, unless they trust this device
+	if user.TOTPEnabled() && !app.isTrustedDevice(r, user) {
 
-	// retrieving basic template data
-	tmplData := app.newTemplateData(r)
-	tmplData.Title = "Antoine de Barbarin - Update user"
+		// renewing the user session, the user not being authenticated yet
+		err = app.sessionManager.RenewToken(r.Context())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
 
-	// retrieving user ID
-	id := app.getUserID(r)
+		app.sessionManager.Put(r.Context(), pendingUserIDSessionManager, user.ID)
+		app.sessionManager.Put(r.Context(), pendingSinceSessionManager, time.Now().Unix())
+		app.sessionManager.Remove(r.Context(), pendingAttemptsSessionManager)
 
-	// fetching user data
-	user, err := app.models.UserModel.GetByID(id)
+		http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	// clearing the failed attempts of the account
+	err = app.models.LoginAttemptModel.Clear(data.LockoutAccount, user.Email)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	// filling the form with user values
-	tmplData.Form = newUserUpdateForm(user)
+	app.signIn(w, r, user.ID)
+}
+
+func (app *application) loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	// checking that the user typed their password
+	if app.pendingUser(w, r) == nil {
+		return
+	}
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Two-factor authentication"
+
+	// filling the form with empty values
+	tmplData.Form = twoFactorLoginForm{Validator: *validator.New()}
 
 	// rendering the template
-	app.render(w, r, http.StatusOK, "user-update.tmpl", tmplData)
+	app.render(w, r, http.StatusOK, "login-2fa.tmpl", tmplData)
 }
 
-func (app *application) updateUserPost(w http.ResponseWriter, r *http.Request) {
+func (app *application) loginTwoFactorPost(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving the form data
-	form := newUserUpdateForm(nil)
+	form := twoFactorLoginForm{Validator: *validator.New()}
 	err := app.decodePostForm(r, &form)
 	if err != nil {
 		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	// fetching the authenticated user
-	user, err := app.models.UserModel.GetByID(app.getUserID(r))
+	// fetching the user who typed their password
+	user := app.pendingUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// delaying the attempts of the account and of the client after their failed ones
+	ip := clientIP(r)
+	wait, err := app.attemptWait(user.Email, ip)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
+	if wait > 0 {
+		form.AddNonFieldError(waitMessage(wait))
+		app.tooManyAttempts(w, r, form, "login-2fa.tmpl", wait)
+		return
+	}
 
-	// checking the data from the user
-	var isEmpty = true
-	if form.Username != nil {
-		isEmpty = false
-		form.StringCheck(*form.Username, 2, 70, false, "username")
-		user.Name = *form.Username
+	// checking the code of the authenticator app, or else a recovery code
+	valid, recovery := false, false
+	form.Code = strings.TrimSpace(form.Code)
+	if counter, ok := totp.Validate(user.TOTPSecret, form.Code, time.Now()); ok {
+		err = app.models.UserModel.UseTOTP(user.ID, counter)
+		valid = err == nil
+		if errors.Is(err, data.ErrTOTPReused) {
+			err = nil
+		}
+	} else if form.Code != "" {
+		err = app.models.RecoveryCodeModel.Use(user.ID, form.Code)
+		valid, recovery = err == nil, err == nil
+		if errors.Is(err, data.ErrRecordNotFound) {
+			err = nil
+		}
 	}
-	if form.Password != nil || form.NewPassword != nil || form.ConfirmationPassword != nil {
-		isEmpty = false
-		form.ValidateNewPassword(*form.NewPassword, *form.ConfirmationPassword)
-		err = user.Password.Set(*form.NewPassword)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// asking for the password again after too many invalid codes, the failed attempt being recorded
+	if !valid {
+		err = app.failedAttempt(data.AttemptTwoFactor, user.Email, ip)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		attempts := app.sessionManager.GetInt(r.Context(), pendingAttemptsSessionManager) + 1
+		if attempts >= maxTwoFactorAttempts {
+			app.clearPendingUser(r)
+			app.sessionManager.Put(r.Context(), "flash", "Too many invalid codes, please sign in again.")
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		app.sessionManager.Put(r.Context(), pendingAttemptsSessionManager, attempts)
+
+		form.AddNonFieldError("invalid code")
+		app.failedValidationError(w, r, form, &form.Validator, "login-2fa.tmpl")
+		return
+	}
+	app.clearPendingUser(r)
+
+	// clearing the failed attempts of the account
+	err = app.models.LoginAttemptModel.Clear(data.LockoutAccount, user.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// trusting the device if asked, until the user enables the two-factor authentication again
+	if form.Trust {
+		err = app.trustedDevices.RenewToken(r.Context())
 		if err != nil {
 			app.serverError(w, r, err)
 			return
 		}
+		app.trustedDevices.Put(r.Context(), trustedUserIDSessionManager, user.ID)
+		app.trustedDevices.Put(r.Context(), trustedTOTPEnabledSessionManager, user.TOTPEnabledAt.Unix())
 	}
-	if form.Email != nil {
-		isEmpty = false
-		form.ValidateEmail(*form.Email)
-		user.Email = *form.Email
+
+	// warning the user about the recovery codes they have left
+	if recovery {
+		left, err := app.models.RecoveryCodeModel.Count(user.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You signed in with a recovery code, %d left.", left))
 	}
-	if isEmpty {
-		form.AddNonFieldError("at least one field is required")
+
+	app.signIn(w, r, user.ID)
+}
+
+func (app *application) loginPasskeyOptions(w http.ResponseWriter, r *http.Request) {
+
+	// checking that the request is sent to the site the passkeys are registered for
+	rp, err := app.relyingParty(r)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// generating the challenge the passkey signs
+	challenge, err := app.newPasskeyChallenge(r, passkeyLoginSessionManager)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"publicKey": rp.RequestOptions(challenge)})
+}
+
+func (app *application) loginPasskey(w http.ResponseWriter, r *http.Request) {
+
+	// checking that the request is sent to the site the passkeys are registered for
+	rp, err := app.relyingParty(r)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// retrieving the response of the authenticator
+	var response webauthn.AssertionResponse
+	err = app.readJSON(w, r, &response)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// retrieving the challenge of the ceremony
+	challenge := app.passkeyChallenge(r, passkeyLoginSessionManager)
+	if challenge == nil {
+		app.ajaxResponse(w, http.StatusBadRequest, "the sign in timed out, please try again")
+		return
+	}
+
+	// fetching the passkey, which has to be the one of the user the authenticator holds it for
+	passkey, err := app.models.PasskeyModel.GetByCredentialID(response.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.ajaxResponse(w, http.StatusUnauthorized, "unknown passkey, it may have been removed")
+		default:
+			app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if len(response.UserHandle) != 0 && !bytes.Equal(response.UserHandle, passkeyUserHandle(passkey.UserID)) {
+		app.ajaxResponse(w, http.StatusUnauthorized, "invalid passkey")
+		return
+	}
+
+	// refusing the accounts disabled by an admin
+	user, err := app.models.UserModel.GetByID(passkey.UserID)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !user.IsActive() {
+		app.ajaxResponse(w, http.StatusForbidden, "this account is disabled")
+		return
+	}
+
+	// checking the signature of the passkey
+	credential := &webauthn.Credential{ID: passkey.CredentialID, PublicKey: passkey.PublicKey, SignCount: passkey.SignCount}
+	passkey.SignCount, err = rp.VerifyAssertion(challenge, response, credential)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	err = app.models.PasskeyModel.Use(passkey)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// signing in without the two-factor authentication, the passkey verifying the user itself (PIN, biometrics),
+	// nor the lockouts (a passkey can't be guessed, the user signing in even while their password is attacked)
+	app.clearPendingUser(r)
+	err = app.startSession(r, passkey.UserID)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"redirect": "/dashboard"})
+}
+
+func (app *application) forgotPassword(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Forgot password"
+
+	// filling the form with empty values
+	tmplData.Form = newForgotPasswordForm()
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "forgot-password.tmpl", tmplData)
+}
+
+func (app *application) forgotPasswordPost(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	form := newForgotPasswordForm()
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// checking the data from the user
+	form.ValidateEmail(form.Email)
+
+	// return to forgot-password page if there is an error
+	if !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "forgot-password.tmpl")
+		return
+	}
+
+	// delaying the requests of the client after their last ones, each of them being recorded
+	ip := clientIP(r)
+	wait, err := app.attemptWait("", ip)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		form.AddNonFieldError(waitMessage(wait))
+		app.tooManyAttempts(w, r, form, "forgot-password.tmpl", wait)
+		return
+	}
+	err = app.failedAttempt(data.AttemptForgotPassword, form.Email, ip)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// fetching the user
+	user, err := app.models.UserModel.GetByEmail(form.Email)
+
+	// if the user exists
+	if nil == err {
+
+		// Generate a reset token and send a mail if the user exists
+		token, err := app.models.TokenModel.New(user.ID, 24*time.Hour, data.TokenReset)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.background(func() {
+
+			mailData := map[string]any{
+				"userID":     user.ID,
+				"username":   user.Name,
+				"resetToken": token.Plaintext,
+			}
+
+			err = app.mailer.Send(user.Email, "forgot_password.tmpl", mailData)
+			if err != nil {
+				app.logger.Error(err.Error())
+			}
+		})
+
+	} else if !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// do this anyway (even if the user doesn't exist)
+	app.sessionManager.Put(r.Context(), "flash", "We've sent you a mail to reset your password!")
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (app *application) resetPassword(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Reset password"
+
+	// filling the form with empty values
+	form := newResetPasswordForm()
+
+	// retrieving the reset token from the URL
+	form.Token = flow.Param(r.Context(), "token")
+	if form.ValidateToken(form.Token); !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "reset-password.tmpl")
+		return
+	}
+
+	// putting the form in the template data
+	tmplData.Form = form
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "reset-password.tmpl", tmplData)
+}
+
+func (app *application) resetPasswordPost(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	form := newResetPasswordForm()
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// checking the data from the user and return to reset password page if there is an error
+	form.ValidateNewPassword(form.NewPassword, form.ConfirmPassword)
+	if form.ValidateToken(form.Token); !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "reset-password.tmpl")
+		return
+	}
+
+	// delaying the attempts of the client after their failed ones
+	ip := clientIP(r)
+	wait, err := app.attemptWait("", ip)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		form.AddNonFieldError(waitMessage(wait))
+		app.tooManyAttempts(w, r, form, "reset-password.tmpl", wait)
+		return
+	}
+
+	// fetching the user with the token, the failed attempt being recorded
+	user, err := app.models.UserModel.GetForToken(data.TokenReset, form.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			err = app.failedAttempt(data.AttemptResetPassword, "", ip)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			form.AddFieldError("token", "invalid or expired link")
+			app.failedValidationError(w, r, form, &form.Validator, "reset-password.tmpl")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// setting the new password
+	err = user.Password.Set(form.NewPassword)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// updating the user with the new password
+	err = app.models.UserModel.Update(user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// logging out all the sessions of the user and forgetting their trusted devices, in case someone else got their password
+	_, err = app.revokeSessions(r, user.ID, "", true)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if app.getUserID(r) == user.ID {
+		err = app.sessionManager.Destroy(r.Context())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated successfully, all your sessions have been logged out!")
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+/* #############################################################################
+/*	RESTRICTED
+/* #############################################################################*/
+
+func (app *application) dashboard(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Dashboard"
+
+	// getting the testimonials and the meetings of the profile of the user
+	author, err := app.models.AuthorModel.GetByUserID(app.getUserID(r))
+	switch {
+	case err == nil:
+		tmplData.Testimonials, err = app.models.TestimonialModel.GetForAuthor(author.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		// getting the upcoming meetings, in the time zone of the bookings
+		tmplData.Bookings, err = app.models.BookingModel.GetUpcoming(author.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		for _, booking := range tmplData.Bookings {
+			booking.StartsAt = booking.StartsAt.In(app.config.booking.location)
+		}
+		tmplData.Timezone = app.config.booking.timezone
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.serverError(w, r, err)
+		return
+	}
+
+	// getting the current lockouts and the failed attempts of the last week for the admins, in the time zone of the site
+	if app.contextUser(r).Can(data.PermissionUsers) {
+		tmplData.Lockouts, err = app.models.LoginAttemptModel.GetLockouts()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		for _, lockout := range tmplData.Lockouts {
+			lockout.CreatedAt = lockout.CreatedAt.In(app.config.booking.location)
+			lockout.LockedUntil = lockout.LockedUntil.In(app.config.booking.location)
+		}
+		tmplData.LoginAttempts, err = app.models.LoginAttemptModel.GetRecent(time.Now().AddDate(0, 0, -7), 50)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		for _, attempt := range tmplData.LoginAttempts {
+			attempt.CreatedAt = attempt.CreatedAt.In(app.config.booking.location)
+		}
+		tmplData.Timezone = app.config.booking.timezone
+	}
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "dashboard.tmpl", tmplData)
+}
+
+func (app *application) unlock(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the lockout ID
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// ending the lockout
+	lockout, err := app.models.LoginAttemptModel.Unlock(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.sessionManager.Put(r.Context(), "flash", "This lockout has already ended.")
+			http.Redirect(w, r, "/dashboard#security", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s has been unlocked!", lockout.Value))
+	http.Redirect(w, r, "/dashboard#security", http.StatusSeeOther)
+}
+
+func (app *application) users(w http.ResponseWriter, r *http.Request) {
+	app.renderUsersPage(w, r, http.StatusOK, newUserInviteForm())
+}
+
+func (app *application) inviteUser(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	form := newUserInviteForm()
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// checking the data from the user
+	form.ValidateEmail(form.Email)
+	form.Check(validator.PermittedValue(form.Role, data.Roles...), "role", "invalid role")
+	if !form.Valid() {
+		app.renderUsersPage(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	// creating the invited user, named after their email until they set up their account,
+	// with a random password nobody knows until they choose theirs
+	password, err := newNonce()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	user := &data.User{
+		Name:   strings.Split(form.Email, "@")[0],
+		Email:  form.Email,
+		Avatar: "/static/img/avatar.png",
+		Status: data.UserInvited,
+		Role:   form.Role,
+	}
+	err = user.Password.Set(password)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// inserting the user in the DB
+	err = app.models.UserModel.Insert(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			form.AddFieldError("email", "a user with this email address already exists")
+			app.renderUsersPage(w, r, http.StatusUnprocessableEntity, form)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// mailing the invitation link
+	err = app.sendInvitation(user, app.contextUser(r).Name)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("An invitation has been sent to %s!", user.Email))
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
+
+// managedUser returns the user of the id in the path the admin manages, or else writes the response
+// (nil being returned), the admins not managing their own account here not to lock themselves out
+func (app *application) managedUser(w http.ResponseWriter, r *http.Request) *data.User {
+
+	// retrieving the user ID
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return nil
+	}
+	if id == app.getUserID(r) {
+		app.sessionManager.Put(r.Context(), "flash", "You can't change your own account here.")
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return nil
+	}
+
+	// fetching the user
+	user, err := app.models.UserModel.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil
+	}
+
+	return user
+}
+
+// updateManagedUser saves the changes made to user, flashing msg once done
+func (app *application) updateManagedUser(w http.ResponseWriter, r *http.Request, user *data.User, msg string) {
+
+	err := app.models.UserModel.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.sessionManager.Put(r.Context(), "flash", "This user has been modified in the meantime, please try again.")
+			http.Redirect(w, r, "/users", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", msg)
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
+
+func (app *application) resendInvitation(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the invited user
+	user := app.managedUser(w, r)
+	if user == nil {
+		return
+	}
+	if user.Status != data.UserInvited {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s already accepted their invitation.", user.Name))
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	// replacing the previous invitation link
+	err := app.models.TokenModel.DeleteAllForUser(data.TokenInvitation, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.sendInvitation(user, app.contextUser(r).Name)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("A new invitation has been sent to %s!", user.Email))
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
+
+func (app *application) updateUserRole(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	form := userRoleForm{Validator: *validator.New()}
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	if !validator.PermittedValue(form.Role, data.Roles...) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// fetching the user
+	user := app.managedUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// changing their role
+	user.Role = form.Role
+	app.updateManagedUser(w, r, user, fmt.Sprintf("%s is now %s.", user.Name, strings.ToLower(user.RoleLabel())))
+}
+
+func (app *application) disableUser(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the user
+	user := app.managedUser(w, r)
+	if user == nil {
+		return
+	}
+	if user.Status != data.UserActivated {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s can't sign in already.", user.Name))
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	// disabling their account and logging out their sessions
+	_, err := app.revokeSessions(r, user.ID, "", true)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	user.Status = data.UserDisabled
+	app.updateManagedUser(w, r, user, fmt.Sprintf("%s has been disabled.", user.Name))
+}
+
+func (app *application) enableUser(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the user
+	user := app.managedUser(w, r)
+	if user == nil {
+		return
+	}
+	if user.Status != data.UserDisabled {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s is not disabled.", user.Name))
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	// enabling their account again
+	user.Status = data.UserActivated
+	app.updateManagedUser(w, r, user, fmt.Sprintf("%s has been enabled.", user.Name))
+}
+
+func (app *application) deleteUser(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the user
+	user := app.managedUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// deleting the user, their author profile and their posts being kept
+	err := app.models.UserModel.Delete(user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s has been deleted.", user.Name))
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
+
+func (app *application) logoutPost(w http.ResponseWriter, r *http.Request) {
+
+	// logging the user out
+	err := app.logout(r)
+	if err != nil {
+
+		// DEBUG
+		app.logger.Debug(fmt.Sprintf("error: %s", err.Error()))
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) updateAuthor(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Update Author"
+
+	// filling the form with the profile of the user, or with their account for a new one
+	author, err := app.models.AuthorModel.GetByUserID(app.getUserID(r))
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.serverError(w, r, err)
+			return
+		}
+		user, err := app.models.UserModel.GetByID(app.getUserID(r))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		author = &data.Author{Slug: data.Slugify(user.Name), Name: user.Name, Email: user.Email, Avatar: user.Avatar}
+	}
+	tmplData.Author = nil
+	if author.ID != 0 {
+		tmplData.Author = author
+	}
+	form := app.newAuthorUpdateForm()
+	form.Slug = &author.Slug
+	form.Name = &author.Name
+	form.Email = &author.Email
+	form.Avatar = &author.Avatar
+	form.Presentation = author.Presentation
+	form.Location = &author.Location
+	form.Birth = &author.Birth
+	form.CVFile = &author.CVFile
+	form.StatusActivity = &author.StatusActivity
+	tmplData.Form = form
+
+	// listing the projects and the posts the skills can link to
+	err = app.skillLinkChoices(&tmplData)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, http.StatusOK, "author-update.tmpl", tmplData)
+}
+
+func (app *application) updateAuthorPost(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	form := app.newAuthorUpdateForm()
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// getting the profile of the user (a new one if they have none yet)
+	author, err := app.models.AuthorModel.GetByUserID(app.getUserID(r))
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.serverError(w, r, err)
+			return
+		}
+		author = &data.Author{UserID: app.getUserID(r)}
+	}
+
+	// checking the data from the user
+	if form.Slug != nil {
+		author.Slug = strings.TrimSpace(*form.Slug)
+	}
+	if form.Name != nil {
+		author.Name = *form.Name
+	}
+	if form.Email != nil {
+		author.Email = *form.Email
+	}
+	if form.Avatar != nil {
+		author.Avatar = *form.Avatar
+	}
+	author.Presentation = form.Presentation
+	if form.Location != nil {
+		author.Location = *form.Location
+	}
+	if form.Birth != nil {
+		author.Birth = *form.Birth
+	}
+	if form.CVFile != nil {
+		author.CVFile = *form.CVFile
+	}
+	if form.StatusActivity != nil {
+		author.StatusActivity = *form.StatusActivity
+	}
+
+	// rendering the page again with the errors
+	failedValidation := func() {
+		tmplData := app.newTemplateData(r)
+		tmplData.Author = nil
+		if author.ID != 0 {
+			tmplData.Author = author
+		}
+		tmplData.Form = form
+		err = app.skillLinkChoices(&tmplData)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.render(w, r, http.StatusUnprocessableEntity, "author-update.tmpl", tmplData)
+	}
+
+	if author.Validate(&form.Validator); !form.Valid() {
+		failedValidation()
+		return
+	}
+
+	// creating or updating the profile
+	if author.ID == 0 {
+		err = app.models.AuthorModel.Insert(author)
+	} else {
+		err = app.models.AuthorModel.Update(author)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		case errors.Is(err, data.ErrDuplicateAuthorSlug):
+			form.AddFieldError("slug", "is already in use")
+			failedValidation()
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The author data has been updated successfully!")
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// timelineEntry fills entry with the timeline form of the request, and returns the validation errors
+func (app *application) timelineEntry(r *http.Request, entry *data.TimelineEntry) (*timelineEntryForm, error) {
+
+	// retrieving the form data
+	form := timelineEntryForm{Validator: *validator.New()}
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Organization = strings.TrimSpace(form.Organization)
+	entry.Title = strings.TrimSpace(form.Title)
+	entry.Location = strings.TrimSpace(form.Location)
+	entry.Description = strings.TrimSpace(form.Description)
+	entry.URL = strings.TrimSpace(form.URL)
+	entry.Position = form.Position
+
+	entry.StartDate = parseMonth(&form.Validator, form.StartDate, "start_date")
+	entry.EndDate = parseMonth(&form.Validator, form.EndDate, "end_date")
+
+	entry.Validate(&form.Validator)
+
+	return &form, nil
+}
+
+func (app *application) createTimelineEntry(w http.ResponseWriter, r *http.Request) {
+
+	entry := &data.TimelineEntry{Kind: flow.Param(r.Context(), "kind")}
+	if !data.IsTimelineKind(entry.Kind) {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// getting the profile the entry is added to
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	entry.AuthorID = author.ID
+
+	// checking the data from the user
+	form, err := app.timelineEntry(r, entry)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", "Invalid entry: "+fieldErrorsMessage(&form.Validator))
+		http.Redirect(w, r, "/author#"+entry.Kind, http.StatusSeeOther)
+		return
+	}
+
+	// recording the entry
+	err = app.models.AuthorModel.InsertTimelineEntry(entry)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.touchProfile(author.ID)
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%q has been added!", entry.Title))
+	http.Redirect(w, r, "/author#"+entry.Kind, http.StatusSeeOther)
+}
+
+func (app *application) updateTimelineEntry(w http.ResponseWriter, r *http.Request) {
+
+	// getting the entry
+	id, err := strconv.Atoi(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+	entry, err := app.models.AuthorModel.GetTimelineEntry(flow.Param(r.Context(), "kind"), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// checking that the entry belongs to the profile of the user
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	if entry.AuthorID != author.ID {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// checking the data from the user
+	form, err := app.timelineEntry(r, entry)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", "Invalid entry: "+fieldErrorsMessage(&form.Validator))
+		http.Redirect(w, r, "/author#"+entry.Kind, http.StatusSeeOther)
+		return
+	}
+
+	// saving the changes
+	err = app.models.AuthorModel.UpdateTimelineEntry(entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.touchProfile(author.ID)
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%q has been updated!", entry.Title))
+	http.Redirect(w, r, "/author#"+entry.Kind, http.StatusSeeOther)
+}
+
+func (app *application) deleteTimelineEntry(w http.ResponseWriter, r *http.Request) {
+
+	kind := flow.Param(r.Context(), "kind")
+	id, err := strconv.Atoi(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// deleting the entry from the profile of the user
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	err = app.models.AuthorModel.DeleteTimelineEntry(kind, id, author.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.touchProfile(author.ID)
+
+	app.sessionManager.Put(r.Context(), "flash", "The entry has been deleted!")
+	http.Redirect(w, r, "/author#"+kind, http.StatusSeeOther)
+}
+
+// skill fills skill with the skill form of the request, and returns the validation errors
+func (app *application) skill(r *http.Request, skill *data.Skill) (*skillForm, error) {
+
+	// retrieving the form data
+	form := skillForm{Validator: *validator.New()}
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		return nil, err
+	}
+
+	skill.Name = strings.TrimSpace(form.Name)
+	skill.Category = form.Category
+	skill.Level = form.Level
+	skill.Years = form.Years
+	skill.Position = form.Position
+	skill.ProjectIDs = form.ProjectIDs
+	skill.PostIDs = form.PostIDs
+
+	skill.Validate(&form.Validator)
+
+	return &form, nil
+}
+
+func (app *application) createSkill(w http.ResponseWriter, r *http.Request) {
+
+	// getting the profile the skill is added to
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	skill := &data.Skill{AuthorID: author.ID}
+
+	// checking the data from the user
+	form, err := app.skill(r, skill)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", "Invalid skill: "+fieldErrorsMessage(&form.Validator))
+		http.Redirect(w, r, "/author#skills", http.StatusSeeOther)
+		return
+	}
+
+	// recording the skill
+	err = app.models.AuthorModel.InsertSkill(skill)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSkillName):
+			app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Invalid skill: %q already exists", skill.Name))
+			http.Redirect(w, r, "/author#skills", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.touchProfile(author.ID)
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%q has been added!", skill.Name))
+	http.Redirect(w, r, "/author#skills", http.StatusSeeOther)
+}
+
+func (app *application) updateSkill(w http.ResponseWriter, r *http.Request) {
+
+	// getting the skill
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+	skill, err := app.models.AuthorModel.GetSkill(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// checking that the skill belongs to the profile of the user
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	if skill.AuthorID != author.ID {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// checking the data from the user
+	form, err := app.skill(r, skill)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", "Invalid skill: "+fieldErrorsMessage(&form.Validator))
+		http.Redirect(w, r, "/author#skills", http.StatusSeeOther)
+		return
+	}
+
+	// saving the changes
+	err = app.models.AuthorModel.UpdateSkill(skill)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		case errors.Is(err, data.ErrDuplicateSkillName):
+			app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Invalid skill: %q already exists", skill.Name))
+			http.Redirect(w, r, "/author#skills", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.touchProfile(author.ID)
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%q has been updated!", skill.Name))
+	http.Redirect(w, r, "/author#skills", http.StatusSeeOther)
+}
+
+func (app *application) deleteSkill(w http.ResponseWriter, r *http.Request) {
+
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// deleting the skill from the profile of the user
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	err = app.models.AuthorModel.DeleteSkill(id, author.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.touchProfile(author.ID)
+
+	app.sessionManager.Put(r.Context(), "flash", "The skill has been deleted!")
+	http.Redirect(w, r, "/author#skills", http.StatusSeeOther)
+}
+
+func (app *application) reviewTestimonial(w http.ResponseWriter, r *http.Request) {
+
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	status := r.PostForm.Get("status")
+
+	// approving or rejecting the testimonial of the profile of the user
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	err = app.models.TestimonialModel.Review(id, author.ID, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("The testimonial has been %s!", status))
+	http.Redirect(w, r, "/dashboard#testimonials", http.StatusSeeOther)
+}
+
+func (app *application) deleteTestimonial(w http.ResponseWriter, r *http.Request) {
+
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// deleting the testimonial from the profile of the user
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	err = app.models.TestimonialModel.Delete(id, author.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The testimonial has been deleted!")
+	http.Redirect(w, r, "/dashboard#testimonials", http.StatusSeeOther)
+}
+
+func (app *application) availability(w http.ResponseWriter, r *http.Request) {
+
+	// getting the schedule of the profile of the user
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	availabilities, err := app.models.BookingModel.GetAvailabilities(author.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	blackouts, err := app.models.BookingModel.GetBlackouts(author.ID, time.Now().In(app.config.booking.location))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = author.Name + " - Availability"
+	tmplData.Author = author
+	tmplData.Schedule = &data.Schedule{Availabilities: availabilities, Blackouts: blackouts}
+	tmplData.Timezone = app.config.booking.timezone
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "availability.tmpl", tmplData)
+}
+
+func (app *application) createAvailability(w http.ResponseWriter, r *http.Request) {
+
+	// getting the profile the availability is added to
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+
+	// retrieving the form data
+	form := availabilityForm{Validator: *validator.New()}
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// checking the data from the user
+	availability := &data.Availability{AuthorID: author.ID, Weekday: time.Weekday(form.Weekday)}
+	availability.Start, err = data.ParseClockTime(form.Start)
+	form.Check(err == nil, "start", "invalid time")
+	availability.End, err = data.ParseClockTime(form.End)
+	form.Check(err == nil, "end", "invalid time")
+	if form.Valid() {
+		availability.Validate(&form.Validator)
+	}
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", "Invalid availability: "+fieldErrorsMessage(&form.Validator))
+		http.Redirect(w, r, "/availability", http.StatusSeeOther)
+		return
+	}
+
+	// recording the availability
+	err = app.models.BookingModel.InsertAvailability(availability)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s %s has been added!", availability.Weekday, availability.Period()))
+	http.Redirect(w, r, "/availability", http.StatusSeeOther)
+}
+
+func (app *application) deleteAvailability(w http.ResponseWriter, r *http.Request) {
+
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// deleting the availability from the profile of the user
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	err = app.models.BookingModel.DeleteAvailability(id, author.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The availability has been deleted!")
+	http.Redirect(w, r, "/availability", http.StatusSeeOther)
+}
+
+func (app *application) createBlackout(w http.ResponseWriter, r *http.Request) {
+
+	// getting the profile the blackout date is added to
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+
+	// retrieving the form data
+	form := blackoutForm{Validator: *validator.New()}
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// checking the data from the user (an invalid day being left unset)
+	day, _ := time.Parse(time.DateOnly, form.Day)
+	blackout := &data.Blackout{AuthorID: author.ID, Day: day, Reason: strings.TrimSpace(form.Reason)}
+	blackout.Validate(&form.Validator)
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", "Invalid blackout date: "+fieldErrorsMessage(&form.Validator))
+		http.Redirect(w, r, "/availability#blackouts", http.StatusSeeOther)
+		return
+	}
+
+	// recording the blackout date
+	err = app.models.BookingModel.InsertBlackout(blackout)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateBlackout):
+			app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Invalid blackout date: %s is already blocked", form.Day))
+			http.Redirect(w, r, "/availability#blackouts", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s has been blocked!", blackout.Day.Format("Monday 02 January 2006")))
+	http.Redirect(w, r, "/availability#blackouts", http.StatusSeeOther)
+}
+
+func (app *application) deleteBlackout(w http.ResponseWriter, r *http.Request) {
+
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// deleting the blackout date from the profile of the user
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	err = app.models.BookingModel.DeleteBlackout(id, author.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The blackout date has been deleted!")
+	http.Redirect(w, r, "/availability#blackouts", http.StatusSeeOther)
+}
+
+func (app *application) authorCancelBooking(w http.ResponseWriter, r *http.Request) {
+
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// getting the booking of the profile of the user
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+	booking, err := app.models.BookingModel.GetByID(id, author.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// cancelling the booking
+	err = app.models.BookingModel.Cancel(booking, author.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrBookingNotModified):
+			app.sessionManager.Put(r.Context(), "flash", "The meeting has already been cancelled.")
+			http.Redirect(w, r, "/dashboard#bookings", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// sending the cancellations
+	app.mailBooking(author, booking, "")
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("The meeting with %s has been cancelled!", booking.Name))
+	http.Redirect(w, r, "/dashboard#bookings", http.StatusSeeOther)
+}
+
+func (app *application) updateUser(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving user ID
+	id := app.getUserID(r)
+
+	// fetching user data
+	user, err := app.models.UserModel.GetByID(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// filling the form with user values and rendering the template
+	app.renderUserPage(w, r, http.StatusOK, user, newUserUpdateForm(user))
+}
+
+func (app *application) updateUserPost(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	form := newUserUpdateForm(nil)
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// fetching the authenticated user
+	user, err := app.models.UserModel.GetByID(app.getUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// checking the data from the user
+	var isEmpty = true
+	if form.Username != nil {
+		isEmpty = false
+		form.StringCheck(*form.Username, 2, 70, false, "username")
+		user.Name = *form.Username
+	}
+	if form.Password != nil || form.NewPassword != nil || form.ConfirmationPassword != nil {
+		isEmpty = false
+		form.ValidateNewPassword(*form.NewPassword, *form.ConfirmationPassword)
+		err = user.Password.Set(*form.NewPassword)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if form.Email != nil {
+		isEmpty = false
+		form.ValidateEmail(*form.Email)
+		user.Email = *form.Email
+	}
+	if isEmpty {
+		form.AddNonFieldError("at least one field is required")
+	}
+
+	// return to update-user page if there is an error
+	if !form.Valid() {
+		app.renderUserPage(w, r, http.StatusUnprocessableEntity, user, form)
+		return
+	}
+
+	// updating the user
+	err = app.models.UserModel.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		case errors.Is(err, data.ErrDuplicateEmail):
+			form.AddFieldError("email", "email is already in use")
+			app.renderUserPage(w, r, http.StatusUnprocessableEntity, user, form)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your data has been updated successfully!")
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+func (app *application) setupTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	// generating the secret the user enrolls their authenticator app with
+	secret, err := totp.NewSecret()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.models.UserModel.SetTOTPSecret(app.getUserID(r), secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.sessionManager.Put(r.Context(), "flash", "The two-factor authentication is already enabled.")
+			http.Redirect(w, r, "/user#two-factor", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/user#two-factor", http.StatusSeeOther)
+}
+
+func (app *application) enableTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	form := twoFactorForm{Validator: *validator.New()}
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// fetching the authenticated user
+	user, err := app.models.UserModel.GetByID(app.getUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if user.TOTPEnabled() || user.TOTPSecret == nil {
+		http.Redirect(w, r, "/user#two-factor", http.StatusSeeOther)
+		return
+	}
+
+	// checking that the authenticator app is enrolled with the code it shows
+	counter, ok := totp.Validate(user.TOTPSecret, form.Code, time.Now())
+	if !ok {
+		app.sessionManager.Put(r.Context(), "flash", "Invalid code, please type the one your authenticator app shows now.")
+		http.Redirect(w, r, "/user#two-factor", http.StatusSeeOther)
+		return
+	}
+
+	// enabling the two-factor authentication with its recovery codes, shown once
+	err = app.models.UserModel.EnableTOTP(user, counter)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			http.Redirect(w, r, "/user#two-factor", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	codes, err := app.models.RecoveryCodeModel.New(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), recoveryCodesSessionManager, codes)
+
+	app.sessionManager.Put(r.Context(), "flash", "The two-factor authentication is enabled! Keep your recovery codes somewhere safe.")
+	http.Redirect(w, r, "/user#two-factor", http.StatusSeeOther)
+}
+
+// twoFactorPassword reports whether the password typed to manage the enabled two-factor authentication is the one
+// of the authenticated user, sending them back to the user page otherwise (false being returned once the response is written)
+func (app *application) twoFactorPassword(w http.ResponseWriter, r *http.Request, user *data.User) bool {
+
+	// retrieving the form data
+	form := twoFactorForm{Validator: *validator.New()}
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return false
+	}
+
+	// matching the password
+	match, err := user.Password.Matches(form.Password)
+	if err != nil {
+		app.serverError(w, r, err)
+		return false
+	}
+	if !match {
+		app.sessionManager.Put(r.Context(), "flash", "Invalid password.")
+		http.Redirect(w, r, "/user#two-factor", http.StatusSeeOther)
+		return false
+	}
+
+	return true
+}
+
+func (app *application) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the authenticated user
+	user, err := app.models.UserModel.GetByID(app.getUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// the pending enrollment being cancelled without the password
+	if user.TOTPEnabled() && !app.twoFactorPassword(w, r, user) {
+		return
+	}
+
+	// disabling the two-factor authentication (the trusted devices being forgotten with it)
+	err = app.models.UserModel.DisableTOTP(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if user.TOTPEnabled() {
+		app.sessionManager.Put(r.Context(), "flash", "The two-factor authentication is disabled.")
+	}
+	http.Redirect(w, r, "/user#two-factor", http.StatusSeeOther)
+}
+
+func (app *application) newRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+
+	// fetching the authenticated user
+	user, err := app.models.UserModel.GetByID(app.getUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !user.TOTPEnabled() {
+		http.Redirect(w, r, "/user#two-factor", http.StatusSeeOther)
+		return
+	}
+	if !app.twoFactorPassword(w, r, user) {
+		return
+	}
+
+	// replacing the recovery codes, shown once
+	codes, err := app.models.RecoveryCodeModel.New(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), recoveryCodesSessionManager, codes)
+
+	app.sessionManager.Put(r.Context(), "flash", "Your recovery codes have been replaced! Keep the new ones somewhere safe.")
+	http.Redirect(w, r, "/user#two-factor", http.StatusSeeOther)
+}
+
+func (app *application) passkeyRegistrationOptions(w http.ResponseWriter, r *http.Request) {
+
+	// checking that the request is sent to the site the passkeys are registered for
+	rp, err := app.relyingParty(r)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// fetching the authenticated user and their passkeys, not to register them twice
+	user, err := app.models.UserModel.GetByID(app.getUserID(r))
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	passkeys, err := app.models.PasskeyModel.GetForUser(user.ID)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	exclude := make([][]byte, len(passkeys))
+	for i, passkey := range passkeys {
+		exclude[i] = passkey.CredentialID
+	}
+
+	// generating the challenge the new passkey signs
+	challenge, err := app.newPasskeyChallenge(r, passkeyRegistrationSessionManager)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	account := webauthn.User{ID: passkeyUserHandle(user.ID), Name: user.Email, DisplayName: user.Name}
+	app.writeJSON(w, http.StatusOK, envelope{"publicKey": rp.CreationOptions(challenge, account, exclude)})
+}
+
+func (app *application) createPasskey(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the passkey and its name
+	var form passkeyForm
+	err := app.readJSON(w, r, &form)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// checking the name
+	passkey := &data.Passkey{
+		UserID: app.getUserID(r),
+		Name:   strings.TrimSpace(form.Name),
+	}
+	v := validator.New()
+	if passkey.Validate(v); !v.Valid() {
+		app.ajaxResponse(w, http.StatusUnprocessableEntity, fieldErrorsMessage(v))
+		return
+	}
+
+	// checking the response of the authenticator to the challenge of the ceremony
+	challenge := app.passkeyChallenge(r, passkeyRegistrationSessionManager)
+	if challenge == nil {
+		app.ajaxResponse(w, http.StatusBadRequest, "the registration timed out, please try again")
+		return
+	}
+	rp, err := app.relyingParty(r)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	credential, err := rp.VerifyRegistration(challenge, form.Credential)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	passkey.CredentialID = credential.ID
+	passkey.PublicKey = credential.PublicKey
+	passkey.SignCount = credential.SignCount
+
+	// recording the passkey
+	err = app.models.PasskeyModel.Insert(passkey)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicatePasskey):
+			app.ajaxResponse(w, http.StatusConflict, "this passkey is already registered")
+		default:
+			app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("The passkey %s has been registered!", passkey.Name))
+	app.ajaxResponse(w, http.StatusCreated, "passkey registered")
+}
+
+func (app *application) deletePasskey(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the passkey ID
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// removing the passkey of the authenticated user
+	err = app.models.PasskeyModel.Delete(id, app.getUserID(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The passkey has been removed.")
+	http.Redirect(w, r, "/user#passkeys", http.StatusSeeOther)
+}
+
+func (app *application) revokeSession(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the session ID
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// logging the session out, unless it's the current one
+	err = app.models.SessionModel.Revoke(id, app.getUserID(r), app.sessionManager.Token(r.Context()))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.sessionManager.Put(r.Context(), "flash", "This session has already ended.")
+			http.Redirect(w, r, "/user#sessions", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The session has been logged out!")
+	http.Redirect(w, r, "/user#sessions", http.StatusSeeOther)
+}
+
+func (app *application) revokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+
+	// logging out all the sessions of the user but the current one
+	revoked, err := app.revokeSessions(r, app.getUserID(r), app.sessionManager.Token(r.Context()), false)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if revoked == 0 {
+		app.sessionManager.Put(r.Context(), "flash", "There was no other session to log out.")
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "All your other sessions have been logged out!")
+	}
+	http.Redirect(w, r, "/user#sessions", http.StatusSeeOther)
+}
+
+func (app *application) createPost(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Create Post"
+
+	// filling the form with empty values
+	tmplData.Form = newPostForm(nil)
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "post-form.tmpl", tmplData)
+}
+
+func (app *application) createPostPost(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	form := newPostForm(nil)
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		// DEBUG
+		app.logger.Debug(fmt.Errorf("decoding postForm error: %w", err).Error())
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// DEBUG
+	app.logger.Debug(fmt.Sprintf("form: %+v", *form))
+
+	// getting the profile of the user, the author of the post
+	author := app.userProfile(w, r)
+	if author == nil {
+		return
+	}
+
+	// creating the new thread
+	post := &data.Post{AuthorID: author.ID}
+
+	// checking the data from the user
+	form.StringCheck(form.Content, 2, 10_000, true, "content")
+	post.Content = []byte(form.Content)
+	if form.Title == nil {
+		form.AddFieldError("title", "must be provided")
+	} else {
+		form.StringCheck(*form.Title, 2, 120, true, "title")
+		post.Title = *form.Title
+	}
+	if form.Images == nil {
+		form.AddFieldError("images", "must be provided")
+	} else {
+		post.Images = form.Images
+	}
+
+	// return to post-create page if there is an error
+	if !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "post-form.tmpl")
+		return
+	}
+
+	// creating the post
+	err = app.models.PostModel.Insert(post)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicatePostTitle):
+			form.AddFieldError("title", "is already in use")
+			app.failedValidationError(w, r, form, &form.Validator, "post-form.tmpl")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Post created successfully!")
+	http.Redirect(w, r, fmt.Sprintf("/post/%d", post.ID), http.StatusSeeOther)
+}
+
+func (app *application) updatePost(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Update post"
+
+	// retrieving the post id from the path
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// retrieving the post from the API
+	post, err := app.models.PostModel.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// checking that the user is the author of the post or can edit those of the other authors
+	if !app.canEditPost(w, r, post) {
+		return
+	}
+
+	// inserting the post values in the TemplateData's Form
+	tmplData.Form = newPostForm(post)
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "post-form.tmpl", tmplData)
+}
+
+func (app *application) updatePostPost(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	form := newPostForm(nil)
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// DEBUG
+	app.logger.Debug(fmt.Sprintf("form: %+v", *form))
+
+	// creating the updated post
+	post := &data.Post{}
+
+	// retrieving the post id from the path
+	post.ID, err = getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// retrieving the post from the DB
+	post, err = app.models.PostModel.GetByID(post.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// checking that the user is the author of the post or can edit those of the other authors
+	if !app.canEditPost(w, r, post) {
+		return
+	}
+
+	// checking the data from the user
+	form.StringCheck(form.Content, 2, 10_000, true, "content")
+	post.Content = []byte(form.Content)
+	if form.Title != nil {
+		form.StringCheck(*form.Title, 1, 120, false, "title")
+		post.Title = *form.Title
+	}
+	if form.Images != nil {
+		form.Check(len(form.Images) < 6, "images", "limit: 5 images max")
+		post.Images = form.Images
+	}
+
+	// return to post-update page if there is an error
+	if !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "post-form.tmpl")
+		return
+	}
+
+	// API request to update a post
+	err = app.models.PostModel.Update(*post)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		case errors.Is(err, data.ErrDuplicatePostTitle):
+			form.AddFieldError("title", "title is already in use")
+			app.failedValidationError(w, r, form, &form.Validator, "post-form.tmpl")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Post updated successfully!")
+	http.Redirect(w, r, fmt.Sprintf("/post/%d", post.ID), http.StatusSeeOther)
+}
+
+// project fills project with the project form of the request, and returns the form with its validation errors
+func (app *application) project(r *http.Request, project *data.Project) (*projectForm, error) {
+
+	// retrieving the form data
+	form := newProjectForm(nil)
+	err := app.decodePostForm(r, form)
+	if err != nil {
+		return nil, err
+	}
+
+	project.Title = strings.TrimSpace(form.Title)
+	project.Summary = strings.TrimSpace(form.Summary)
+	project.Description = []byte(form.Description)
+	project.Role = strings.TrimSpace(form.Role)
+	project.RepositoryURL = strings.TrimSpace(form.RepositoryURL)
+	project.DemoURL = strings.TrimSpace(form.DemoURL)
+	project.Status = form.Status
+	project.Featured = form.Featured
+	project.Position = form.Position
+	project.Version = form.Version
+
+	// splitting the technologies and dropping the blank screenshots
+	project.TechStack = []string{}
+	for _, tech := range strings.Split(form.TechStack, ",") {
+		if tech = strings.TrimSpace(tech); tech != "" && !slices.Contains(project.TechStack, tech) {
+			project.TechStack = append(project.TechStack, tech)
+		}
+	}
+	project.Screenshots = []string{}
+	for _, screenshot := range form.Screenshots {
+		if screenshot = strings.TrimSpace(screenshot); screenshot != "" {
+			project.Screenshots = append(project.Screenshots, screenshot)
+		}
+	}
+	form.Screenshots = project.Screenshots
+
+	// reading the months of the dates
+	project.StartedOn = parseMonth(&form.Validator, form.StartedOn, "started_on")
+	project.EndedOn = parseMonth(&form.Validator, form.EndedOn, "ended_on")
+
+	project.Validate(&form.Validator)
+
+	return form, nil
+}
+
+func (app *application) createProject(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Create Project"
+
+	// filling the form with empty values
+	tmplData.Form = newProjectForm(nil)
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "project-form.tmpl", tmplData)
+}
+
+func (app *application) createProjectPost(w http.ResponseWriter, r *http.Request) {
+
+	// checking the data from the user
+	project := &data.Project{}
+	form, err := app.project(r, project)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	form.ID = 0
+
+	// return to project-create page if there is an error
+	if !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "project-form.tmpl")
+		return
+	}
+
+	// creating the project
+	err = app.models.ProjectModel.Insert(project)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateProjectTitle):
+			form.AddFieldError("title", "is already in use")
+			app.failedValidationError(w, r, form, &form.Validator, "project-form.tmpl")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Project created successfully!")
+	http.Redirect(w, r, fmt.Sprintf("/project/%d", project.ID), http.StatusSeeOther)
+}
+
+func (app *application) updateProject(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Update project"
+
+	// retrieving the project id from the path
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// retrieving the project
+	project, err := app.models.ProjectModel.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// inserting the project values in the TemplateData's Form
+	tmplData.Form = newProjectForm(project)
+
+	// rendering the template
+	app.render(w, r, http.StatusOK, "project-form.tmpl", tmplData)
+}
+
+func (app *application) updateProjectPost(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the project id from the path
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// retrieving the project from the DB
+	project, err := app.models.ProjectModel.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// checking the data from the user
+	form, err := app.project(r, project)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	form.ID = project.ID
+
+	// return to project-update page if there is an error
+	if !form.Valid() {
+		app.failedValidationError(w, r, form, &form.Validator, "project-form.tmpl")
+		return
+	}
+
+	// saving the changes
+	err = app.models.ProjectModel.Update(project)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			form.AddNonFieldError("The project has been modified in the meantime, please reload the page")
+			app.failedValidationError(w, r, form, &form.Validator, "project-form.tmpl")
+		case errors.Is(err, data.ErrDuplicateProjectTitle):
+			form.AddFieldError("title", "is already in use")
+			app.failedValidationError(w, r, form, &form.Validator, "project-form.tmpl")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Project updated successfully!")
+	http.Redirect(w, r, fmt.Sprintf("/project/%d", project.ID), http.StatusSeeOther)
+}
+
+func (app *application) deleteProject(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the project id from the path
+	id, err := getPathID(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// deleting the project (its screenshots stay in the uploads)
+	err = app.models.ProjectModel.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Project deleted successfully!")
+	http.Redirect(w, r, "/projects", http.StatusSeeOther)
+}
+
+/* #############################################################################
+/*	AJAX CALLS
+/* #############################################################################*/
+
+func (app *application) postIncrementView(w http.ResponseWriter, r *http.Request) {
+
+	// fetch post id
+	id, err := getPathID(r)
+	if err != nil {
+		// send the error back in JSON
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// increment post view
+	err = app.models.PostModel.IncrementViews(id)
+	if err != nil {
+		// send the error back in JSON
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// send the positive response back in JSON
+	app.ajaxResponse(w, http.StatusOK, "post view incremented successfully!")
+}
+
+func (app *application) uploadFile(w http.ResponseWriter, r *http.Request) {
+
+	// limiting the size of the whole request
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.uploads.maxRequestSize)
+
+	// getting the file from the form
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.ajaxResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request too large: the limit is %d bytes", maxBytesError.Limit))
+		case errors.Is(err, http.ErrMissingFile):
+			app.ajaxResponse(w, http.StatusBadRequest, "no file in the request")
+		default:
+			app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	defer file.Close()
+
+	// uploading the file
+	upload, msg, err := uploads.Add(file, header, app.getUserID(r))
+	if err != nil {
+		app.uploadError(w, err)
+		return
+	}
+
+	// DEBUG
+	app.logger.Debug(msg)
+
+	// respond with a validation message, the file URL and its alt text
+	app.writeJSON(w, http.StatusOK, envelope{"response": msg, "path": "/" + upload.Path, "alt": upload.Alt, "caption": upload.Caption})
+}
+
+func (app *application) uploadArchive(w http.ResponseWriter, r *http.Request) {
+
+	// giving the archive more time to be received and extracted than the server timeouts allow
+	app.extendArchiveDeadlines(w)
+
+	// limiting the size of the whole request (the archive and the form fields)
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.uploads.maxArchiveSize+1<<20)
+
+	// getting the archive from the form
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.ajaxResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request too large: the limit is %d bytes", maxBytesError.Limit))
+		case errors.Is(err, http.ErrMissingFile):
+			app.ajaxResponse(w, http.StatusBadRequest, "no file in the request")
+		default:
+			app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	defer file.Close()
+
+	// extracting the files to the folder
+	entries, err := uploads.Extract(file, header.Size, r.PostFormValue("dir"), app.getUserID(r))
+	if err != nil {
+		app.uploadError(w, err)
+		return
+	}
+
+	// counting the rejected files
+	var rejected int
+	for _, entry := range entries {
+		if entry.Error != "" {
+			rejected++
+		}
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"response": fmt.Sprintf("%d files extracted, %d rejected", len(entries)-rejected, rejected), "entries": entries})
+}
+
+func (app *application) downloadFolder(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the unescaped path for the directory
+	dir := strings.ReplaceAll(flow.Param(r.Context(), "dir"), "|2F", "/")
+
+	// checking the folder before sending the archive headers
+	folders, err := uploads.Folders()
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !validator.PermittedValue(dir, folders...) {
+		app.ajaxResponse(w, http.StatusNotFound, uploads.ErrForbiddenDirectory.Error())
+		return
+	}
+
+	// streaming the archive, with more time than the server timeouts allow
+	app.extendArchiveDeadlines(w)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(dir) + ".zip"}))
+	w.Header().Set("Cache-Control", "no-store")
+
+	err = uploads.WriteArchive(w, dir)
+	if err != nil {
+		// the archive is already partly sent
+		app.logger.Error(fmt.Errorf("error archiving %s: %w", dir, err).Error())
+	}
+}
+
+func (app *application) deleteFile(w http.ResponseWriter, r *http.Request) {
+
+	// getting directory and file names
+	dir := flow.Param(r.Context(), "dir")
+	file := flow.Param(r.Context(), "file")
+
+	// retrieving the unescaped path for the directory
+	dir = strings.ReplaceAll(dir, "|2F", "/")
+
+	// moving the file to the trash, even if posts use it when forced
+	force := r.URL.Query().Get("force") == "true"
+	err := uploads.Remove(filepath.Join(dir, file), app.getUserID(r), force)
+	if err != nil {
+		app.uploadError(w, err)
+		return
+	}
+
+	// sending the positive response
+	app.ajaxResponse(w, http.StatusOK, fmt.Sprintf("file %s moved from %s to the trash!", file, dir))
+}
+
+func (app *application) getFiles(w http.ResponseWriter, r *http.Request) {
+
+	// setting the Browser variable
+	var browser uploads.Browser
+
+	// getting directory name
+	browser.Dirname = flow.Param(r.Context(), "dir")
+
+	// retrieving the unescaped path for the directory
+	var err error
+	if browser.Dirname != "" {
+		browser.Dirname = strings.ReplaceAll(browser.Dirname, "|2F", "/")
+	}
+
+	// only the users managing the files see the private folder
+	if uploads.IsPrivate(browser.Dirname) && !app.contextUser(r).Can(data.PermissionFiles) {
+		app.ajaxResponse(w, http.StatusForbidden, "you are not allowed to do this")
+		return
+	}
+
+	// getting the search, type and sort filters
+	query := r.URL.Query()
+	browser.Search = strings.TrimSpace(query.Get("q"))
+	browser.Type = query.Get("type")
+	filters := data.NewUploadFilters(query)
+	browser.Sort = filters.Sort
+
+	v := validator.New()
+	if data.ValidateFilters(v, *filters); !v.Valid() {
+		app.ajaxResponse(w, http.StatusBadRequest, fieldErrorsMessage(v))
+		return
+	}
+	if browser.Type != "" {
+		if _, ok := uploads.FileTypes[browser.Type]; !ok {
+			app.ajaxResponse(w, http.StatusBadRequest, fmt.Sprintf("unknown file type %q", browser.Type))
+			return
+		}
+	}
+
+	// getting the files
+	browser.Files, err = uploads.Get(browser.Dirname, browser.Search, browser.Type, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, uploads.ErrForbiddenDirectory):
+			app.ajaxResponse(w, http.StatusNotFound, err.Error())
+		default:
+			app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	// getting the destinations of the move actions
+	browser.Folders, err = uploads.Folders()
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// parsing the template for the file browser
+	err = uploads.Render(w, browser)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (app *application) updateFileMetadata(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	var form uploadMetadataForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// checking the data from the user
+	upload := &data.Upload{
+		Path:    strings.TrimPrefix(form.Path, "/"),
+		Alt:     strings.TrimSpace(form.Alt),
+		Caption: strings.TrimSpace(form.Caption),
+	}
+	v := validator.New()
+	if upload.Validate(v); !v.Valid() {
+		app.ajaxResponse(w, http.StatusUnprocessableEntity, fieldErrorsMessage(v))
+		return
+	}
+
+	// updating the metadata
+	err = app.models.UploadModel.UpdateMetadata(upload)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.ajaxResponse(w, http.StatusNotFound, uploads.ErrFileNotFound.Error())
+		default:
+			app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	app.ajaxResponse(w, http.StatusOK, fmt.Sprintf("metadata of %s updated", upload.Path))
+}
+
+func (app *application) moveFile(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	var form folderForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// moving the file
+	file, err := uploads.Move(strings.TrimPrefix(form.Path, "/"), form.Parent)
+	if err != nil {
+		app.uploadError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"response": fmt.Sprintf("file moved to %s", file), "path": file})
+}
+
+func (app *application) signFile(w http.ResponseWriter, r *http.Request) {
+
+	// retrieving the form data
+	var form signLinkForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	// return to update-user page if there is an error
+	// checking the lifetime of the link
+	ttl, err := time.ParseDuration(form.TTL)
+	form.Check(err == nil && ttl > 0, "ttl", "must be a positive duration")
+	form.Check(ttl <= uploads.MaxLinkLifetime, "ttl", fmt.Sprintf("must not exceed %s", uploads.MaxLinkLifetime))
 	if !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "user-update.tmpl")
+		app.ajaxResponse(w, http.StatusUnprocessableEntity, form.FieldErrors["ttl"])
 		return
 	}
 
-	// updating the user
-	err = app.models.UserModel.Update(user)
+	// signing the link
+	link, expires, err := uploads.SignURL(strings.TrimPrefix(form.Path, "/"), ttl)
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.clientError(w, r, http.StatusNotFound)
-		case errors.Is(err, data.ErrDuplicateEmail):
-			form.AddFieldError("email", "email is already in use")
-			app.failedValidationError(w, r, form, &form.Validator, "user-update.tmpl")
-		default:
-			app.serverError(w, r, err)
-		}
+		app.uploadError(w, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your data has been updated successfully!")
-	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	app.writeJSON(w, http.StatusOK, envelope{"response": fmt.Sprintf("link valid until %s", expires.Format("2006-01-02 15:04")), "url": link, "expires": expires})
 }
 
-func (app *application) createPost(w http.ResponseWriter, r *http.Request) {
+func (app *application) createFolder(w http.ResponseWriter, r *http.Request) {
 
-	// retrieving basic template data
-	tmplData := app.newTemplateData(r)
-	tmplData.Title = "Antoine de Barbarin - Create Post"
+	// retrieving the form data
+	var form folderForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	// filling the form with empty values
-	tmplData.Form = newPostForm(nil)
+	// creating the folder
+	dir, err := uploads.CreateFolder(form.Parent, strings.TrimSpace(form.Name))
+	if err != nil {
+		app.uploadError(w, err)
+		return
+	}
 
-	// rendering the template
-	app.render(w, r, http.StatusOK, "post-form.tmpl", tmplData)
+	app.writeJSON(w, http.StatusOK, envelope{"response": fmt.Sprintf("folder %s created", dir), "path": dir})
 }
 
-func (app *application) createPostPost(w http.ResponseWriter, r *http.Request) {
+func (app *application) renameFolder(w http.ResponseWriter, r *http.Request) {
 
 	// retrieving the form data
-	form := newPostForm(nil)
+	var form folderForm
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		// DEBUG
-		app.logger.Debug(fmt.Errorf("decoding postForm error: %w", err).Error())
-		app.clientError(w, r, http.StatusBadRequest)
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// DEBUG
-	app.logger.Debug(fmt.Sprintf("form: %+v", *form))
+	// renaming the folder
+	dir, err := uploads.RenameFolder(form.Path, strings.TrimSpace(form.Name))
+	if err != nil {
+		app.uploadError(w, err)
+		return
+	}
 
-	// creating the new thread
-	post := &data.Post{}
+	app.writeJSON(w, http.StatusOK, envelope{"response": fmt.Sprintf("folder renamed to %s", dir), "path": dir})
+}
 
-	// checking the data from the user
-	form.StringCheck(form.Content, 2, 10_000, true, "content")
-	post.Content = []byte(form.Content)
-	if form.Title == nil {
-		form.AddFieldError("title", "must be provided")
-	} else {
-		form.StringCheck(*form.Title, 2, 120, true, "title")
-		post.Title = *form.Title
-	}
-	if form.Images == nil {
-		form.AddFieldError("images", "must be provided")
-	} else {
-		post.Images = form.Images
-	}
+func (app *application) moveFolder(w http.ResponseWriter, r *http.Request) {
 
-	// return to post-create page if there is an error
-	if !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "post-form.tmpl")
+	// retrieving the form data
+	var form folderForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// creating the post
-	err = app.models.PostModel.Insert(post)
+	// moving the folder
+	dir, err := uploads.MoveFolder(form.Path, form.Parent)
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrDuplicatePostTitle):
-			form.AddFieldError("title", "is already in use")
-			app.failedValidationError(w, r, form, &form.Validator, "post-form.tmpl")
-		default:
-			app.serverError(w, r, err)
-		}
+		app.uploadError(w, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Post created successfully!")
-	http.Redirect(w, r, fmt.Sprintf("/post/%d", post.ID), http.StatusSeeOther)
+	app.writeJSON(w, http.StatusOK, envelope{"response": fmt.Sprintf("folder moved to %s", dir), "path": dir})
 }
 
-func (app *application) updatePost(w http.ResponseWriter, r *http.Request) {
-
-	// retrieving basic template data
-	tmplData := app.newTemplateData(r)
-	tmplData.Title = "Antoine de Barbarin - Update post"
+func (app *application) deleteFolder(w http.ResponseWriter, r *http.Request) {
 
-	// retrieving the post id from the path
-	id, err := getPathID(r)
+	// retrieving the form data
+	var form folderForm
+	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, r, http.StatusBadRequest)
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// retrieving the post from the API
-	post, err := app.models.PostModel.GetByID(id)
+	// deleting the folder if it is empty
+	err = uploads.DeleteFolder(form.Path)
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.clientError(w, r, http.StatusNotFound)
-		default:
-			app.serverError(w, r, err)
-		}
+		app.uploadError(w, err)
 		return
 	}
 
-	// inserting the post values in the TemplateData's Form
-	tmplData.Form = newPostForm(post)
-
-	// rendering the template
-	app.render(w, r, http.StatusOK, "post-form.tmpl", tmplData)
+	app.ajaxResponse(w, http.StatusOK, fmt.Sprintf("folder %s deleted", form.Path))
 }
 
-func (app *application) updatePostPost(w http.ResponseWriter, r *http.Request) {
+// tusVersion is the version of the tus resumable upload protocol implemented by the tus handlers
+const tusVersion = "1.0.0"
 
-	// retrieving the form data
-	form := newPostForm(nil)
-	err := app.decodePostForm(r, &form)
-	if err != nil {
-		app.clientError(w, r, http.StatusBadRequest)
-		return
+// tusCheck sets the protocol header of the response, and checks the version requested by the client
+func (app *application) tusCheck(w http.ResponseWriter, r *http.Request) bool {
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		app.ajaxResponse(w, http.StatusPreconditionFailed, "unsupported tus version")
+		return false
 	}
 
-	// DEBUG
-	app.logger.Debug(fmt.Sprintf("form: %+v", *form))
+	return true
+}
 
-	// creating the updated post
-	post := &data.Post{}
+func (app *application) tusOptions(w http.ResponseWriter, r *http.Request) {
 
-	// retrieving the post id from the path
-	post.ID, err = getPathID(r)
-	if err != nil {
-		app.clientError(w, r, http.StatusBadRequest)
+	// describing the protocol support
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", "creation,expiration")
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(app.config.uploads.maxFileSize, 10))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) tusCreate(w http.ResponseWriter, r *http.Request) {
+
+	if !app.tusCheck(w, r) {
 		return
 	}
 
-	// retrieving the post from the DB
-	post, err = app.models.PostModel.GetByID(post.ID)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.clientError(w, r, http.StatusNotFound)
-		default:
-			app.serverError(w, r, err)
-		}
+	// getting the size of the upload
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		app.ajaxResponse(w, http.StatusBadRequest, "invalid Upload-Length header")
 		return
 	}
 
-	// checking the data from the user
-	form.StringCheck(form.Content, 2, 10_000, true, "content")
-	post.Content = []byte(form.Content)
-	if form.Title != nil {
-		form.StringCheck(*form.Title, 1, 120, false, "title")
-		post.Title = *form.Title
+	// getting the file name from the metadata (comma-separated keys with their base64 value)
+	var filename string
+	for _, pair := range strings.Split(r.Header.Get("Upload-Metadata"), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key != "filename" {
+			continue
+		}
+		name, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			app.ajaxResponse(w, http.StatusBadRequest, "invalid Upload-Metadata header")
+			return
+		}
+		filename = string(name)
 	}
-	if form.Images != nil {
-		form.Check(len(form.Images) < 6, "images", "limit: 5 images max")
-		post.Images = form.Images
+
+	// staging the upload
+	resumable, err := uploads.CreateResumable(length, filename, app.getUserID(r))
+	if err != nil {
+		app.uploadError(w, err)
+		return
 	}
 
-	// return to post-update page if there is an error
-	if !form.Valid() {
-		app.failedValidationError(w, r, form, &form.Validator, "post-form.tmpl")
+	w.Header().Set("Location", "/upload/tus/"+resumable.ID)
+	w.Header().Set("Upload-Expires", resumable.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (app *application) tusHead(w http.ResponseWriter, r *http.Request) {
+
+	if !app.tusCheck(w, r) {
 		return
 	}
 
-	// API request to update a post
-	err = app.models.PostModel.Update(*post)
+	// getting the staged upload
+	resumable, err := uploads.GetResumable(flow.Param(r.Context(), "id"), app.getUserID(r))
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.clientError(w, r, http.StatusNotFound)
-		case errors.Is(err, data.ErrDuplicatePostTitle):
-			form.AddFieldError("title", "title is already in use")
-			app.failedValidationError(w, r, form, &form.Validator, "post-form.tmpl")
-		default:
-			app.serverError(w, r, err)
-		}
+		app.uploadError(w, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Post updated successfully!")
-	http.Redirect(w, r, fmt.Sprintf("/post/%d", post.ID), http.StatusSeeOther)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(resumable.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(resumable.Length, 10))
+	w.Header().Set("Upload-Expires", resumable.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
 }
 
-/* #############################################################################
-/*	AJAX CALLS
-/* #############################################################################*/
+func (app *application) tusPatch(w http.ResponseWriter, r *http.Request) {
 
-func (app *application) postIncrementView(w http.ResponseWriter, r *http.Request) {
+	if !app.tusCheck(w, r) {
+		return
+	}
 
-	// fetch post id
-	id, err := getPathID(r)
+	// checking the chunk headers
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		app.ajaxResponse(w, http.StatusUnsupportedMediaType, "the chunks must be sent as application/offset+octet-stream")
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		app.ajaxResponse(w, http.StatusBadRequest, "invalid Upload-Offset header")
+		return
+	}
+
+	// getting the staged upload
+	resumable, err := uploads.GetResumable(flow.Param(r.Context(), "id"), app.getUserID(r))
 	if err != nil {
-		// send the error back in JSON
-		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		app.uploadError(w, err)
 		return
 	}
 
-	// increment post view
-	err = app.models.PostModel.IncrementViews(id)
+	// giving the chunk more time than the server timeouts allow, the last one being saved to the storage too
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(app.config.uploads.chunkTimeout)
+	if err = rc.SetReadDeadline(deadline); err == nil {
+		err = rc.SetWriteDeadline(deadline.Add(app.config.uploads.chunkTimeout))
+	}
 	if err != nil {
-		// send the error back in JSON
-		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		app.logger.Warn("resumable upload: the chunk deadline can't be extended", "error", err.Error())
+	}
+
+	// writing the chunk (the received bytes being kept if the transfer is interrupted)
+	err = resumable.Write(offset, r.Body)
+	if err != nil {
+		app.uploadError(w, err)
 		return
 	}
 
-	// send the positive response back in JSON
-	app.ajaxResponse(w, http.StatusOK, "post view incremented successfully!")
+	if resumable.Upload != nil {
+		app.logger.Debug(fmt.Sprintf("resumable upload %s saved to %s", resumable.ID, resumable.Upload.Path))
+		w.Header().Set("Upload-Path", url.PathEscape("/"+resumable.Upload.Path))
+	} else {
+		w.Header().Set("Upload-Expires", resumable.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(resumable.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (app *application) uploadFile(w http.ResponseWriter, r *http.Request) {
+func (app *application) getTrash(w http.ResponseWriter, r *http.Request) {
 
-	// getting the file from the form
-	file, header, err := r.FormFile("file")
+	// getting the trashed files
+	files, err := uploads.Trash()
 	if err != nil {
 		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	defer file.Close()
 
-	// uploading the file
-	msg, err := uploads.Add(file, header)
+	// parsing the template for the file browser
+	err = uploads.Render(w, uploads.Browser{Dirname: "trash", Files: files, Trash: true})
 	if err != nil {
 		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
-		return
 	}
-
-	// DEBUG
-	app.logger.Debug(msg)
-
-	// respond with a validation message and the image name
-	app.ajaxResponse(w, http.StatusOK, msg)
 }
 
-func (app *application) deleteFile(w http.ResponseWriter, r *http.Request) {
-
-	// getting directory and file names
-	dir := flow.Param(r.Context(), "dir")
-	file := flow.Param(r.Context(), "file")
+func (app *application) getOrphans(w http.ResponseWriter, r *http.Request) {
 
-	// retrieving the unescaped path for the directory
-	var err error
-	if dir != "" {
-		dir, err = url.PathUnescape(dir)
-		if err != nil {
-			app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
-			return
-		}
+	// getting the files no post nor project uses
+	files, err := uploads.Orphans()
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	// deleting the file
-	err = uploads.Remove(filepath.Join(dir, file))
+	// getting the destinations of the move actions
+	folders, err := uploads.Folders()
 	if err != nil {
 		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// sending the positive response
-	app.ajaxResponse(w, http.StatusOK, fmt.Sprintf("file %s successfully deleted from %s!", file, dir))
+	// parsing the template for the file browser
+	err = uploads.Render(w, uploads.Browser{Dirname: "orphans", Files: files, Folders: folders, Orphans: true})
+	if err != nil {
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+	}
 }
 
-func (app *application) getFiles(w http.ResponseWriter, r *http.Request) {
-
-	// setting the Browser variable
-	var browser uploads.Browser
-
-	// getting directory name
-	browser.Dirname = flow.Param(r.Context(), "dir")
+func (app *application) restoreFile(w http.ResponseWriter, r *http.Request) {
 
-	// retrieving the unescaped path for the directory
-	var err error
-	if browser.Dirname != "" {
-		browser.Dirname = strings.ReplaceAll(browser.Dirname, "|2F", "/")
+	// retrieving the form data
+	var form folderForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.ajaxResponse(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	// getting the files
-	browser.Files, err = uploads.Get(browser.Dirname)
+	// taking the file out of the trash
+	err = uploads.Restore(strings.TrimPrefix(form.Path, "/"))
 	if err != nil {
-		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		app.uploadError(w, err)
 		return
 	}
 
-	// parsing the template for the file browser
-	err = uploads.Render(w, browser)
+	app.ajaxResponse(w, http.StatusOK, fmt.Sprintf("file %s restored", form.Path))
+}
+
+func (app *application) emptyTrash(w http.ResponseWriter, r *http.Request) {
+
+	// purging every file of the trash
+	purged, err := uploads.PurgeTrash(time.Now())
 	if err != nil {
 		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+		return
 	}
+
+	app.ajaxResponse(w, http.StatusOK, fmt.Sprintf("%d files deleted for good", purged))
 }
@@ -2,9 +2,18 @@ package main
 
 import (
 	"Portfolio/internal/data"
+	"Portfolio/internal/ics"
+	"Portfolio/internal/mailer"
+	"Portfolio/internal/qrcode"
+	"Portfolio/internal/totp"
+	"Portfolio/internal/uploads"
 	"Portfolio/internal/validator"
+	"Portfolio/internal/webauthn"
 	"bytes"
+	"context"
 	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -12,10 +21,15 @@ import (
 	"github.com/alexedwards/flow"
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
+	"html/template"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -35,6 +49,54 @@ func (app *application) cleanExpiredTokens(frequency, timeout time.Duration) {
 	}
 }
 
+func (app *application) cleanExpiredTestimonials(frequency, timeout time.Duration) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error(fmt.Sprintf("%v", err))
+		}
+	}()
+	time.Sleep(timeout)
+	for {
+		err := app.models.TestimonialModel.DeleteExpired(testimonialTokenTTL)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+		time.Sleep(frequency)
+	}
+}
+
+func (app *application) cleanLoginAttempts(frequency, timeout time.Duration) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error(fmt.Sprintf("%v", err))
+		}
+	}()
+	time.Sleep(timeout)
+	for {
+		err := app.models.LoginAttemptModel.DeleteExpired(app.config.lockout.retention)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+		time.Sleep(frequency)
+	}
+}
+
+func (app *application) cleanExpiredSessions(frequency, timeout time.Duration) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error(fmt.Sprintf("%v", err))
+		}
+	}()
+	time.Sleep(timeout)
+	for {
+		err := app.models.SessionModel.DeleteExpired()
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+		time.Sleep(frequency)
+	}
+}
+
 func (app *application) cleanExpiredUnactivatedUsers(frequency, timeout time.Duration) {
 	defer func() {
 		if err := recover(); err != nil {
@@ -51,9 +113,755 @@ func (app *application) cleanExpiredUnactivatedUsers(frequency, timeout time.Dur
 	}
 }
 
+func (app *application) cleanExpiredUploads(frequency, timeout time.Duration) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error(fmt.Sprintf("%v", err))
+		}
+	}()
+	time.Sleep(timeout)
+	for {
+		removed, err := uploads.CleanStaging()
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+		if removed > 0 {
+			app.logger.Info("expired resumable uploads removed", "count", removed)
+		}
+		time.Sleep(frequency)
+	}
+}
+
+func (app *application) purgeTrash(frequency, retention time.Duration) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error(fmt.Sprintf("%v", err))
+		}
+	}()
+	for {
+		purged, err := uploads.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+		if purged > 0 {
+			app.logger.Info("trashed uploads purged", "count", purged)
+		}
+		time.Sleep(frequency)
+	}
+}
+
+// skillLinkChoices lists the projects and the posts of the author the skills of the author update page can link to
+func (app *application) skillLinkChoices(tmplData *templateData) error {
+
+	if tmplData.Author == nil {
+		return nil
+	}
+
+	var err error
+	tmplData.Projects.List, err = app.models.ProjectModel.Get("")
+	if err != nil {
+		return err
+	}
+	tmplData.Posts.List, err = app.models.PostModel.GetTitles(tmplData.Author.ID)
+
+	return err
+}
+
+// userProfile returns the author profile of the authenticated user, or sends them to the author page
+// to create it first (nil being returned once the response is written)
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) *data.Author {
+
+	author, err := app.models.AuthorModel.GetByUserID(app.getUserID(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.sessionManager.Put(r.Context(), "flash", "Please create your author profile first!")
+			http.Redirect(w, r, "/author", http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil
+	}
+
+	return author
+}
+
+// canEditPost reports whether the authenticated user can edit post, being its author or allowed to edit the posts
+// of the other authors, or else writes the response (false being returned)
+func (app *application) canEditPost(w http.ResponseWriter, r *http.Request, post *data.Post) bool {
+
+	if app.contextUser(r).Can(data.PermissionEditPosts) {
+		return true
+	}
+
+	author := app.userProfile(w, r)
+	if author == nil {
+		return false
+	}
+	if post.AuthorID != author.ID {
+		app.clientError(w, r, http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// profileData returns the template data of the public page of author, with their posts, their testimonials and the contact form
+func (app *application) profileData(r *http.Request, author *data.Author) templateData {
+
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = author.Name + " - Profile"
+	tmplData.Author = author
+	tmplData.IsProfileView = true
+	tmplData.Form = newContactForm()
+
+	// getting the post feed of the author
+	postFeed, err := app.models.PostModel.GetFeed(author.ID)
+	if err != nil {
+		app.logger.Error(fmt.Errorf("error getting post feed: %w", err).Error())
+	}
+	tmplData.PostFeed = data.PostFeed{}
+	if postFeed != nil {
+		tmplData.PostFeed = *postFeed
+	}
+
+	// getting the approved testimonials of the author
+	tmplData.Testimonials, err = app.models.TestimonialModel.GetApproved(author.ID)
+	if err != nil {
+		app.logger.Error(fmt.Errorf("error getting testimonials: %w", err).Error())
+	}
+
+	return tmplData
+}
+
+// exportedAuthor returns the author of the slug path parameter (the default one without slug) with the projects of their resume,
+// the errors being sent (the showcase projects being those of the default author only)
+func (app *application) exportedAuthor(w http.ResponseWriter, r *http.Request) (*data.Author, []*data.Project) {
+
+	defaultAuthor, err := app.models.AuthorModel.Get()
+	author := defaultAuthor
+	if err == nil {
+		if slug := flow.Param(r.Context(), "slug"); slug != "" {
+			author, err = app.models.AuthorModel.GetBySlug(slug)
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.clientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil, nil
+	}
+
+	if author.ID != defaultAuthor.ID {
+		return author, nil
+	}
+	projects, err := app.models.ProjectModel.Get("")
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, nil
+	}
+
+	return author, projects
+}
+
+// siteURL returns the scheme and the host of the site ("https://example.com") set at start,
+// the Host header of the requests being chosen by the clients
+func (app *application) siteURL() string {
+	return app.config.siteURL
+}
+
+// freeSlots returns the slots of the author the visitors can book, between the booking notice and the booking horizon
+func (app *application) freeSlots(author *data.Author) ([]time.Time, error) {
+
+	from := time.Now().Add(app.config.booking.notice)
+	schedule, err := app.models.BookingModel.GetSchedule(author.ID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule.FreeSlots(from, time.Now().Add(app.config.booking.horizon), app.config.booking.location, app.config.booking.duration), nil
+}
+
+// bookingData returns the template data of the booking page of author, with their free slots grouped by day
+func (app *application) bookingData(r *http.Request, author *data.Author, form *bookingForm) (templateData, error) {
+
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = author.Name + " - Book a meeting"
+	tmplData.Author = author
+	tmplData.IsProfileView = form.Author != ""
+	tmplData.Timezone = app.config.booking.timezone
+	tmplData.Form = form
+
+	slots, err := app.freeSlots(author)
+	if err != nil {
+		return tmplData, err
+	}
+	for _, slot := range slots {
+		day := time.Date(slot.Year(), slot.Month(), slot.Day(), 0, 0, 0, 0, slot.Location())
+		if n := len(tmplData.Slots); n == 0 || !tmplData.Slots[n-1].Day.Equal(day) {
+			tmplData.Slots = append(tmplData.Slots, bookingDay{Day: day})
+		}
+		tmplData.Slots[len(tmplData.Slots)-1].Slots = append(tmplData.Slots[len(tmplData.Slots)-1].Slots, slot)
+	}
+
+	return tmplData, nil
+}
+
+// mailBooking sends the invite of the booking, or its cancellation, to the visitor and to the author in the background,
+// cancelToken being the plaintext of the cancellation token of the visitor (the invites being updated if empty)
+func (app *application) mailBooking(author *data.Author, booking *data.Booking, cancelToken string) {
+
+	// the invite UID being unique across the sites thanks to their host (the site URL being checked at start)
+	site, _ := url.Parse(app.siteURL())
+
+	cancelled := booking.Status == data.BookingCancelled
+	event := &ics.Event{
+		UID:         fmt.Sprintf("booking-%d@%s", booking.ID, site.Hostname()),
+		Start:       booking.StartsAt,
+		End:         booking.EndsAt,
+		Summary:     fmt.Sprintf("Meeting between %s and %s", author.Name, booking.Name),
+		Description: booking.Message,
+		URL:         app.siteURL() + author.URL(),
+		Organizer:   ics.Person{Name: author.Name, Email: author.Email},
+		Attendee:    ics.Person{Name: booking.Name, Email: booking.Email},
+		Cancelled:   cancelled,
+	}
+	if cancelled {
+		event.Sequence = 1
+	}
+	invite := mailer.Attachment{
+		Filename:    "invite.ics",
+		ContentType: ics.ContentType(event.Method()),
+		Data:        event.Calendar(),
+	}
+
+	mailData := map[string]any{
+		"authorName":        author.Name,
+		"name":              booking.Name,
+		"email":             booking.Email,
+		"message":           booking.Message,
+		"date":              booking.StartsAt.In(app.config.booking.location).Format("Monday 02 January 2006 at 15:04"),
+		"timezone":          app.config.booking.timezone,
+		"duration":          int(booking.EndsAt.Sub(booking.StartsAt).Minutes()),
+		"cancellationToken": cancelToken,
+	}
+
+	visitorTemplate, authorTemplate := "booking_confirmation.tmpl", "booking_notification.tmpl"
+	if cancelled {
+		visitorTemplate, authorTemplate = "booking_cancellation.tmpl", "booking_cancellation.tmpl"
+	}
+
+	app.background(func() {
+
+		// the cancellation mails name the other side of the meeting
+		mailData["recipient"], mailData["with"] = booking.Name, author.Name
+		err := app.mailer.Send(booking.Email, visitorTemplate, mailData, invite)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+
+		mailData["recipient"], mailData["with"] = author.Name, booking.Name
+		err = app.mailer.Send(author.Email, authorTemplate, mailData, invite)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+}
+
+// touchProfile records that the timeline or the skills of the author authorID changed, their CV being generated again
+// on its next download, the errors being logged only
+func (app *application) touchProfile(authorID int) {
+	err := app.models.AuthorModel.Touch(authorID)
+	if err != nil {
+		app.logger.Error(err.Error())
+	}
+}
+
+// signIn stores the user userID in a renewed session once they proved who they are, and sends them to the dashboard
+func (app *application) signIn(w http.ResponseWriter, r *http.Request, userID int) {
+
+	err := app.startSession(r, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// startSession stores the user userID in a renewed session once they proved who they are
+func (app *application) startSession(r *http.Request, userID int) error {
+
+	// renewing the user session
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	// storing the user id in the user session
+	app.sessionManager.Put(r.Context(), authenticatedUserIDSessionManager, userID)
+
+	// recording the session with the device of the user
+	return app.saveSession(r, userID)
+}
+
+// touchSession records that the authenticated session of the user userID was just seen, once a minute at most
+func (app *application) touchSession(r *http.Request, userID int) error {
+
+	seen := time.Unix(app.sessionManager.GetInt64(r.Context(), sessionSeenSessionManager), 0)
+	if time.Since(seen) < sessionSeenInterval {
+		return nil
+	}
+
+	return app.saveSession(r, userID)
+}
+
+// revokeSessions ends the sessions of the user userID but the session exceptToken (all of them if it is empty),
+// with those started before the sessions were tracked, and returns how many were going on.
+// The devices trusted to skip the two-factor authentication are forgotten too when forgetDevices is set.
+func (app *application) revokeSessions(r *http.Request, userID int, exceptToken string, forgetDevices bool) (int, error) {
+
+	revoked, err := app.models.SessionModel.RevokeAll(userID, exceptToken)
+	if err != nil {
+		return 0, err
+	}
+
+	// looking for the sessions left in the store, the trusted devices being kept there too
+	err = app.sessionManager.Iterate(r.Context(), func(ctx context.Context) error {
+		if app.sessionManager.Token(ctx) == exceptToken {
+			return nil
+		}
+		switch {
+		case app.sessionManager.GetInt(ctx, authenticatedUserIDSessionManager) == userID:
+			revoked++
+		case !forgetDevices || app.sessionManager.GetInt(ctx, trustedUserIDSessionManager) != userID:
+			return nil
+		}
+		return app.sessionManager.Destroy(ctx)
+	})
+
+	return revoked, err
+}
+
+// saveSession records the session of the user userID with their device and their IP, or records that it was just seen
+func (app *application) saveSession(r *http.Request, userID int) error {
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentSize {
+		userAgent = userAgent[:maxUserAgentSize]
+	}
+
+	session := &data.Session{
+		UserID:    userID,
+		Token:     app.sessionManager.Token(r.Context()),
+		Device:    deviceName(userAgent),
+		IP:        clientIP(r),
+		UserAgent: userAgent,
+	}
+	err := app.models.SessionModel.Save(session)
+	if err != nil {
+		return err
+	}
+
+	app.sessionManager.Put(r.Context(), sessionSeenSessionManager, time.Now().Unix())
+
+	return nil
+}
+
+// deviceName describes the device of userAgent as shown on the user page ("Firefox on Linux")
+func deviceName(userAgent string) string {
+
+	browser, system := "Unknown browser", "unknown system"
+
+	// the tokens being looked for in order, the user agents of the browsers naming those they are based on
+	browsers := [][2]string{
+		{"Edg", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"}, {"Chrome/", "Chrome"}, {"Safari/", "Safari"},
+	}
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b[0]) {
+			browser = b[1]
+			break
+		}
+	}
+
+	systems := [][2]string{
+		{"Android", "Android"}, {"iPhone", "iPhone"}, {"iPad", "iPad"}, {"Windows", "Windows"},
+		{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	}
+	for _, s := range systems {
+		if strings.Contains(userAgent, s[0]) {
+			system = s[1]
+			break
+		}
+	}
+
+	return browser + " on " + system
+}
+
+// relyingParty returns the site the passkeys are registered for, from its configured URL,
+// the requests sent to another host being rejected
+func (app *application) relyingParty(r *http.Request) (webauthn.RelyingParty, error) {
+
+	site, err := url.Parse(app.siteURL())
+	if err != nil {
+		return webauthn.RelyingParty{}, err
+	}
+	if !strings.EqualFold(r.Host, site.Host) {
+		return webauthn.RelyingParty{}, fmt.Errorf("passkeys can only be used on %s", site.Host)
+	}
+
+	return webauthn.RelyingParty{ID: site.Hostname(), Name: totpIssuer, Origin: site.Scheme + "://" + site.Host}, nil
+}
+
+// passkeyUserHandle returns the user handle the passkeys of the user userID are stored with by their authenticators
+func passkeyUserHandle(userID int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+// newPasskeyChallenge generates the challenge of a passkey ceremony, kept in the session under key until the response
+func (app *application) newPasskeyChallenge(r *http.Request, key string) ([]byte, error) {
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	app.sessionManager.Put(r.Context(), key, base64.RawURLEncoding.EncodeToString(challenge))
+	app.sessionManager.Put(r.Context(), passkeySinceSessionManager, time.Now().Unix())
+
+	return challenge, nil
+}
+
+// passkeyChallenge removes the challenge of the passkey ceremony under key from the session and returns it,
+// nil being returned if there is none or if the ceremony timed out
+func (app *application) passkeyChallenge(r *http.Request, key string) []byte {
+
+	encoded := app.sessionManager.PopString(r.Context(), key)
+	since := time.Unix(app.sessionManager.GetInt64(r.Context(), passkeySinceSessionManager), 0)
+	app.sessionManager.Remove(r.Context(), passkeySinceSessionManager)
+	if encoded == "" || time.Since(since) > webauthn.Timeout {
+		return nil
+	}
+
+	challenge, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+
+	return challenge
+}
+
+// clientIP returns the IP of the client, as forwarded by Caddy when the request comes from it
+func clientIP(r *http.Request) string {
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	// Caddy appending the IP of the client to the X-Forwarded-For header
+	if addr := net.ParseIP(ip); addr != nil && addr.IsLoopback() {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			ip = strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+
+	return ip
+}
+
+// attemptWait returns the time the account email (if any) and the client ip have to wait before their next attempt,
+// because they are locked out or because of their last failed attempts (0 if they may try now)
+func (app *application) attemptWait(email, ip string) (time.Duration, error) {
+
+	var wait time.Duration
+	since := time.Now().Add(-app.config.lockout.window)
+
+	for scope, value := range map[string]string{data.LockoutAccount: email, data.LockoutIP: ip} {
+		if value == "" {
+			continue
+		}
+
+		// waiting until the end of the lockout
+		lockout, err := app.models.LoginAttemptModel.GetLockout(scope, value)
+		switch {
+		case err == nil:
+			wait = max(wait, time.Until(lockout.LockedUntil))
+			continue
+		case !errors.Is(err, data.ErrRecordNotFound):
+			return 0, err
+		}
+
+		// delaying the attempts after the free ones, twice as long after each failed one
+		failures, last, err := app.models.LoginAttemptModel.Failures(scope, value, since)
+		if err != nil {
+			return 0, err
+		}
+		if failures >= freeAttempts {
+			delay := min(attemptDelay<<min(failures-freeAttempts, 10), maxAttemptDelay)
+			wait = max(wait, time.Until(last.Add(delay)))
+		}
+	}
+
+	return wait, nil
+}
+
+// failedAttempt records the failed attempt of the account email (if any) from the client ip, locking them out once
+// they reached their limit (the password reset requests only counting for the IPs)
+func (app *application) failedAttempt(action, email, ip string) error {
+
+	err := app.models.LoginAttemptModel.Insert(&data.LoginAttempt{Action: action, Email: email, IP: ip})
+	if err != nil {
+		return err
+	}
+
+	limits := map[string]int{data.LockoutAccount: app.config.lockout.attempts, data.LockoutIP: app.config.lockout.ipAttempts}
+	values := map[string]string{data.LockoutAccount: email, data.LockoutIP: ip}
+	if action == data.AttemptForgotPassword {
+		delete(values, data.LockoutAccount)
+	}
+
+	for scope, value := range values {
+		if value == "" {
+			continue
+		}
+
+		// counting the failed attempts
+		failures, _, err := app.models.LoginAttemptModel.Failures(scope, value, time.Now().Add(-app.config.lockout.window))
+		if err != nil {
+			return err
+		}
+		if failures < limits[scope] {
+			continue
+		}
+
+		// locking them out
+		lockout := &data.Lockout{
+			Scope:       scope,
+			Value:       value,
+			LockedUntil: time.Now().Add(app.config.lockout.duration),
+			Attempts:    failures,
+		}
+		err = app.models.LoginAttemptModel.Lock(lockout)
+		if err != nil {
+			return err
+		}
+		app.logger.Warn("locked out after too many failed attempts", slog.String("scope", scope), slog.String("value", value))
+		app.mailLockout(lockout)
+	}
+
+	return nil
+}
+
+// mailLockout alerts the user of the account locked out, or the author of the site for the client IPs
+func (app *application) mailLockout(lockout *data.Lockout) {
+	app.background(func() {
+
+		// fetching the recipient, no mail being sent for the unknown accounts
+		var recipient string
+		switch lockout.Scope {
+		case data.LockoutAccount:
+			user, err := app.models.UserModel.GetByEmail(lockout.Value)
+			if err != nil {
+				if !errors.Is(err, data.ErrRecordNotFound) {
+					app.logger.Error(err.Error())
+				}
+				return
+			}
+			recipient = user.Email
+		default:
+			author, err := app.models.AuthorModel.Get()
+			if err != nil {
+				app.logger.Error(err.Error())
+				return
+			}
+			recipient = author.Email
+		}
+
+		mailData := map[string]any{
+			"account":  lockout.Scope == data.LockoutAccount,
+			"value":    lockout.Value,
+			"attempts": lockout.Attempts,
+			"until":    lockout.LockedUntil.In(app.config.booking.location).Format("02/01/2006 15:04 MST"),
+		}
+
+		err := app.mailer.Send(recipient, "lockout_alert.tmpl", mailData)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+}
+
+// tooManyAttempts renders page again with the form, telling the user how long to wait before their next attempt
+func (app *application) tooManyAttempts(w http.ResponseWriter, r *http.Request, form any, page string, wait time.Duration) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Form = form
+
+	// render the template
+	w.Header().Set("Retry-After", strconv.Itoa(int(max(wait.Round(time.Second), time.Second).Seconds())))
+	app.render(w, r, http.StatusTooManyRequests, page, tmplData)
+}
+
+// waitMessage returns the error of the forms telling the user how long to wait before their next attempt
+func waitMessage(wait time.Duration) string {
+	return fmt.Sprintf("too many failed attempts, please try again in %s", max(wait.Round(time.Second), time.Second))
+}
+
+// isTrustedDevice reports whether user trusted this device to skip the two-factor authentication
+// since they last enabled it
+func (app *application) isTrustedDevice(r *http.Request, user *data.User) bool {
+	return user.TOTPEnabled() &&
+		app.trustedDevices.GetInt(r.Context(), trustedUserIDSessionManager) == user.ID &&
+		app.trustedDevices.GetInt64(r.Context(), trustedTOTPEnabledSessionManager) == user.TOTPEnabledAt.Unix()
+}
+
+// pendingUser returns the user who typed their password and has to type the code of their authenticator app,
+// or sends them back to the login page once the time to do so is over (nil being returned once the response is written)
+func (app *application) pendingUser(w http.ResponseWriter, r *http.Request) *data.User {
+
+	id := app.sessionManager.GetInt(r.Context(), pendingUserIDSessionManager)
+	since := time.Unix(app.sessionManager.GetInt64(r.Context(), pendingSinceSessionManager), 0)
+	if id == 0 || time.Since(since) > twoFactorTimeout {
+		app.clearPendingUser(r)
+		app.sessionManager.Put(r.Context(), "flash", "Please sign in again.")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return nil
+	}
+
+	user, err := app.models.UserModel.GetByID(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil
+	}
+
+	return user
+}
+
+// clearPendingUser forgets the user waiting to type the code of their authenticator app
+func (app *application) clearPendingUser(r *http.Request) {
+	app.sessionManager.Remove(r.Context(), pendingUserIDSessionManager)
+	app.sessionManager.Remove(r.Context(), pendingSinceSessionManager)
+	app.sessionManager.Remove(r.Context(), pendingAttemptsSessionManager)
+}
+
+// renderUserPage renders the user page of user with the state of their two-factor authentication, their passkeys
+// and their sessions
+func (app *application) renderUserPage(w http.ResponseWriter, r *http.Request, status int, user *data.User, form *userUpdateForm) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Update user"
+	tmplData.Form = form
+
+	// fetching the passkeys of the user
+	passkeys, err := app.models.PasskeyModel.GetForUser(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	tmplData.Passkeys = passkeys
+
+	// fetching the sessions of the user
+	tmplData.Sessions, err = app.models.SessionModel.GetForUser(user.ID, app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	switch {
+
+	// enabled: the recovery codes just generated and the number of those left
+	case user.TOTPEnabled():
+		tmplData.TwoFactor.Enabled = true
+		tmplData.TwoFactor.RecoveryCodes, _ = app.sessionManager.Pop(r.Context(), recoveryCodesSessionManager).([]string)
+		left, err := app.models.RecoveryCodeModel.Count(user.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		tmplData.TwoFactor.RecoveryCodesLeft = left
+
+	// enrollment pending: the secret to scan or to type in the authenticator app
+	case user.TOTPSecret != nil:
+		code, err := qrcode.Encode([]byte(totp.URI(user.TOTPSecret, totpIssuer, user.Email)))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		var png bytes.Buffer
+		err = code.WritePNG(&png, 5)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		tmplData.TwoFactor.QRCode = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png.Bytes()))
+		tmplData.TwoFactor.Secret = totp.Encode(user.TOTPSecret)
+	}
+
+	// rendering the template
+	app.render(w, r, status, "user-update.tmpl", tmplData)
+}
+
+// renderUsersPage renders the user management page with the invitation form
+func (app *application) renderUsersPage(w http.ResponseWriter, r *http.Request, status int, form *userInviteForm) {
+
+	// retrieving basic template data
+	tmplData := app.newTemplateData(r)
+	tmplData.Title = "Antoine de Barbarin - Users"
+	tmplData.Form = form
+
+	// fetching the users
+	users, err := app.models.UserModel.GetAll()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	tmplData.Users = users
+
+	// rendering the template
+	app.render(w, r, status, "users.tmpl", tmplData)
+}
+
+// sendInvitation generates a new invitation link for the invited user and mails it to them
+func (app *application) sendInvitation(user *data.User, invitedBy string) error {
+
+	token, err := app.models.TokenModel.New(user.ID, invitationLifetime, data.TokenInvitation)
+	if err != nil {
+		return err
+	}
+
+	app.background(func() {
+
+		mailData := map[string]any{
+			"invitedBy":       invitedBy,
+			"role":            user.RoleLabel(),
+			"invitationToken": token.Plaintext,
+		}
+
+		err := app.mailer.Send(user.Email, "user_invitation.tmpl", mailData)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	return nil
+}
+
 func (app *application) logout(r *http.Request) error {
 
-	err := app.sessionManager.Clear(r.Context())
+	// forgetting the session, which is ended below
+	err := app.models.SessionModel.Delete(app.sessionManager.Token(r.Context()))
+	if err != nil {
+		return err
+	}
+
+	err = app.sessionManager.Clear(r.Context())
 	if err != nil {
 		return err
 	}
@@ -65,6 +873,26 @@ func (app *application) logout(r *http.Request) error {
 	return nil
 }
 
+// archiveMinRate is the slowest transfer rate, in bytes per second, for which ZIP archives are given enough time
+const archiveMinRate = 256 << 10
+
+// extendArchiveDeadlines gives a ZIP archive transfer more time than the server timeouts allow,
+// enough to receive or send an archive of the maximum size at archiveMinRate and to process it
+func (app *application) extendArchiveDeadlines(w http.ResponseWriter) {
+
+	timeout := app.config.uploads.chunkTimeout + time.Duration(app.config.uploads.maxArchiveSize/archiveMinRate)*time.Second
+	deadline := time.Now().Add(timeout)
+
+	rc := http.NewResponseController(w)
+	err := rc.SetReadDeadline(deadline)
+	if err == nil {
+		err = rc.SetWriteDeadline(deadline.Add(app.config.uploads.chunkTimeout))
+	}
+	if err != nil {
+		app.logger.Warn("ZIP archive: the transfer deadline can't be extended", "error", err.Error())
+	}
+}
+
 func newNonce() (string, error) {
 	nonceBytes := make([]byte, 32)
 	_, err := rand.Read(nonceBytes)
@@ -83,6 +911,15 @@ func (app *application) getNonce(r *http.Request) string {
 	return nonce
 }
 
+// maxJSONSize is the size of the largest JSON body of the AJAX requests
+const maxJSONSize = 64 << 10
+
+// readJSON decodes the JSON body of an AJAX request into dst
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONSize)
+	return json.NewDecoder(r.Body).Decode(dst)
+}
+
 func (app *application) decodePostForm(r *http.Request, dst any) error {
 
 	err := r.ParseForm()
@@ -114,6 +951,8 @@ func (app *application) clientError(w http.ResponseWriter, r *http.Request, stat
 
 	if status == http.StatusNotFound {
 		tmplData.Error.Message = "We didn't find what you were looking for :("
+	} else if status == http.StatusForbidden {
+		tmplData.Error.Message = "You are not allowed to do this :("
 	} else {
 		tmplData.Error.Message = "Something went wrong!"
 	}
@@ -165,12 +1004,18 @@ func (app *application) ajaxResponse(w http.ResponseWriter, status int, msg stri
 	var resData envelope
 
 	// checking the status code
-	if status < http.StatusBadRequest {
+	switch {
+	case status < http.StatusBadRequest:
 
 		// wrapping the message in a JSON object
 		resData = envelope{"response": msg}
 
-	} else {
+	case status < http.StatusInternalServerError:
+
+		// sending the client error message as it is
+		resData = envelope{"error": msg}
+
+	default:
 		// logging the error
 		app.logger.Error(msg)
 
@@ -178,6 +1023,12 @@ func (app *application) ajaxResponse(w http.ResponseWriter, status int, msg stri
 		resData = envelope{"error": "internal server error"}
 	}
 
+	app.writeJSON(w, status, resData)
+}
+
+// writeJSON sends resData as a JSON object
+func (app *application) writeJSON(w http.ResponseWriter, status int, resData envelope) {
+
 	// marshalling the resData
 	jsonData, err := json.Marshal(resData)
 	if err != nil {
@@ -198,6 +1049,39 @@ func (app *application) ajaxResponse(w http.ResponseWriter, status int, msg stri
 	}
 }
 
+// fieldErrorsMessage joins the field errors of v in a single message for the AJAX responses
+func fieldErrorsMessage(v *validator.Validator) string {
+	var msgs []string
+	for field, msg := range v.FieldErrors {
+		msgs = append(msgs, fmt.Sprintf("%s %s", field, msg))
+	}
+	sort.Strings(msgs)
+	return strings.Join(append(v.NonFieldErrors, msgs...), ", ")
+}
+
+// uploadError sends the JSON error matching an error returned by the uploads package
+func (app *application) uploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, uploads.ErrFileTooLarge), errors.Is(err, uploads.ErrArchiveTooLarge):
+		app.ajaxResponse(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, uploads.ErrForbiddenType):
+		app.ajaxResponse(w, http.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, uploads.ErrForbiddenDirectory), errors.Is(err, uploads.ErrFileNotFound), errors.Is(err, uploads.ErrResumableNotFound):
+		app.ajaxResponse(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, uploads.ErrResumableBusy):
+		app.ajaxResponse(w, http.StatusLocked, err.Error())
+	case errors.Is(err, uploads.ErrFolderProtected), errors.Is(err, uploads.ErrNotPrivate):
+		app.ajaxResponse(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, uploads.ErrFolderExists), errors.Is(err, uploads.ErrFolderNotEmpty), errors.Is(err, uploads.ErrOffsetMismatch),
+		errors.Is(err, uploads.ErrFileReferenced):
+		app.ajaxResponse(w, http.StatusConflict, err.Error())
+	case errors.Is(err, uploads.ErrFolderName), errors.Is(err, uploads.ErrFileName), errors.Is(err, uploads.ErrArchive):
+		app.ajaxResponse(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		app.ajaxResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
 func (app *application) background(fn func()) {
 
 	app.wg.Add(1)
@@ -225,6 +1109,15 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	return isAuthenticated
 }
 
+// contextUser returns the authenticated user, data.AnonymousUser if there is none
+func (app *application) contextUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(authenticatedUserContextKey).(*data.User)
+	if !ok {
+		return data.AnonymousUser
+	}
+	return user
+}
+
 func (app *application) getUserID(r *http.Request) int {
 	id, ok := app.sessionManager.Get(r.Context(), authenticatedUserIDSessionManager).(int)
 	if !ok {
@@ -239,6 +1132,19 @@ func newContactForm() *contactForm {
 	}
 }
 
+func newTestimonialForm() *testimonialForm {
+	return &testimonialForm{
+		Relationship: data.RelationshipColleague,
+		Validator:    *validator.New(),
+	}
+}
+
+func newBookingForm() *bookingForm {
+	return &bookingForm{
+		Validator: *validator.New(),
+	}
+}
+
 func newUserRegisterForm() *userRegisterForm {
 	return &userRegisterForm{
 		Validator: *validator.New(),
@@ -287,6 +1193,19 @@ func newResetPasswordForm() *resetPasswordForm {
 	}
 }
 
+func newUserInviteForm() *userInviteForm {
+	return &userInviteForm{
+		Role:      data.RoleContributor,
+		Validator: *validator.New(),
+	}
+}
+
+func newInvitationForm() *invitationForm {
+	return &invitationForm{
+		Validator: *validator.New(),
+	}
+}
+
 func newPostForm(post *data.Post) *postForm {
 
 	// creating the form
@@ -306,6 +1225,56 @@ func newPostForm(post *data.Post) *postForm {
 	return formNewPost
 }
 
+func newProjectForm(project *data.Project) *projectForm {
+
+	// creating the form
+	var form = &projectForm{Status: data.ProjectInProgress}
+
+	// filling the form with the data if any
+	if project != nil {
+		form.ID = project.ID
+		form.Version = project.Version
+		form.Title = project.Title
+		form.Summary = project.Summary
+		form.Description = string(project.Description)
+		form.Role = project.Role
+		form.TechStack = strings.Join(project.TechStack, ", ")
+		form.RepositoryURL = project.RepositoryURL
+		form.DemoURL = project.DemoURL
+		form.Screenshots = project.Screenshots
+		form.Status = project.Status
+		if project.StartedOn != nil {
+			form.StartedOn = project.StartedOn.Format("2006-01")
+		}
+		if project.EndedOn != nil {
+			form.EndedOn = project.EndedOn.Format("2006-01")
+		}
+		form.Featured = project.Featured
+		form.Position = project.Position
+	}
+
+	// setting the validator
+	form.Validator = *validator.New()
+
+	return form
+}
+
+// parseMonth reads the month value (YYYY-MM) of the field key, nil if empty or invalid
+func parseMonth(v *validator.Validator, value, key string) *time.Time {
+
+	if value == "" {
+		return nil
+	}
+
+	month, err := time.Parse("2006-01", value)
+	if err != nil {
+		v.AddFieldError(key, "must be a month (YYYY-MM)")
+		return nil
+	}
+
+	return &month
+}
+
 func (app *application) newAuthorUpdateForm() *authorUpdateForm {
 	return &authorUpdateForm{
 		Validator: *validator.New(),
@@ -327,7 +1296,7 @@ func (app *application) newTemplateData(r *http.Request) templateData {
 	}
 
 	// retrieving the post feed
-	postFeed, err := app.models.PostModel.GetFeed()
+	postFeed, err := app.models.PostModel.GetFeed(0)
 	if err != nil {
 		app.logger.Error(fmt.Errorf("error getting post feed: %w", err).Error())
 	}
@@ -354,6 +1323,11 @@ func (app *application) newTemplateData(r *http.Request) templateData {
 		tmplData.PostFeed = *postFeed
 	}
 
+	// setting the authenticated user, whose role decides of the actions shown
+	if isAuthenticated {
+		tmplData.User = *app.contextUser(r)
+	}
+
 	return tmplData
 }
 
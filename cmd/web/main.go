@@ -4,6 +4,7 @@ import (
 	"Portfolio/internal/data"
 	"Portfolio/internal/mailer"
 	"Portfolio/internal/uploads"
+	"crypto/rand"
 	"database/sql"
 	"flag"
 	"fmt"
@@ -12,9 +13,13 @@ import (
 	"github.com/go-playground/form/v4"
 	_ "github.com/lib/pq"
 	"log/slog"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
+	_ "time/tzdata"
 )
 
 func main() {
@@ -25,6 +30,7 @@ func main() {
 	// generic variables
 	flag.Int64Var(&cfg.port, "port", 4000, "HTTP service address")
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
+	flag.StringVar(&cfg.siteURL, "site-url", "", "Public URL of the site (e.g. \"https://example.com\"), the passkeys being registered for its host (http://localhost:<port> in development when empty)")
 
 	// PostgreSQL variables
 	flag.StringVar(&cfg.db.dsn, "dsn", "", "PostgreSQL Database DSN")
@@ -40,8 +46,34 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Antoine's Portfolio <no-reply@adebarbarin.com", "SMTP sender")
 
+	// uploads variables
+	flag.Int64Var(&cfg.uploads.maxFileSize, "upload-max-file-size", 10<<20, "Maximum size of an uploaded file in bytes")
+	flag.Int64Var(&cfg.uploads.maxRequestSize, "upload-max-request-size", 12<<20, "Maximum size of an upload request in bytes")
+	flag.Int64Var(&cfg.uploads.maxArchiveSize, "upload-max-archive-size", 200<<20, "Maximum size of an uploaded ZIP archive once extracted in bytes")
+	flag.IntVar(&cfg.uploads.maxArchiveFiles, "upload-max-archive-files", 500, "Maximum number of files in an uploaded ZIP archive")
+	flag.StringVar(&cfg.uploads.allowlist, "upload-allowlist", "", "MIME types allowed per upload directory, replacing the defaults (e.g. \"uploads/img=image/png,image/jpeg;uploads/docs=application/pdf\")")
+	cfg.uploads.storage.Flags(flag.CommandLine, "")
+	flag.StringVar(&cfg.uploads.stagingDir, "upload-staging-dir", filepath.Join(os.TempDir(), "portfolio-uploads"), "Directory keeping the partial resumable uploads, to be shared by all the instances serving the site")
+	flag.DurationVar(&cfg.uploads.stagingExpiry, "upload-staging-expiry", 24*time.Hour, "Time a partial resumable upload is kept after its last chunk")
+	flag.DurationVar(&cfg.uploads.chunkTimeout, "upload-chunk-timeout", time.Minute, "Maximum time to receive a chunk of a resumable upload")
+	flag.DurationVar(&cfg.uploads.trashRetention, "upload-trash-retention", 30*24*time.Hour, "Time a deleted upload is kept in the trash before being purged")
+	flag.StringVar(&cfg.uploads.signingKey, "upload-signing-key", "", "Secret signing the links to the private uploads (a random one, invalidating the links on restart, when empty)")
+
+	// bookings variables
+	flag.StringVar(&cfg.booking.timezone, "booking-timezone", "Europe/Paris", "Time zone of the availabilities of the authors (IANA name)")
+	flag.DurationVar(&cfg.booking.duration, "booking-duration", 30*time.Minute, "Duration of a booked meeting")
+	flag.DurationVar(&cfg.booking.notice, "booking-notice", 24*time.Hour, "Minimum time between a booking and its meeting")
+	flag.DurationVar(&cfg.booking.horizon, "booking-horizon", 28*24*time.Hour, "Maximum time between a booking and its meeting")
+
+	// brute-force protection variables
+	flag.IntVar(&cfg.lockout.attempts, "lockout-attempts", 10, "Failed attempts locking an account out")
+	flag.IntVar(&cfg.lockout.ipAttempts, "lockout-ip-attempts", 30, "Failed attempts locking a client IP out")
+	flag.DurationVar(&cfg.lockout.window, "lockout-window", 15*time.Minute, "Time a failed attempt counts for")
+	flag.DurationVar(&cfg.lockout.duration, "lockout-duration", 15*time.Minute, "Time an account or a client IP stays locked out")
+	flag.DurationVar(&cfg.lockout.retention, "lockout-retention", 30*24*time.Hour, "Time the failed attempts and the lockouts are kept")
+
 	// cleaning frequency
-	frequency := flag.Duration("frequency", time.Hour*2, "expired tokens and unactivated users cleaning frequency")
+	frequency := flag.Duration("frequency", time.Hour*2, "expired tokens, unactivated users, resumable uploads, trashed uploads and failed attempts cleaning frequency")
 
 	flag.Parse()
 
@@ -61,6 +93,33 @@ func main() {
 		os.Exit(1)
 	}
 
+	// checking the public URL of the site
+	if cfg.siteURL == "" && cfg.env == "development" {
+		cfg.siteURL = fmt.Sprintf("http://localhost:%d", cfg.port)
+	}
+	siteURL, err := url.Parse(cfg.siteURL)
+	if err != nil || (siteURL.Scheme != "http" && siteURL.Scheme != "https") || siteURL.Host == "" || strings.Trim(siteURL.Path, "/") != "" {
+		logger.Error("site-url must be the scheme and the host of the site (e.g. \"https://example.com\")")
+		os.Exit(1)
+	}
+	cfg.siteURL = siteURL.Scheme + "://" + siteURL.Host
+
+	// loading the time zone of the bookings
+	location, err := time.LoadLocation(cfg.booking.timezone)
+	if err != nil {
+		logger.Error(fmt.Errorf("invalid booking time zone: %w", err).Error())
+		os.Exit(1)
+	}
+	cfg.booking.location = location
+	if cfg.booking.duration < time.Minute {
+		logger.Error("the booking duration must be at least one minute")
+		os.Exit(1)
+	}
+	if cfg.lockout.attempts < 1 || cfg.lockout.ipAttempts < 1 {
+		logger.Error("the lockouts must allow at least one failed attempt")
+		os.Exit(1)
+	}
+
 	// checking the dsn info
 	if cfg.db.dsn == "" {
 		logger.Error("dsn is required")
@@ -86,15 +145,27 @@ func main() {
 	// initializing the application components
 	formDecoder := form.NewDecoder()
 
+	sessionStore := postgresstore.New(db)
+
 	sessionManager := scs.New()
-	sessionManager.Store = postgresstore.New(db)
+	sessionManager.Store = sessionStore
 	sessionManager.Lifetime = 24 * time.Hour
 	sessionManager.Cookie.Secure = true
 
+	// the devices trusted to skip the two-factor authentication are remembered in the sessions table too
+	trustedDevices := scs.New()
+	trustedDevices.Store = sessionStore
+	trustedDevices.Lifetime = trustedDeviceLifetime
+	trustedDevices.Cookie.Name = "trusted_device"
+	trustedDevices.Cookie.Path = "/login"
+	trustedDevices.Cookie.Persist = true
+	trustedDevices.Cookie.Secure = true
+
 	app := &application{
 		logger:         logger,
 		mailer:         mailer.New(cfg.smtp.host, int(cfg.smtp.port), cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
 		sessionManager: sessionManager,
+		trustedDevices: trustedDevices,
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		config:         &cfg,
@@ -108,12 +179,63 @@ func main() {
 	// Clean expired unactivated users every N duration with 1 hour timeout
 	go app.cleanExpiredUnactivatedUsers(*frequency, time.Hour)
 
-	// Initialize the uploads directories
-	err = uploads.Init()
+	// Clean the testimonials whose email was never verified every N duration with 1 hour timeout
+	go app.cleanExpiredTestimonials(*frequency, time.Hour)
+
+	// Clean the failed attempts and the lockouts older than the retention every N duration with 1 hour timeout
+	go app.cleanLoginAttempts(*frequency, time.Hour)
+
+	// Clean the sessions which ended every N duration with 1 hour timeout
+	go app.cleanExpiredSessions(*frequency, time.Hour)
+
+	// Initialize the uploads storage and move the legacy files to it
+	storage, err := cfg.uploads.storage.Open()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	err = uploads.Init(app.models.UploadModel, storage)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Set the uploads size limits and allowed types
+	allowlist, err := uploads.ParseAllowlist(cfg.uploads.allowlist)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
+	uploads.SetPolicy(uploads.Policy{
+		MaxFileSize:     cfg.uploads.maxFileSize,
+		Allowed:         allowlist,
+		MaxArchiveFiles: cfg.uploads.maxArchiveFiles,
+		MaxArchiveSize:  cfg.uploads.maxArchiveSize,
+	})
+
+	// Set the staging area of the resumable uploads and clean it every N duration
+	err = uploads.InitStaging(cfg.uploads.stagingDir, cfg.uploads.stagingExpiry, cfg.uploads.chunkTimeout)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	go app.cleanExpiredUploads(*frequency, time.Hour*0)
+
+	// Set the secret signing the links to the private uploads
+	signingKey := []byte(cfg.uploads.signingKey)
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		_, err = rand.Read(signingKey)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		logger.Warn("no upload signing key set, the links to the private uploads won't survive a restart")
+	}
+	uploads.SetSigningKey(signingKey)
+
+	// Purge the uploads kept in the trash for longer than the retention every N duration
+	go app.purgeTrash(*frequency, cfg.uploads.trashRetention)
 
 	// Running the server
 	err = app.serve()
@@ -1,11 +1,30 @@
 package main
 
 import (
+	"Portfolio/internal/uploads"
+	"fmt"
 	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 	"github.com/microcosm-cc/bluemonday"
+	stdhtml "html"
 	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// postImageSizes is the sizes attribute of the images displayed in the post content
+	postImageSizes = "(max-width: 800px) 100vw, 800px"
+
+	// notFoundImage is the image displayed in place of a missing or unsafe image
+	notFoundImage = "/static/img/not-found.jpg"
+)
+
+var (
+	imgTagRX  = regexp.MustCompile(`<img\s([^>]*?)\s*/?>`)
+	imgAttrRX = regexp.MustCompile(`([a-zA-Z-]+)="([^"]*)"`)
 )
 
 func mdToHTML(md []byte) template.HTML {
@@ -21,5 +40,69 @@ func mdToHTML(md []byte) template.HTML {
 	renderer := html.NewRenderer(opts)
 
 	// sanitize the output into safe HTML
-	return template.HTML(bluemonday.UGCPolicy().SanitizeBytes(markdown.Render(doc, renderer)))
+	safe := bluemonday.UGCPolicy().SanitizeBytes(markdown.Render(doc, renderer))
+
+	// make the uploaded images responsive
+	return template.HTML(responsiveImages(string(safe), postImageSizes))
+}
+
+// responsiveImages rewrites the sanitized <img> tags to add the srcset, sizes, width and height of the uploaded images,
+// with lazy loading
+func responsiveImages(safe, sizes string) string {
+	return imgTagRX.ReplaceAllStringFunc(safe, func(tag string) string {
+
+		// reading the (already escaped) attributes
+		attrs := make(map[string]string)
+		var names []string
+		set := func(name, value string, override bool) {
+			if _, ok := attrs[name]; !ok {
+				names = append(names, name)
+			} else if !override {
+				return
+			}
+			attrs[name] = value
+		}
+		for _, match := range imgAttrRX.FindAllStringSubmatch(tag, -1) {
+			set(strings.ToLower(match[1]), match[2], true)
+		}
+
+		// adding the responsive attributes of the uploaded images
+		src := stdhtml.UnescapeString(attrs["src"])
+		if img := uploads.ImageInfo(src); img != nil {
+			set("srcset", stdhtml.EscapeString(img.SrcSet(src)), true)
+			set("sizes", sizes, true)
+			set("width", fmt.Sprint(img.Width), true)
+			set("height", fmt.Sprint(img.Height), true)
+		}
+		set("loading", "lazy", false)
+		set("decoding", "async", false)
+
+		var sb strings.Builder
+		sb.WriteString("<img")
+		for _, name := range names {
+			fmt.Fprintf(&sb, ` %s="%s"`, name, attrs[name])
+		}
+		sb.WriteString(">")
+
+		return sb.String()
+	})
+}
+
+// responsiveImg returns an <img> tag for src with the responsive attributes of the uploaded images and lazy loading
+// (sources with a scheme other than http or https are replaced by the not found image)
+func responsiveImg(src, alt, class, sizes string) template.HTML {
+	if !safeImageSrc(src) {
+		src = notFoundImage
+	}
+	tag := fmt.Sprintf(`<img src="%s" alt="%s" class="%s">`, stdhtml.EscapeString(src), stdhtml.EscapeString(alt), stdhtml.EscapeString(class))
+	return template.HTML(responsiveImages(tag, stdhtml.EscapeString(sizes)))
+}
+
+// safeImageSrc checks if src is a relative URL or an http(s) URL, which can safely be written in the src attribute
+func safeImageSrc(src string) bool {
+	u, err := url.Parse(strings.TrimSpace(src))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || u.Scheme == "http" || u.Scheme == "https"
 }
@@ -1,15 +1,38 @@
 package main
 
 import (
+	"Portfolio/internal/data"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/justinas/nosurf"
 	"log/slog"
 	"net/http"
+	"strings"
 )
 
 const (
 	authenticatedUserIDSessionManager = "authenticated_user_id"
+
+	// user who typed their password, waiting to type the code of their authenticator app, since when and how many times they failed
+	pendingUserIDSessionManager   = "pending_user_id"
+	pendingSinceSessionManager    = "pending_since"
+	pendingAttemptsSessionManager = "pending_attempts"
+
+	// recovery codes just generated, shown once on the user page
+	recoveryCodesSessionManager = "recovery_codes"
+
+	// user trusting the device, and when they enabled the two-factor authentication (its trust ending if they enable it again)
+	trustedUserIDSessionManager      = "trusted_user_id"
+	trustedTOTPEnabledSessionManager = "trusted_totp_enabled_at"
+
+	// challenges of the passkey ceremonies in progress, and when the last one started
+	passkeyRegistrationSessionManager = "passkey_registration_challenge"
+	passkeyLoginSessionManager        = "passkey_login_challenge"
+	passkeySinceSessionManager        = "passkey_since"
+
+	// when the authenticated session was last recorded as seen
+	sessionSeenSessionManager = "session_seen_at"
 )
 
 func commonHeaders(next http.Handler) http.Handler {
@@ -94,16 +117,25 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		exists, err := app.models.UserModel.Exists(id)
-		if err != nil {
+		// fetching the user, only those who can sign in being authenticated (not disabled in the meantime)
+		user, err := app.models.UserModel.GetByID(id)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
 			app.serverError(w, r, err)
 			return
 		}
 
-		if exists {
+		if user != nil && user.IsActive() {
 			// setting the user as authenticated in the context
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserContextKey, user)
 			r = r.WithContext(ctx)
+
+			// recording that the session is still in use
+			err = app.touchSession(r, user.ID)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
 		}
 
 		next.ServeHTTP(w, r)
@@ -123,3 +155,23 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requirePermission only lets the authenticated users whose role has permission through,
+// the AJAX requests being answered in JSON
+func (app *application) requirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if !app.contextUser(r).Can(permission) {
+				if strings.Contains(r.Header.Get("Accept"), "application/json") {
+					app.ajaxResponse(w, http.StatusForbidden, "you are not allowed to do this")
+					return
+				}
+				app.clientError(w, r, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"Portfolio/internal/data"
 	"Portfolio/internal/mailer"
+	"Portfolio/internal/uploads"
 	"Portfolio/internal/validator"
+	"Portfolio/internal/webauthn"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 	"html/template"
@@ -13,9 +15,10 @@ import (
 )
 
 type config struct {
-	port int64
-	env  string
-	db   struct {
+	port    int64
+	env     string
+	siteURL string
+	db      struct {
 		dsn          string
 		maxOpenConns int
 		maxIdleConns int
@@ -29,6 +32,36 @@ type config struct {
 		password string
 		sender   string
 	}
+
+	uploads struct {
+		maxFileSize     int64
+		maxRequestSize  int64
+		maxArchiveSize  int64
+		maxArchiveFiles int
+		allowlist       string
+		storage         uploads.StorageConfig
+		stagingDir      string
+		stagingExpiry   time.Duration
+		chunkTimeout    time.Duration
+		trashRetention  time.Duration
+		signingKey      string
+	}
+
+	booking struct {
+		timezone string
+		location *time.Location
+		duration time.Duration
+		notice   time.Duration
+		horizon  time.Duration
+	}
+
+	lockout struct {
+		attempts   int
+		ipAttempts int
+		window     time.Duration
+		duration   time.Duration
+		retention  time.Duration
+	}
 }
 
 type application struct {
@@ -37,6 +70,7 @@ type application struct {
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	trustedDevices *scs.SessionManager
 	models         data.Models
 	config         *config
 	wg             *sync.WaitGroup
@@ -62,11 +96,45 @@ type templateData struct {
 	Search         string
 	Post           *data.Post
 	IsPostView     bool
+	IsProfileView  bool
 	PostFeed       data.PostFeed
 	Posts          struct {
 		List     []*data.Post
 		Metadata data.Metadata
 	}
+	Project       *data.Project
+	Testimonials  []*data.Testimonial
+	Booking       *data.Booking
+	Bookings      []*data.Booking
+	Schedule      *data.Schedule
+	Slots         []bookingDay
+	Timezone      string
+	TwoFactor     twoFactorData
+	Passkeys      []*data.Passkey
+	Sessions      []*data.Session
+	Lockouts      []*data.Lockout
+	LoginAttempts []*data.LoginAttempt
+	Users         []*data.User
+	Projects      struct {
+		List  []*data.Project
+		Techs []string
+		Tech  string
+	}
+}
+
+// bookingDay contains the free slots of a day offered to the visitors, in the time zone of the bookings
+type bookingDay struct {
+	Day   time.Time
+	Slots []time.Time
+}
+
+// twoFactorData is the state of the two-factor authentication of the user shown on the user page
+type twoFactorData struct {
+	Enabled           bool
+	QRCode            template.URL // data URI of the QR code of the pending enrollment
+	Secret            string       // secret of the pending enrollment, to type it in the authenticator app
+	RecoveryCodes     []string     // recovery codes just generated, shown once
+	RecoveryCodesLeft int
 }
 
 // envelope data type for JSON responses
@@ -76,6 +144,45 @@ type contactForm struct {
 	Name                string `form:"name"`
 	Email               string `form:"email"`
 	Message             string `form:"message"`
+	Author              string `form:"author"`
+	validator.Validator `form:"-"`
+}
+
+type testimonialForm struct {
+	Name                string `form:"name"`
+	Email               string `form:"email"`
+	Role                string `form:"role"`
+	Company             string `form:"company"`
+	Relationship        string `form:"relationship"`
+	Message             string `form:"message"`
+	Author              string `form:"author"`
+	validator.Validator `form:"-"`
+}
+
+type bookingForm struct {
+	Slot                string `form:"slot"`
+	Name                string `form:"name"`
+	Email               string `form:"email"`
+	Message             string `form:"message"`
+	Author              string `form:"author"`
+	validator.Validator `form:"-"`
+}
+
+type bookingCancelForm struct {
+	Token               string `form:"token"`
+	validator.Validator `form:"-"`
+}
+
+type availabilityForm struct {
+	Weekday             int    `form:"weekday"`
+	Start               string `form:"start"`
+	End                 string `form:"end"`
+	validator.Validator `form:"-"`
+}
+
+type blackoutForm struct {
+	Day                 string `form:"day"`
+	Reason              string `form:"reason"`
 	validator.Validator `form:"-"`
 }
 
@@ -85,6 +192,24 @@ type userLoginForm struct {
 	validator.Validator `form:"-"`
 }
 
+type twoFactorLoginForm struct {
+	Code                string `form:"code"`
+	Trust               bool   `form:"trust"`
+	validator.Validator `form:"-"`
+}
+
+// passkeyForm is the passkey registered by the user, sent as JSON by the client script
+type passkeyForm struct {
+	Name       string                       `json:"name"`
+	Credential webauthn.AttestationResponse `json:"credential"`
+}
+
+type twoFactorForm struct {
+	Code                string `form:"code"`
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
 type userUpdateForm struct {
 	Username             *string `form:"username,omitempty"`
 	Email                *string `form:"email,omitempty"`
@@ -120,18 +245,99 @@ type resetPasswordForm struct {
 	validator.Validator `form:"-"`
 }
 
+// userInviteForm is the invitation of a new user sent by an admin
+type userInviteForm struct {
+	Email               string `form:"email"`
+	Role                string `form:"role"`
+	validator.Validator `form:"-"`
+}
+
+type userRoleForm struct {
+	Role                string `form:"role"`
+	validator.Validator `form:"-"`
+}
+
+// invitationForm is the account the invited user sets up
+type invitationForm struct {
+	Token               string `form:"token"`
+	Username            string `form:"username"`
+	NewPassword         string `form:"new_password"`
+	ConfirmPassword     string `form:"confirm_password"`
+	validator.Validator `form:"-"`
+}
+
 type authorUpdateForm struct {
-	Name                *string  `form:"name"`
-	Email               *string  `form:"email"`
-	Avatar              *string  `form:"avatar"`
-	Presentation        *string  `form:"presentation"`
-	Birth               *string  `form:"birth"`
-	Location            *string  `form:"location"`
-	StatusActivity      *string  `form:"status_activity"`
-	Formations          []string `form:"formations"`
-	Experiences         []string `form:"experiences"`
-	Tags                []string `form:"tags"`
-	CVFile              *string  `form:"cv_file"`
+	Slug                *string `form:"slug"`
+	Name                *string `form:"name"`
+	Email               *string `form:"email"`
+	Avatar              *string `form:"avatar"`
+	Presentation        *string `form:"presentation"`
+	Birth               *string `form:"birth"`
+	Location            *string `form:"location"`
+	StatusActivity      *string `form:"status_activity"`
+	CVFile              *string `form:"cv_file"`
+	validator.Validator `form:"-"`
+}
+
+type timelineEntryForm struct {
+	Organization        string `form:"organization"`
+	Title               string `form:"title"`
+	Location            string `form:"location"`
+	StartDate           string `form:"start_date"`
+	EndDate             string `form:"end_date"`
+	Description         string `form:"description"`
+	URL                 string `form:"url"`
+	Position            int    `form:"position"`
+	validator.Validator `form:"-"`
+}
+
+type skillForm struct {
+	Name                string `form:"name"`
+	Category            string `form:"category"`
+	Level               int    `form:"level"`
+	Years               int    `form:"years"`
+	Position            int    `form:"position"`
+	ProjectIDs          []int  `form:"project_ids"`
+	PostIDs             []int  `form:"post_ids"`
+	validator.Validator `form:"-"`
+}
+
+type uploadMetadataForm struct {
+	Path                string `form:"path"`
+	Alt                 string `form:"alt"`
+	Caption             string `form:"caption"`
+	validator.Validator `form:"-"`
+}
+
+type folderForm struct {
+	Path                string `form:"path"`
+	Name                string `form:"name"`
+	Parent              string `form:"parent"`
+	validator.Validator `form:"-"`
+}
+
+type signLinkForm struct {
+	Path                string `form:"path"`
+	TTL                 string `form:"ttl"`
+	validator.Validator `form:"-"`
+}
+
+type projectForm struct {
+	ID                  int      `form:"id,omitempty"`
+	Version             int      `form:"version,omitempty"`
+	Title               string   `form:"title"`
+	Summary             string   `form:"summary"`
+	Description         string   `form:"description"`
+	Role                string   `form:"role"`
+	TechStack           string   `form:"tech_stack"`
+	RepositoryURL       string   `form:"repository_url"`
+	DemoURL             string   `form:"demo_url"`
+	Screenshots         []string `form:"screenshots"`
+	Status              string   `form:"status"`
+	StartedOn           string   `form:"started_on"`
+	EndedOn             string   `form:"ended_on"`
+	Featured            bool     `form:"featured"`
+	Position            int      `form:"position"`
 	validator.Validator `form:"-"`
 }
 
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"Portfolio/internal/data"
+	"Portfolio/internal/uploads"
 	"Portfolio/ui"
 	"github.com/alexedwards/flow"
 	"io/fs"
@@ -20,8 +22,9 @@ func (app *application) routes() http.Handler {
 	router.NotFound = http.HandlerFunc(app.notFound)                 // error 404 page
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowed) // error 405 page
 
-	router.Handle("/static/...", http.StripPrefix("/static/", http.FileServerFS(staticFs)), http.MethodGet)            // static files
-	router.Handle("/uploads/...", http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads"))), http.MethodGet) // uploaded files
+	router.Handle("/static/...", http.StripPrefix("/static/", http.FileServerFS(staticFs)), http.MethodGet) // static files
+	router.Handle("/uploads/...", http.StripPrefix("/uploads/", uploads.Serve()), http.MethodGet)           // uploaded files
+	router.Handle("/private/...", http.StripPrefix("/private/", uploads.ServePrivate()), http.MethodGet)    // private uploaded files through signed links
 
 	router.Use(app.recoverPanic, app.logRequest, commonHeaders, app.sessionManager.LoadAndSave, noSurf, app.authenticate)
 
@@ -39,7 +42,32 @@ func (app *application) routes() http.Handler {
 		group.HandleFunc("/user", app.updateUser, http.MethodGet)      // update user page
 		group.HandleFunc("/user", app.updateUserPost, http.MethodPost) // update user treatment route
 
-		// TODO -> add delete user and more to complete the user management options
+		group.HandleFunc("/user/2fa/setup", app.setupTwoFactor, http.MethodPost)            // two-factor authentication enrollment route
+		group.HandleFunc("/user/2fa/enable", app.enableTwoFactor, http.MethodPost)          // two-factor authentication activation route
+		group.HandleFunc("/user/2fa/disable", app.disableTwoFactor, http.MethodPost)        // two-factor authentication deactivation route
+		group.HandleFunc("/user/2fa/recovery-codes", app.newRecoveryCodes, http.MethodPost) // recovery codes replacement route
+
+		group.HandleFunc("/user/passkeys/options", app.passkeyRegistrationOptions, http.MethodPost) // passkey registration options with AJAX
+		group.HandleFunc("/user/passkeys", app.createPasskey, http.MethodPost)                      // passkey registration with AJAX
+		group.HandleFunc("/user/passkeys/:id/delete", app.deletePasskey, http.MethodPost)           // passkey deletion route
+
+		group.HandleFunc("/user/sessions/:id/revoke", app.revokeSession, http.MethodPost)   // session logout route
+		group.HandleFunc("/user/sessions/revoke", app.revokeOtherSessions, http.MethodPost) // other sessions logout route
+
+		// USERS MANAGEMENT
+		group.Group(func(group *flow.Mux) {
+
+			group.Use(app.requirePermission(data.PermissionUsers))
+
+			group.HandleFunc("/users", app.users, http.MethodGet)                        // user management page
+			group.HandleFunc("/users/invite", app.inviteUser, http.MethodPost)           // user invitation route
+			group.HandleFunc("/users/:id/invite", app.resendInvitation, http.MethodPost) // user invitation resending route
+			group.HandleFunc("/users/:id/role", app.updateUserRole, http.MethodPost)     // user role change route
+			group.HandleFunc("/users/:id/disable", app.disableUser, http.MethodPost)     // user disabling route
+			group.HandleFunc("/users/:id/enable", app.enableUser, http.MethodPost)       // user enabling route
+			group.HandleFunc("/users/:id/delete", app.deleteUser, http.MethodPost)       // user deletion route
+			group.HandleFunc("/lockouts/:id/unlock", app.unlock, http.MethodPost)        // account or client IP unlocking route
+		})
 
 		// POST HANDLING
 		group.HandleFunc("/post/create", app.createPost, http.MethodGet)          // post creation page
@@ -47,19 +75,80 @@ func (app *application) routes() http.Handler {
 		group.HandleFunc("/post/:id/update", app.updatePost, http.MethodGet)      // post update page
 		group.HandleFunc("/post/:id/update", app.updatePostPost, http.MethodPost) // post update treatment route
 
+		// PROJECT HANDLING
+		group.Group(func(group *flow.Mux) {
+
+			group.Use(app.requirePermission(data.PermissionProjects))
+
+			group.HandleFunc("/project/create", app.createProject, http.MethodGet)          // project creation page
+			group.HandleFunc("/project/create", app.createProjectPost, http.MethodPost)     // project creation treatment route
+			group.HandleFunc("/project/:id/update", app.updateProject, http.MethodGet)      // project update page
+			group.HandleFunc("/project/:id/update", app.updateProjectPost, http.MethodPost) // project update treatment route
+			group.HandleFunc("/project/:id/delete", app.deleteProject, http.MethodPost)     // project deletion route
+		})
+
 		// AUTHOR HANDLING
 		group.HandleFunc("/author", app.updateAuthor, http.MethodGet)      // author update page
 		group.HandleFunc("/author", app.updateAuthorPost, http.MethodPost) // author update treatment route
 
+		group.HandleFunc("/author/skills", app.createSkill, http.MethodPost)            // skill creation route
+		group.HandleFunc("/author/skills/:id", app.updateSkill, http.MethodPost)        // skill update route
+		group.HandleFunc("/author/skills/:id/delete", app.deleteSkill, http.MethodPost) // skill deletion route
+
+		group.HandleFunc("/author/:kind", app.createTimelineEntry, http.MethodPost)            // formation or experience creation route
+		group.HandleFunc("/author/:kind/:id", app.updateTimelineEntry, http.MethodPost)        // formation or experience update route
+		group.HandleFunc("/author/:kind/:id/delete", app.deleteTimelineEntry, http.MethodPost) // formation or experience deletion route
+
+		// TESTIMONIALS
+		group.HandleFunc("/testimonial/:id/review", app.reviewTestimonial, http.MethodPost) // testimonial approval or rejection route
+		group.HandleFunc("/testimonial/:id/delete", app.deleteTestimonial, http.MethodPost) // testimonial deletion route
+
+		// BOOKINGS
+		group.HandleFunc("/availability", app.availability, http.MethodGet)                         // availability page
+		group.HandleFunc("/availability", app.createAvailability, http.MethodPost)                  // weekly availability creation route
+		group.HandleFunc("/availability/:id/delete", app.deleteAvailability, http.MethodPost)       // weekly availability deletion route
+		group.HandleFunc("/availability/blackouts", app.createBlackout, http.MethodPost)            // blackout date creation route
+		group.HandleFunc("/availability/blackouts/:id/delete", app.deleteBlackout, http.MethodPost) // blackout date deletion route
+		group.HandleFunc("/booking/:id/cancel", app.authorCancelBooking, http.MethodPost)           // meeting cancellation route
+
 		// TODO -> add delete post and more to complete the posts management options
 
 		// FILES & UPLOADS
-		group.HandleFunc("/files/:dir", app.getFiles, http.MethodGet) // get file list with AJAX
+		group.HandleFunc("/files/:dir", app.getFiles, http.MethodGet) // get file list with AJAX (the private folder being reserved to the files managers)
 
 		group.HandleFunc("/upload", app.uploadFile, http.MethodPost) // upload file with AJAX
 
-		group.HandleFunc("/upload/:dir/:file", app.deleteFile, http.MethodDelete) // delete file with AJAX
-		group.HandleFunc("/upload/:file", app.deleteFile, http.MethodDelete)      // delete file with AJAX
+		group.HandleFunc("/upload/tus", app.tusOptions, http.MethodOptions) // resumable uploads: protocol support
+		group.HandleFunc("/upload/tus", app.tusCreate, http.MethodPost)     // resumable uploads: creation
+		group.HandleFunc("/upload/tus/:id", app.tusHead, http.MethodHead)   // resumable uploads: received offset
+		group.HandleFunc("/upload/tus/:id", app.tusPatch, http.MethodPatch) // resumable uploads: chunk
+
+		// FILES MANAGEMENT
+		group.Group(func(group *flow.Mux) {
+
+			group.Use(app.requirePermission(data.PermissionFiles))
+
+			group.HandleFunc("/files/metadata", app.updateFileMetadata, http.MethodPost) // update the alt text and caption of a file with AJAX
+			group.HandleFunc("/files/move", app.moveFile, http.MethodPost)               // move a file to another folder with AJAX
+			group.HandleFunc("/files/sign", app.signFile, http.MethodPost)               // create an expiring link to a private file with AJAX
+
+			group.HandleFunc("/upload/zip", app.uploadArchive, http.MethodPost)         // extract a ZIP archive to a folder with AJAX
+			group.HandleFunc("/files/archive/:dir", app.downloadFolder, http.MethodGet) // download a folder as a ZIP archive
+
+			group.HandleFunc("/files/folders", app.createFolder, http.MethodPost)        // create a folder with AJAX
+			group.HandleFunc("/files/folders/rename", app.renameFolder, http.MethodPost) // rename a folder with AJAX
+			group.HandleFunc("/files/folders/move", app.moveFolder, http.MethodPost)     // move a folder with AJAX
+			group.HandleFunc("/files/folders/delete", app.deleteFolder, http.MethodPost) // delete an empty folder with AJAX
+
+			group.HandleFunc("/upload/:dir/:file", app.deleteFile, http.MethodDelete) // move file to the trash with AJAX
+			group.HandleFunc("/upload/:file", app.deleteFile, http.MethodDelete)      // move file to the trash with AJAX
+
+			group.HandleFunc("/orphans", app.getOrphans, http.MethodGet) // get the list of the files no post nor project uses with AJAX
+
+			group.HandleFunc("/trash", app.getTrash, http.MethodGet)             // get the trashed file list with AJAX
+			group.HandleFunc("/trash/restore", app.restoreFile, http.MethodPost) // restore a file from the trash with AJAX
+			group.HandleFunc("/trash/empty", app.emptyTrash, http.MethodPost)    // delete the trashed files for good with AJAX
+		})
 
 	})
 
@@ -74,17 +163,53 @@ func (app *application) routes() http.Handler {
 	router.HandleFunc("/post/:id", app.postIncrementView, http.MethodPost) // AJAX call increment post view
 	router.HandleFunc("/post/:id", app.postGet, http.MethodGet)            // post page
 
+	router.HandleFunc("/projects", app.projects, http.MethodGet)      // projects showcase page
+	router.HandleFunc("/project/:id", app.projectGet, http.MethodGet) // project page
+
+	router.HandleFunc("/cv.pdf", app.authorCV, http.MethodGet)           // CV generated from the author profile
+	router.HandleFunc("/resume.json", app.resumeJSON, http.MethodGet)    // JSON Resume of the author
+	router.HandleFunc("/contact.vcf", app.contactVCard, http.MethodGet)  // vCard of the author
+	router.HandleFunc("/contact.png", app.contactQRCode, http.MethodGet) // QR code of the vCard of the author
+
+	router.HandleFunc("/author/:slug", app.authorGet, http.MethodGet)                 // public page of an author
+	router.HandleFunc("/author/:slug/cv.pdf", app.authorCV, http.MethodGet)           // CV generated from the profile of an author
+	router.HandleFunc("/author/:slug/resume.json", app.resumeJSON, http.MethodGet)    // JSON Resume of an author
+	router.HandleFunc("/author/:slug/contact.vcf", app.contactVCard, http.MethodGet)  // vCard of an author
+	router.HandleFunc("/author/:slug/contact.png", app.contactQRCode, http.MethodGet) // QR code of the vCard of an author
+
 	router.HandleFunc("/search", app.search, http.MethodGet)      // search page
 	router.HandleFunc("/latest", app.latestPosts, http.MethodGet) // latest posts page
 
 	router.HandleFunc("/contact", app.contact, http.MethodPost) // contact message treatment page
 
+	router.HandleFunc("/testimonial", app.testimonial, http.MethodGet)                     // testimonial submission page
+	router.HandleFunc("/testimonial", app.testimonialPost, http.MethodPost)                // testimonial submission treatment route
+	router.HandleFunc("/testimonial/verify/:token", app.verifyTestimonial, http.MethodGet) // testimonial email verification route
+	router.HandleFunc("/author/:slug/testimonial", app.testimonial, http.MethodGet)        // testimonial submission page for an author
+
+	router.HandleFunc("/booking", app.booking, http.MethodGet)                     // meeting booking page
+	router.HandleFunc("/booking", app.bookingPost, http.MethodPost)                // meeting booking treatment route
+	router.HandleFunc("/booking/cancel/:token", app.cancelBooking, http.MethodGet) // meeting cancellation page
+	router.HandleFunc("/booking/cancel", app.cancelBookingPost, http.MethodPost)   // meeting cancellation treatment route
+	router.HandleFunc("/author/:slug/booking", app.booking, http.MethodGet)        // meeting booking page for an author
+
 	/* #############################################################################
 	/*	USER ACCESS
 	/* #############################################################################*/
 
-	router.HandleFunc("/login", app.login, http.MethodGet)      // login page
-	router.HandleFunc("/login", app.loginPost, http.MethodPost) // login treatment route
+	router.Group(func(group *flow.Mux) {
+
+		// the devices trusted to skip the two-factor authentication are only needed to sign in
+		group.Use(app.trustedDevices.LoadAndSave)
+
+		group.HandleFunc("/login", app.login, http.MethodGet)                   // login page
+		group.HandleFunc("/login", app.loginPost, http.MethodPost)              // login treatment route
+		group.HandleFunc("/login/2fa", app.loginTwoFactor, http.MethodGet)      // two-factor authentication page
+		group.HandleFunc("/login/2fa", app.loginTwoFactorPost, http.MethodPost) // two-factor authentication treatment route
+
+		group.HandleFunc("/login/passkey/options", app.loginPasskeyOptions, http.MethodPost) // passkey sign in options with AJAX
+		group.HandleFunc("/login/passkey", app.loginPasskey, http.MethodPost)                // passkey sign in with AJAX
+	})
 
 	//router.HandleFunc("/register", app.register, http.MethodGet)      // register page
 	//router.HandleFunc("/register", app.registerPost, http.MethodPost) // register treatment route
@@ -92,6 +217,9 @@ func (app *application) routes() http.Handler {
 	//router.HandleFunc("/activation/:token", app.activate, http.MethodGet) // activation page
 	//router.HandleFunc("/activation", app.activatePost, http.MethodPost)   // activation treatment route
 
+	router.HandleFunc("/invitation/:token", app.invitation, http.MethodGet) // invitation page
+	router.HandleFunc("/invitation", app.invitationPost, http.MethodPost)   // invitation treatment route
+
 	router.HandleFunc("/forgot-password", app.forgotPassword, http.MethodGet)      // forgot password page
 	router.HandleFunc("/forgot-password", app.forgotPasswordPost, http.MethodPost) // forgot password treatment route
 
@@ -1,22 +1,45 @@
 package main
 
 import (
+	"Portfolio/internal/data"
 	"Portfolio/internal/uploads"
 	"Portfolio/ui"
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 )
 
 var functions = template.FuncMap{
-	"humanDate":     humanDate,
-	"mdToHTML":      mdToHTML,
-	"bytesToString": bytesToString,
-	"increment":     increment,
-	"decrement":     decrement,
-	"filename":      filename,
-	"isDir":         isDir,
+	"humanDate":       humanDate,
+	"mdToHTML":        mdToHTML,
+	"bytesToString":   bytesToString,
+	"increment":       increment,
+	"decrement":       decrement,
+	"filename":        filename,
+	"isDir":           isDir,
+	"responsiveImg":   responsiveImg,
+	"humanSize":       uploads.HumanSize,
+	"fileTypes":       uploads.TypeNames,
+	"isBuiltin":       uploads.IsBuiltinFolder,
+	"timelineForms":   timelineForms,
+	"timelineKinds":   func() []string { return data.TimelineKinds },
+	"projectStatuses": func() []string { return data.ProjectStatuses },
+	"humanStatus":     humanStatus,
+	"skillForms":      skillForms,
+	"skillCategories": func() []string { return data.SkillCategories },
+	"skillCategory":   data.SkillCategoryLabel,
+	"skillLevels":     skillLevels,
+	"skillLevel":      data.SkillLevelName,
+	"containsID":      slices.Contains[[]int],
+	"relationships":   func() []string { return data.TestimonialRelationships },
+	"relationship":    data.RelationshipLabel,
+	"weekdays":        func() []time.Weekday { return data.Weekdays },
+	"can":             data.Can,
+	"roles":           func() []string { return data.Roles },
+	"roleLabel":       data.RoleLabel,
 }
 
 func filename(file uploads.File) string {
@@ -27,6 +50,50 @@ func isDir(file uploads.File) bool {
 	return file.IsDir()
 }
 
+// timelineForms returns the entries of the kind kind of the author followed by a blank one, to edit them or add a new one
+func timelineForms(author *data.Author, kind string) []*data.TimelineEntry {
+
+	var entries []*data.TimelineEntry
+	if author != nil {
+		switch kind {
+		case data.TimelineFormation:
+			entries = append(entries, author.Formations...)
+		case data.TimelineExperience:
+			entries = append(entries, author.Experiences...)
+		}
+	}
+
+	return append(entries, &data.TimelineEntry{Kind: kind})
+}
+
+// humanStatus returns the status of a project as displayed ("in-progress" -> "In progress")
+func humanStatus(status string) string {
+	if status == "" {
+		return ""
+	}
+	return strings.ToUpper(status[:1]) + strings.ReplaceAll(status[1:], "-", " ")
+}
+
+// skillForms returns the skills of the author followed by a blank one, to edit them or add a new one
+func skillForms(author *data.Author) []*data.Skill {
+
+	var skills []*data.Skill
+	if author != nil {
+		skills = append(skills, author.Skills...)
+	}
+
+	return append(skills, &data.Skill{Category: data.SkillOther, Level: 3})
+}
+
+// skillLevels returns the proficiency levels, from the lowest
+func skillLevels() []int {
+	var levels []int
+	for level := data.MinSkillLevel; level <= data.MaxSkillLevel; level++ {
+		levels = append(levels, level)
+	}
+	return levels
+}
+
 func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
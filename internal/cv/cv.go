@@ -0,0 +1,352 @@
+package cv
+
+import (
+	"Portfolio/internal/data"
+	"Portfolio/ui"
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+// margin is the space around the content of the pages, in points
+const margin = 50.0
+
+// the fonts of the CV, bundled with the site assets
+const (
+	bodyFont = iota
+	titleFont
+)
+
+var fontFiles = []struct{ name, file string }{
+	bodyFont:  {"VarelaRound-Regular", "assets/font/VarelaRound-Regular.ttf"},
+	titleFont: {"Dosis", "assets/font/Dosis-VariableFont_wght.ttf"},
+}
+
+// the colors of the site
+var (
+	darkBlue = [3]float64{0.008, 0.149, 0.235}
+	blue     = [3]float64{0.349, 0.584, 0.929}
+	orange   = [3]float64{0.984, 0.522, 0}
+	grey     = [3]float64{0.4, 0.4, 0.45}
+)
+
+// style is the look of a paragraph of the layout
+type style struct {
+	font   int
+	size   float64
+	color  [3]float64
+	before float64
+	indent float64
+	bullet bool
+}
+
+// styles contains the paragraph styles the layout template can use
+var styles = map[string]style{
+	"name":    {font: titleFont, size: 28, color: darkBlue},
+	"status":  {font: titleFont, size: 15, color: blue, before: 2},
+	"contact": {font: bodyFont, size: 10, color: grey, before: 4},
+	"heading": {font: titleFont, size: 16, color: orange, before: 16},
+	"text":    {font: bodyFont, size: 10.5, color: darkBlue, before: 4},
+	"bullet":  {font: bodyFont, size: 10.5, color: darkBlue, before: 3, indent: 14, bullet: true},
+	"tags":    {font: bodyFont, size: 10.5, color: blue, before: 4},
+	"small":   {font: bodyFont, size: 8, color: grey, before: 4},
+}
+
+// generated is a CV generated from the profile of an author last updated at modTime
+type generated struct {
+	pdf      []byte
+	etag     string
+	filename string
+	modTime  time.Time
+}
+
+// cache contains the last CV generated for each author, by author ID
+var cache = struct {
+	mu      sync.RWMutex
+	authors map[int]*generated
+}{
+	authors: make(map[int]*generated),
+}
+
+// lines keeps the user text text on the paragraph of its directive, its next lines continuing the paragraph
+// with the @+ directive, so that a line of text starting with @ is never read as a directive
+func lines(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\n@+ ")
+}
+
+// join joins the non-blank elements of a list
+func join(elems []string, sep string) string {
+	var kept []string
+	for _, elem := range elems {
+		if strings.TrimSpace(elem) != "" {
+			kept = append(kept, strings.TrimSpace(elem))
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+// loadFonts reads the bundled fonts
+func loadFonts() ([]*font, error) {
+
+	fonts := make([]*font, len(fontFiles))
+	for i, file := range fontFiles {
+		content, err := ui.StaticFiles.ReadFile(file.file)
+		if err != nil {
+			return nil, err
+		}
+		fonts[i], err = parseFont(file.name, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fonts, nil
+}
+
+// layout returns the paragraphs of the CV of author, one per line with their style, from the layout template
+func layout(author *data.Author) (string, error) {
+
+	tmpl, err := template.New("cv.tmpl").Funcs(template.FuncMap{"join": join, "lines": lines}).ParseFS(templateFS, "templates/cv.tmpl")
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, author)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Generate renders the profile of author to a print-ready A4 PDF, following the layout template
+func Generate(author *data.Author) ([]byte, error) {
+
+	paragraphs, err := layout(author)
+	if err != nil {
+		return nil, err
+	}
+
+	fonts, err := loadFonts()
+	if err != nil {
+		return nil, fmt.Errorf("error loading the CV fonts: %w", err)
+	}
+
+	w := &writer{doc: newDocument(author.Name+" - CV", author.Name, author.UpdatedAt, fonts...), fonts: fonts}
+	w.newPage()
+
+	// drawing the paragraphs of the layout
+	var previous style
+	for i, line := range strings.Split(paragraphs, "\n") {
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// the lines without style continue the paragraph above
+		if !strings.HasPrefix(line, "@") {
+			w.paragraph(previous, line, false)
+			continue
+		}
+
+		name, text, _ := strings.Cut(line[1:], " ")
+		text = strings.TrimSpace(text)
+		switch name {
+		case "+":
+			w.paragraph(previous, text, false)
+		case "rule":
+			w.rule()
+		case "space":
+			w.y -= 12
+		default:
+			st, ok := styles[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown style %q at line %d of the CV layout", name, i+1)
+			}
+			previous = st
+			w.paragraph(st, text, st.bullet)
+		}
+	}
+
+	var pdf bytes.Buffer
+	_, err = w.doc.WriteTo(&pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdf.Bytes(), nil
+}
+
+// writer lays out the paragraphs on the pages of the document, from the top
+type writer struct {
+	doc   *document
+	fonts []*font
+	y     float64
+}
+
+func (w *writer) newPage() {
+	w.doc.addPage()
+	w.y = pageHeight - margin
+}
+
+// rule draws a line across the page
+func (w *writer) rule() {
+	w.y -= 8
+	w.doc.line(margin, w.y, pageWidth-margin, w.y, 1, orange)
+	w.y -= 4
+}
+
+// paragraph draws text in the style st, wrapping its words on the width of the page (with a bullet before the first line if set)
+func (w *writer) paragraph(st style, text string, bullet bool) {
+
+	if text == "" {
+		return
+	}
+
+	f := w.fonts[st.font]
+	lineHeight := st.size * 1.3
+	lines := wrap(f, st.size, text, pageWidth-2*margin-st.indent)
+
+	// keeping the headings with the two lines after them
+	w.y -= st.before
+	keep := lineHeight
+	if st.font == titleFont {
+		keep += 2 * styles["text"].size * 1.3
+	}
+	if w.y-keep < margin {
+		w.newPage()
+	}
+
+	for i, line := range lines {
+
+		if w.y-lineHeight < margin {
+			w.newPage()
+		}
+		w.y -= lineHeight
+
+		// centering the ascent and the descent of the font on the line
+		ascent := float64(f.ascent) * st.size / float64(f.unitsPerEm)
+		descent := float64(-f.descent) * st.size / float64(f.unitsPerEm)
+		baseline := w.y + (lineHeight-ascent-descent)/2 + descent
+		x := margin + st.indent
+
+		if bullet && i == 0 {
+			mark := "•"
+			if !f.has(mark) {
+				mark = "-"
+			}
+			w.doc.text(st.font, st.size, x-st.indent+2, baseline, orange, mark)
+		}
+		w.doc.text(st.font, st.size, x, baseline, st.color, line)
+	}
+}
+
+// wrap splits text into lines no wider than width, the words longer than a line being cut
+func wrap(f *font, size float64, text string, width float64) []string {
+
+	var lines []string
+	var current string
+
+	for _, word := range strings.Fields(text) {
+
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if f.width(candidate, size) <= width {
+			current = candidate
+			continue
+		}
+
+		if current != "" {
+			lines = append(lines, current)
+		}
+
+		// cutting the words wider than the page
+		for f.width(word, size) > width {
+			cut := len(word)
+			for cut > 0 && f.width(word[:cut], size) > width {
+				_, n := utf8.DecodeLastRuneInString(word[:cut])
+				cut -= n
+			}
+			if cut == 0 {
+				_, cut = utf8.DecodeRuneInString(word)
+			}
+			lines = append(lines, word[:cut])
+			word = word[cut:]
+		}
+		current = word
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+
+	return lines
+}
+
+// update generates the CV of author and keeps it in the cache
+func update(author *data.Author) (*generated, error) {
+
+	pdf, err := Generate(author)
+	if err != nil {
+		return nil, fmt.Errorf("error generating the CV: %w", err)
+	}
+
+	sum := sha256.Sum256(pdf)
+	cv := &generated{
+		pdf:      pdf,
+		etag:     hex.EncodeToString(sum[:16]),
+		filename: author.Name + " - CV.pdf",
+		modTime:  author.UpdatedAt,
+	}
+
+	cache.mu.Lock()
+	cache.authors[author.ID] = cv
+	cache.mu.Unlock()
+
+	return cv, nil
+}
+
+// Write sends the CV of author, generating it only if its profile was updated since the last time
+func Write(w http.ResponseWriter, r *http.Request, author *data.Author) error {
+
+	cache.mu.RLock()
+	cv, ok := cache.authors[author.ID]
+	cache.mu.RUnlock()
+
+	if !ok || !cv.modTime.Equal(author.UpdatedAt) {
+		var err error
+		cv, err = update(author)
+		if err != nil {
+			return err
+		}
+	}
+
+	servePDF(w, r, cv.pdf, cv.etag, cv.filename, cv.modTime)
+
+	return nil
+}
+
+// servePDF sends the PDF file pdf, displayed in the browser
+func servePDF(w http.ResponseWriter, r *http.Request, pdf []byte, etag, filename string, modTime time.Time) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, filename, modTime, bytes.NewReader(pdf))
+}
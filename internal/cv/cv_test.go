@@ -0,0 +1,93 @@
+package cv
+
+import (
+	"Portfolio/internal/data"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLines(t *testing.T) {
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Backend developer", "Backend developer"},
+		{"@rule", "@rule"},
+		{"first\nsecond", "first\n@+ second"},
+		{"first\r\n@heading Hacked", "first\n@+ @heading Hacked"},
+	}
+
+	for _, tt := range tests {
+		if got := lines(tt.text); got != tt.want {
+			t.Errorf("lines(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestLayout checks that the profile text starting with @ is kept as text instead of being read as the style of a paragraph
+func TestLayout(t *testing.T) {
+
+	// the styles of the paragraphs of the CV, whatever the profile text
+	want := []string{"name", "status", "contact", "rule", "heading", "text", "heading", "bullet", "space", "small"}
+
+	tests := []struct {
+		name string
+		text string
+		edit func(author *data.Author, text string)
+	}{
+		{"name", "@rule", func(a *data.Author, text string) { a.Name = text }},
+		{"status", "@heading Hacked", func(a *data.Author, text string) { a.StatusActivity = text }},
+		{"location", "@unknown style", func(a *data.Author, text string) { a.Location = text }},
+		{"presentation", "@rule\n@heading Hacked\n@space", func(a *data.Author, text string) { a.Presentation = &text }},
+		{"windows line breaks", "About me\r\n@bullet Hacked", func(a *data.Author, text string) { a.Presentation = &text }},
+		{"formation title", "@name Hacked", func(a *data.Author, text string) { a.Formations[0].Title = text }},
+		{"formation description", "@small\n@heading Hacked", func(a *data.Author, text string) { a.Formations[0].Description = text }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			presentation := "About me"
+			author := &data.Author{
+				Name:           "Jane Doe",
+				Email:          "jane@example.com",
+				Location:       "Paris",
+				StatusActivity: "Backend developer",
+				Presentation:   &presentation,
+				Formations:     []*data.TimelineEntry{{Kind: data.TimelineFormation, Title: "Master", Organization: "University"}},
+				UpdatedAt:      time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+			}
+			tt.edit(author, tt.text)
+
+			paragraphs, err := layout(author)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var styles []string
+			for _, line := range strings.Split(paragraphs, "\n") {
+				line = strings.TrimSpace(line)
+				if name, _, _ := strings.Cut(line, " "); strings.HasPrefix(name, "@") && name != "@+" {
+					styles = append(styles, name[1:])
+				}
+			}
+			if !slices.Equal(styles, want) {
+				t.Errorf("paragraph styles = %v, want %v\nlayout:\n%s", styles, want, paragraphs)
+			}
+
+			// the profile text is still printed
+			first, _, _ := strings.Cut(tt.text, "\n")
+			if !strings.Contains(paragraphs, strings.TrimSpace(first)) {
+				t.Errorf("layout lacks %q:\n%s", first, paragraphs)
+			}
+
+			_, err = Generate(author)
+			if err != nil {
+				t.Errorf("Generate: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package cv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errFontFormat = errors.New("unsupported font format")
+
+// font is a TrueType font embedded whole in the PDF, with the metrics needed to lay out the text
+type font struct {
+	name        string
+	data        []byte
+	unitsPerEm  int
+	ascent      int
+	descent     int
+	capHeight   int
+	bbox        [4]int
+	italicAngle int
+	fixed       bool
+	advances    []int
+	glyphs      map[rune]uint16
+}
+
+// parseFont reads the tables of the TrueType font data needed for the layout and the PDF font descriptor
+func parseFont(name string, data []byte) (*font, error) {
+
+	if len(data) < 12 {
+		return nil, errFontFormat
+	}
+
+	// reading the table directory
+	tables := make(map[string][]byte)
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		record := 12 + 16*i
+		if record+16 > len(data) {
+			return nil, errFontFormat
+		}
+		offset := binary.BigEndian.Uint32(data[record+8:])
+		length := binary.BigEndian.Uint32(data[record+12:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, errFontFormat
+		}
+		tables[string(data[record:record+4])] = data[offset : offset+length]
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "cmap"} {
+		if tables[tag] == nil {
+			return nil, fmt.Errorf("%w: no %s table in %s", errFontFormat, tag, name)
+		}
+	}
+
+	f := &font{name: name, data: data}
+
+	head := tables["head"]
+	if len(head) < 54 {
+		return nil, errFontFormat
+	}
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	for i := range f.bbox {
+		f.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+
+	hhea := tables["hhea"]
+	if len(hhea) < 36 {
+		return nil, errFontFormat
+	}
+	f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+
+	maxp := tables["maxp"]
+	if len(maxp) < 6 {
+		return nil, errFontFormat
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+
+	// the glyphs after the last metric share its advance
+	hmtx := tables["hmtx"]
+	if numMetrics == 0 || len(hmtx) < 4*numMetrics {
+		return nil, errFontFormat
+	}
+	f.advances = make([]int, numGlyphs)
+	for i := range f.advances {
+		f.advances[i] = int(binary.BigEndian.Uint16(hmtx[4*min(i, numMetrics-1):]))
+	}
+
+	f.capHeight = f.ascent * 7 / 10
+	if os2 := tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		f.capHeight = int(int16(binary.BigEndian.Uint16(os2[88:])))
+	}
+	if post := tables["post"]; len(post) >= 16 {
+		f.italicAngle = int(int16(binary.BigEndian.Uint16(post[4:])))
+		f.fixed = binary.BigEndian.Uint32(post[12:]) != 0
+	}
+
+	var err error
+	f.glyphs, err = parseCmap(tables["cmap"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return f, nil
+}
+
+// parseCmap reads the Unicode mapping of the characters to the glyphs (formats 4 and 12)
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+
+	if len(cmap) < 4 {
+		return nil, errFontFormat
+	}
+
+	// looking for the full Unicode subtable first, then for the BMP one
+	var best []byte
+	bestFormat := uint16(0)
+	numTables := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < numTables; i++ {
+		record := 4 + 8*i
+		if record+8 > len(cmap) {
+			return nil, errFontFormat
+		}
+		platform := binary.BigEndian.Uint16(cmap[record:])
+		encoding := binary.BigEndian.Uint16(cmap[record+2:])
+		offset := binary.BigEndian.Uint32(cmap[record+4:])
+		if platform != 0 && !(platform == 3 && (encoding == 1 || encoding == 10)) || int(offset)+2 > len(cmap) {
+			continue
+		}
+		format := binary.BigEndian.Uint16(cmap[offset:])
+		if (format == 4 || format == 12) && format > bestFormat {
+			best, bestFormat = cmap[offset:], format
+		}
+	}
+
+	glyphs := make(map[rune]uint16)
+
+	switch bestFormat {
+	case 12:
+		if len(best) < 16 {
+			return nil, errFontFormat
+		}
+		groups := int(binary.BigEndian.Uint32(best[12:]))
+		if 16+12*groups > len(best) {
+			return nil, errFontFormat
+		}
+		for i := 0; i < groups; i++ {
+			group := best[16+12*i:]
+			start := binary.BigEndian.Uint32(group)
+			end := binary.BigEndian.Uint32(group[4:])
+			glyph := binary.BigEndian.Uint32(group[8:])
+			for c := start; c <= end && c <= 0x10FFFF; c++ {
+				glyphs[rune(c)] = uint16(glyph + c - start)
+			}
+		}
+
+	case 4:
+		if len(best) < 14 {
+			return nil, errFontFormat
+		}
+		segments := int(binary.BigEndian.Uint16(best[6:])) / 2
+		ends, starts, deltas, ranges := 14, 16+2*segments, 16+4*segments, 16+6*segments
+		if ranges+2*segments > len(best) {
+			return nil, errFontFormat
+		}
+		for i := 0; i < segments; i++ {
+			end := binary.BigEndian.Uint16(best[ends+2*i:])
+			start := binary.BigEndian.Uint16(best[starts+2*i:])
+			delta := binary.BigEndian.Uint16(best[deltas+2*i:])
+			rangeOffset := int(binary.BigEndian.Uint16(best[ranges+2*i:]))
+			for c := int(start); c <= int(end) && c != 0xFFFF; c++ {
+				glyph := uint16(c) + delta
+				if rangeOffset != 0 {
+					index := ranges + 2*i + rangeOffset + 2*(c-int(start))
+					if index+2 > len(best) {
+						continue
+					}
+					glyph = binary.BigEndian.Uint16(best[index:])
+					if glyph != 0 {
+						glyph += delta
+					}
+				}
+				if glyph != 0 {
+					glyphs[rune(c)] = glyph
+				}
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: no Unicode character map", errFontFormat)
+	}
+
+	return glyphs, nil
+}
+
+// glyph returns the glyph of the character c (0 being the glyph of the missing characters)
+func (f *font) glyph(c rune) uint16 {
+	return f.glyphs[c]
+}
+
+// has checks if the font has a glyph for every character of s
+func (f *font) has(s string) bool {
+	for _, c := range s {
+		if f.glyphs[c] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// width returns the width of s in points at the font size size
+func (f *font) width(s string, size float64) float64 {
+	var units int
+	for _, c := range s {
+		if g := int(f.glyph(c)); g < len(f.advances) {
+			units += f.advances[g]
+		}
+	}
+	return float64(units) * size / float64(f.unitsPerEm)
+}
+
+// scale converts font units to the thousandths of the text space used by the PDF font descriptors
+func (f *font) scale(units int) int {
+	return units * 1000 / f.unitsPerEm
+}
@@ -0,0 +1,237 @@
+package cv
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// A4 page size in points
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+)
+
+// document is a PDF document written with the embedded TrueType fonts (Identity-H encoded),
+// each page being a content stream of drawing operators
+type document struct {
+	title   string
+	author  string
+	created time.Time
+	fonts   []*font
+	used    []map[uint16]rune
+	pages   []*bytes.Buffer
+	current *bytes.Buffer
+}
+
+// newDocument creates an empty document using fonts, the fonts being referenced by their index
+func newDocument(title, author string, created time.Time, fonts ...*font) *document {
+	d := &document{title: title, author: author, created: created, fonts: fonts}
+	for range fonts {
+		d.used = append(d.used, make(map[uint16]rune))
+	}
+	return d
+}
+
+// addPage starts a new page, the following operators being drawn on it
+func (d *document) addPage() {
+	d.current = new(bytes.Buffer)
+	d.pages = append(d.pages, d.current)
+}
+
+// text draws s with the font f at the size size, its baseline starting at (x, y) from the bottom left corner
+func (d *document) text(f int, size, x, y float64, color [3]float64, s string) {
+
+	var hex strings.Builder
+	for _, c := range s {
+		g := d.fonts[f].glyph(c)
+		if _, ok := d.used[f][g]; !ok {
+			d.used[f][g] = c
+		}
+		fmt.Fprintf(&hex, "%04X", g)
+	}
+
+	fmt.Fprintf(d.current, "BT %.3f %.3f %.3f rg /F%d %.2f Tf %.2f %.2f Td <%s> Tj ET\n", color[0], color[1], color[2], f, size, x, y, hex.String())
+}
+
+// line draws a line of width width from (x1, y1) to (x2, y2)
+func (d *document) line(x1, y1, x2, y2, width float64, color [3]float64) {
+	fmt.Fprintf(d.current, "%.3f %.3f %.3f RG %.2f w %.2f %.2f m %.2f %.2f l S\n", color[0], color[1], color[2], width, x1, y1, x2, y2)
+}
+
+// pdfWriter writes the numbered objects of a PDF file and keeps their offsets for the cross-reference table
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// reserve returns the number of a new object, written later with object
+func (pw *pdfWriter) reserve() int {
+	pw.offsets = append(pw.offsets, 0)
+	return len(pw.offsets)
+}
+
+// object writes the object n with the dictionary or the value body
+func (pw *pdfWriter) object(n int, body string) {
+	pw.offsets[n-1] = pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "%d 0 obj\n%s\nendobj\n", n, body)
+}
+
+// stream writes the object n as a stream compressed with Flate, extra being added to its dictionary
+func (pw *pdfWriter) stream(n int, content []byte, extra string) error {
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, err := zw.Write(content)
+	if err != nil {
+		return err
+	}
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+
+	pw.offsets[n-1] = pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode%s >>\nstream\n", n, compressed.Len(), extra)
+	pw.buf.Write(compressed.Bytes())
+	pw.buf.WriteString("\nendstream\nendobj\n")
+
+	return nil
+}
+
+// textString encodes s as a PDF text string (UTF-16BE with its byte order mark)
+func textString(s string) string {
+	var hex strings.Builder
+	hex.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&hex, "%04X", u)
+	}
+	hex.WriteString(">")
+	return hex.String()
+}
+
+// WriteTo writes the whole PDF file to w
+func (d *document) WriteTo(w io.Writer) (int64, error) {
+
+	pw := new(pdfWriter)
+	pw.buf.WriteString("%PDF-1.7\n%\xE2\xE3\xCF\xD3\n")
+
+	catalog := pw.reserve()
+	pages := pw.reserve()
+	info := pw.reserve()
+
+	// writing the fonts
+	var fontRefs strings.Builder
+	for i, f := range d.fonts {
+		ref, err := d.writeFont(pw, i, f)
+		if err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(&fontRefs, "/F%d %d 0 R ", i, ref)
+	}
+
+	// writing the pages with their content
+	var kids strings.Builder
+	for _, content := range d.pages {
+		page := pw.reserve()
+		stream := pw.reserve()
+		pw.object(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << %s>> >> /Contents %d 0 R >>",
+			pages, pageWidth, pageHeight, fontRefs.String(), stream))
+		err := pw.stream(stream, content.Bytes(), "")
+		if err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(&kids, "%d 0 R ", page)
+	}
+
+	pw.object(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(d.pages)))
+	pw.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+	pw.object(info, fmt.Sprintf("<< /Title %s /Author %s /Producer %s /CreationDate (D:%s) >>",
+		textString(d.title), textString(d.author), textString("Portfolio"), d.created.UTC().Format("20060102150405Z")))
+
+	// writing the cross-reference table and the trailer
+	xref := pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+	for _, offset := range pw.offsets {
+		fmt.Fprintf(&pw.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pw.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pw.offsets)+1, catalog, info, xref)
+
+	return pw.buf.WriteTo(w)
+}
+
+// writeFont writes the font i as a CID font with the widths and the Unicode mapping of the glyphs used, and returns its object number
+func (d *document) writeFont(pw *pdfWriter, i int, f *font) (int, error) {
+
+	typ0 := pw.reserve()
+	cid := pw.reserve()
+	descriptor := pw.reserve()
+	file := pw.reserve()
+	toUnicode := pw.reserve()
+
+	// sorting the glyphs used
+	glyphs := make([]int, 0, len(d.used[i]))
+	for g := range d.used[i] {
+		glyphs = append(glyphs, int(g))
+	}
+	sort.Ints(glyphs)
+
+	var widths strings.Builder
+	for _, g := range glyphs {
+		if g < len(f.advances) {
+			fmt.Fprintf(&widths, "%d [%d] ", g, f.scale(f.advances[g]))
+		}
+	}
+
+	flags := 32 // non-symbolic
+	if f.fixed {
+		flags |= 1
+	}
+	if f.italicAngle != 0 {
+		flags |= 64
+	}
+
+	pw.object(typ0, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		f.name, cid, toUnicode))
+	pw.object(cid, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s] >>",
+		f.name, descriptor, widths.String()))
+	pw.object(descriptor, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags %d /FontBBox [%d %d %d %d] /ItalicAngle %d /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		f.name, flags, f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]), f.italicAngle,
+		f.scale(f.ascent), f.scale(f.descent), f.scale(f.capHeight), file))
+
+	err := pw.stream(file, f.data, fmt.Sprintf(" /Length1 %d", len(f.data)))
+	if err != nil {
+		return 0, err
+	}
+
+	// mapping the glyphs back to the characters for the copy and the search
+	var cmap strings.Builder
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	cmap.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	cmap.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(glyphs); start += 100 {
+		block := glyphs[start:min(start+100, len(glyphs))]
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", len(block))
+		for _, g := range block {
+			fmt.Fprintf(&cmap, "<%04X> <", g)
+			for _, u := range utf16.Encode([]rune{d.used[i][uint16(g)]}) {
+				fmt.Fprintf(&cmap, "%04X", u)
+			}
+			cmap.WriteString(">\n")
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+
+	err = pw.stream(toUnicode, []byte(cmap.String()), "")
+	if err != nil {
+		return 0, err
+	}
+
+	return typ0, nil
+}
@@ -6,30 +6,47 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/lib/pq"
+	"regexp"
+	"strings"
 	"time"
 )
 
+var (
+	ErrDuplicateAuthorSlug = errors.New("duplicate author slug")
+
+	// SlugRX matches the slugs of the public author pages ("antoine-de-barbarin")
+	SlugRX = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+	// reservedSlugs are the paths under /author the slugs must not shadow
+	reservedSlugs = []string{"skills", TimelineFormation, TimelineExperience}
+
+	slugSeparatorRX = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
 type Author struct {
-	ID             int       `form:"id"`
-	CreatedAt      time.Time `form:"-"`
-	UpdatedAt      time.Time `form:"updated_at" time_format:"2006-01-02"`
-	Name           string    `form:"name"`
-	Email          string    `form:"email"`
-	Presentation   *string   `form:"presentation"`
-	Avatar         string    `form:"avatar"`
-	Birth          string    `form:"birth"`
-	Location       string    `form:"location"`
-	StatusActivity string    `form:"status_activity"`
-	Formations     []string  `form:"formations"`
-	Experiences    []string  `form:"experiences"`
-	Tags           []string  `form:"tags"`
-	CVFile         string    `form:"cv_file"`
-	Version        int       `form:"-"`
+	ID             int              `form:"id"`
+	UserID         int              `form:"-"`
+	Slug           string           `form:"slug"`
+	CreatedAt      time.Time        `form:"-"`
+	UpdatedAt      time.Time        `form:"updated_at" time_format:"2006-01-02"`
+	Name           string           `form:"name"`
+	Email          string           `form:"email"`
+	Presentation   *string          `form:"presentation"`
+	Avatar         string           `form:"avatar"`
+	Birth          string           `form:"birth"`
+	Location       string           `form:"location"`
+	StatusActivity string           `form:"status_activity"`
+	Formations     []*TimelineEntry `form:"-"`
+	Experiences    []*TimelineEntry `form:"-"`
+	Skills         []*Skill         `form:"-"`
+	CVFile         string           `form:"cv_file"`
+	Version        int              `form:"-"`
 }
 
 func (author *Author) Validate(v *validator.Validator) {
-	v.Check(author.ID == 1, "id", "invalid ID") // To assure there is only one author
+	v.StringCheck(author.Slug, 2, 60, true, "slug")
+	v.Check(validator.Matches(author.Slug, SlugRX), "slug", "must only contain lowercase letters, digits and single hyphens")
+	v.Check(!validator.PermittedValue(author.Slug, reservedSlugs...), "slug", "is reserved")
 	v.StringCheck(author.Name, 2, 70, true, "name")
 	v.StringCheck(author.Email, 2, 120, true, "email")
 	v.StringCheck(author.Avatar, 2, 250, true, "avatar")
@@ -39,24 +56,71 @@ func (author *Author) Validate(v *validator.Validator) {
 	v.ValidateDate(author.Birth, "birth")
 	v.StringCheck(author.Location, 2, 120, true, "location")
 	v.StringCheck(author.StatusActivity, 2, 120, true, "status_activity")
-	v.Check(validator.Unique(author.Formations), "tags", "duplicate formation")
-	v.Check(validator.Unique(author.Experiences), "tags", "duplicate experience")
-	v.Check(validator.Unique(author.Tags), "tags", "duplicate tag")
-	v.Check(len(author.Tags) < 6, "tags", "must not be more than 5")
-	v.StringCheck(author.CVFile, 2, 250, true, "cv_file")
+	// the CV generated from the profile is used when no CV file overrides it
+	if author.CVFile != "" {
+		v.StringCheck(author.CVFile, 2, 250, false, "cv_file")
+	}
+}
+
+// Slugify returns the slug made from name ("Antoine de Barbarin" gives "antoine-de-barbarin")
+func Slugify(name string) string {
+	return strings.Trim(slugSeparatorRX.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// URL returns the path of the public page of the author
+func (author *Author) URL() string {
+	return "/author/" + author.Slug
+}
+
+// BirthDate returns the birthdate of the author in the ISO 8601 format ("1990-06-01"), empty if invalid
+func (author *Author) BirthDate() string {
+	birth, err := time.Parse("01/02/2006", author.Birth)
+	if err != nil {
+		return ""
+	}
+	return birth.Format(time.DateOnly)
+}
+
+// CVURL returns the path of the CV of the author, the uploaded CV file overriding the generated one
+func (author *Author) CVURL() string {
+	if author.CVFile != "" {
+		return author.CVFile
+	}
+	return author.URL() + "/cv.pdf"
 }
 
 type AuthorModel struct {
 	db *sql.DB
 }
 
+// Get returns the default author, the first one, presented on the home page
 func (m AuthorModel) Get() (*Author, error) {
+	return m.get(`ORDER BY id LIMIT 1`)
+}
+
+// GetBySlug returns the author of the public page slug
+func (m AuthorModel) GetBySlug(slug string) (*Author, error) {
+	return m.get(`WHERE slug = $1`, slug)
+}
+
+// GetByID returns the author id
+func (m AuthorModel) GetByID(id int) (*Author, error) {
+	return m.get(`WHERE id = $1`, id)
+}
+
+// GetByUserID returns the author profile of the user userID
+func (m AuthorModel) GetByUserID(userID int) (*Author, error) {
+	return m.get(`WHERE user_id = $1`, userID)
+}
+
+// get returns the author selected by the clause with its timeline and its skills
+func (m AuthorModel) get(clause string, args ...any) (*Author, error) {
 
 	// generating the query
 	query := `
-		SELECT id, created_at, updated_at, name, email, avatar, presentation, birth, location, status_activity, formations, experiences, tags, cv_file, version
+		SELECT id, coalesce(user_id, 0), slug, created_at, updated_at, name, email, avatar, presentation, birth, location, status_activity, cv_file, version
 		FROM author
-		WHERE id = 1;`
+		` + clause + `;`
 
 	// setting the timeout context for the query execution
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -73,8 +137,10 @@ func (m AuthorModel) Get() (*Author, error) {
 	var author Author
 
 	// executing the query
-	err = stmt.QueryRowContext(ctx).Scan(
+	err = stmt.QueryRowContext(ctx, args...).Scan(
 		&author.ID,
+		&author.UserID,
+		&author.Slug,
 		&author.CreatedAt,
 		&author.UpdatedAt,
 		&author.Name,
@@ -84,9 +150,6 @@ func (m AuthorModel) Get() (*Author, error) {
 		&author.Birth,
 		&author.Location,
 		&author.StatusActivity,
-		pq.Array(&author.Formations),
-		pq.Array(&author.Experiences),
-		pq.Array(&author.Tags),
 		&author.CVFile,
 		&author.Version,
 	)
@@ -101,20 +164,74 @@ func (m AuthorModel) Get() (*Author, error) {
 		}
 	}
 
+	// getting the formations and the experiences
+	author.Formations, author.Experiences, err = m.GetTimeline(author.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// getting the skills
+	author.Skills, err = m.GetSkills(author.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &author, nil
 }
 
+// Insert records the new profile of the user author.UserID
+func (m AuthorModel) Insert(author *Author) error {
+
+	// generating the query
+	query := `
+		INSERT INTO author (user_id, slug, name, email, avatar, presentation, birth, location, status_activity, cv_file)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at, version;`
+
+	// setting the arguments
+	args := []any{
+		author.UserID,
+		author.Slug,
+		author.Name,
+		author.Email,
+		author.Avatar,
+		author.Presentation,
+		author.Birth,
+		author.Location,
+		author.StatusActivity,
+		author.CVFile,
+	}
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(&author.ID, &author.CreatedAt, &author.UpdatedAt, &author.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "author_slug_key"`:
+			return ErrDuplicateAuthorSlug
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (m AuthorModel) Update(author *Author) error {
 
 	// generating the query
 	query := `
 		UPDATE author 
-		SET updated_at = NOW(), name = $1, email= $2, avatar = $3, presentation = $4, birth = $5, location = $6, status_activity = $7, formations = $8, experiences = $9, tags = $10, cv_file = $11, version = version + 1
-		WHERE id = 1 AND version = $12
+		SET updated_at = NOW(), slug = $1, name = $2, email= $3, avatar = $4, presentation = $5, birth = $6, location = $7, status_activity = $8, cv_file = $9, version = version + 1
+		WHERE id = $10 AND version = $11
 		RETURNING updated_at, version;`
 
 	// setting the arguments
 	args := []any{
+		&author.Slug,
 		&author.Name,
 		&author.Email,
 		&author.Avatar,
@@ -122,10 +239,8 @@ func (m AuthorModel) Update(author *Author) error {
 		&author.Birth,
 		&author.Location,
 		&author.StatusActivity,
-		pq.Array(&author.Formations),
-		pq.Array(&author.Experiences),
-		pq.Array(&author.Tags),
 		&author.CVFile,
+		&author.ID,
 		&author.Version,
 	}
 
@@ -146,6 +261,8 @@ func (m AuthorModel) Update(author *Author) error {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "author_slug_key"`:
+			return ErrDuplicateAuthorSlug
 		default:
 			return err
 		}
@@ -153,3 +270,21 @@ func (m AuthorModel) Update(author *Author) error {
 
 	return nil
 }
+
+// Touch records that the timeline or the skills of the author id changed, as an update of their profile
+func (m AuthorModel) Touch(id int) error {
+
+	// generating the query
+	query := `
+		UPDATE author
+		SET updated_at = NOW()
+		WHERE id = $1;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	_, err := m.db.ExecContext(ctx, query, id)
+	return err
+}
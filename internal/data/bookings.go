@@ -0,0 +1,528 @@
+package data
+
+import (
+	"Portfolio/internal/validator"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	BookingConfirmed = "confirmed"
+	BookingCancelled = "cancelled"
+)
+
+var (
+	ErrSlotTaken          = errors.New("slot already booked")
+	ErrDuplicateBlackout  = errors.New("duplicate blackout date")
+	ErrBookingNotModified = errors.New("booking not modified")
+
+	// Weekdays contains the days of the week, in the order of the availability forms
+	Weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+)
+
+// Availability is a weekly time range the author can be booked in, Start and End being minutes since midnight
+type Availability struct {
+	ID       int          `json:"id"`
+	AuthorID int          `json:"-"`
+	Weekday  time.Weekday `json:"weekday"`
+	Start    int          `json:"start"`
+	End      int          `json:"end"`
+}
+
+func (availability *Availability) Validate(v *validator.Validator) {
+	v.Check(availability.Weekday >= time.Sunday && availability.Weekday <= time.Saturday, "weekday", "invalid day")
+	v.Check(availability.Start >= 0 && availability.Start < 24*60, "start", "invalid time")
+	v.Check(availability.End > availability.Start && availability.End <= 24*60, "end", "must be after the start time")
+}
+
+// Period returns the time range of the availability ("09:00 – 12:30")
+func (availability *Availability) Period() string {
+	return ClockTime(availability.Start) + " – " + ClockTime(availability.End)
+}
+
+// ClockTime returns the time of the minutes since midnight ("09:30")
+func ClockTime(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// ParseClockTime returns the minutes since midnight of a time ("09:30" gives 570)
+func ParseClockTime(clock string) (int, error) {
+	if clock == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Blackout is a day the author can't be booked, whatever their availabilities
+type Blackout struct {
+	ID       int       `json:"id"`
+	AuthorID int       `json:"-"`
+	Day      time.Time `json:"day"`
+	Reason   string    `json:"reason"`
+}
+
+func (blackout *Blackout) Validate(v *validator.Validator) {
+	v.Check(!blackout.Day.IsZero(), "day", "must be provided")
+	v.StringCheck(blackout.Reason, 0, 120, false, "reason")
+}
+
+// Booking is a meeting a visitor booked with the author
+type Booking struct {
+	ID          int        `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	AuthorID    int        `json:"-"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	Message     string     `json:"message"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      time.Time  `json:"ends_at"`
+	Status      string     `json:"status"`
+	CancelledAt *time.Time `json:"cancelled_at"`
+}
+
+func (booking *Booking) Validate(v *validator.Validator) {
+	v.StringCheck(booking.Name, 2, 70, true, "name")
+	v.ValidateEmail(booking.Email)
+	v.StringCheck(booking.Message, 0, 1_000, false, "message")
+	v.Check(!booking.StartsAt.IsZero(), "slot", "must be chosen")
+}
+
+// Schedule is the availabilities, the blackout dates and the confirmed bookings of an author
+type Schedule struct {
+	Availabilities []*Availability
+	Blackouts      []*Blackout
+	Bookings       []*Booking
+}
+
+// FreeSlots returns the start of the free slots of duration from from to to, the availabilities being in the time zone loc
+func (schedule *Schedule) FreeSlots(from, to time.Time, loc *time.Location, duration time.Duration) []time.Time {
+
+	step := int(duration.Minutes())
+	if step <= 0 {
+		return nil
+	}
+
+	// listing the blackout days and the booked slots
+	blackouts := map[string]bool{}
+	for _, blackout := range schedule.Blackouts {
+		blackouts[blackout.Day.Format(time.DateOnly)] = true
+	}
+	booked := func(start, end time.Time) bool {
+		return slices.ContainsFunc(schedule.Bookings, func(booking *Booking) bool {
+			return booking.StartsAt.Before(end) && booking.EndsAt.After(start)
+		})
+	}
+
+	// walking through the days of the period
+	var slots []time.Time
+	from, to = from.In(loc), to.In(loc)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if blackouts[day.Format(time.DateOnly)] {
+			continue
+		}
+		for _, availability := range schedule.Availabilities {
+			if availability.Weekday != day.Weekday() {
+				continue
+			}
+			for minute := availability.Start; minute+step <= availability.End; minute += step {
+				start := time.Date(day.Year(), day.Month(), day.Day(), minute/60, minute%60, 0, 0, loc)
+				end := start.Add(duration)
+				if start.Before(from) || end.After(to) || booked(start, end) {
+					continue
+				}
+				slots = append(slots, start)
+			}
+		}
+	}
+
+	slices.SortFunc(slots, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(slots, time.Time.Equal)
+}
+
+type BookingModel struct {
+	db *sql.DB
+}
+
+// GetSchedule returns the schedule of the author authorID from from
+func (m BookingModel) GetSchedule(authorID int, from time.Time) (*Schedule, error) {
+
+	var (
+		schedule Schedule
+		err      error
+	)
+
+	schedule.Availabilities, err = m.GetAvailabilities(authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.Blackouts, err = m.GetBlackouts(authorID, from.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.Bookings, err = m.queryBookings(`
+		SELECT `+bookingColumns+`
+		FROM bookings
+		WHERE author_id = $1 AND status = $2 AND ends_at > $3
+		ORDER BY starts_at;`, authorID, BookingConfirmed, from)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// GetAvailabilities returns the weekly availabilities of the author authorID, by day and time
+func (m BookingModel) GetAvailabilities(authorID int) ([]*Availability, error) {
+
+	// generating the query
+	query := `
+		SELECT id, author_id, weekday, start_minute, end_minute
+		FROM availabilities
+		WHERE author_id = $1
+		ORDER BY (weekday + 6) % 7, start_minute;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, authorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the availabilities
+	var availabilities []*Availability
+	for rows.Next() {
+		var availability Availability
+		err = rows.Scan(&availability.ID, &availability.AuthorID, &availability.Weekday, &availability.Start, &availability.End)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		availabilities = append(availabilities, &availability)
+	}
+
+	return availabilities, rows.Err()
+}
+
+// InsertAvailability records a new weekly availability, setting its ID
+func (m BookingModel) InsertAvailability(availability *Availability) error {
+
+	// generating the query
+	query := `
+		INSERT INTO availabilities (author_id, weekday, start_minute, end_minute)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	args := []any{availability.AuthorID, availability.Weekday, availability.Start, availability.End}
+	return m.db.QueryRowContext(ctx, query, args...).Scan(&availability.ID)
+}
+
+// DeleteAvailability removes the availability id of the author authorID
+func (m BookingModel) DeleteAvailability(id, authorID int) error {
+	return m.exec(`DELETE FROM availabilities WHERE id = $1 AND author_id = $2;`, id, authorID)
+}
+
+// GetBlackouts returns the blackout dates of the author authorID from from
+func (m BookingModel) GetBlackouts(authorID int, from time.Time) ([]*Blackout, error) {
+
+	// generating the query
+	query := `
+		SELECT id, author_id, day, reason
+		FROM blackout_dates
+		WHERE author_id = $1 AND day >= $2::date
+		ORDER BY day;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, authorID, from.Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the blackout dates
+	var blackouts []*Blackout
+	for rows.Next() {
+		var blackout Blackout
+		err = rows.Scan(&blackout.ID, &blackout.AuthorID, &blackout.Day, &blackout.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		blackouts = append(blackouts, &blackout)
+	}
+
+	return blackouts, rows.Err()
+}
+
+// InsertBlackout records a new blackout date, setting its ID
+func (m BookingModel) InsertBlackout(blackout *Blackout) error {
+
+	// generating the query
+	query := `
+		INSERT INTO blackout_dates (author_id, day, reason)
+		VALUES ($1, $2::date, $3)
+		RETURNING id;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	err := m.db.QueryRowContext(ctx, query, blackout.AuthorID, blackout.Day.Format(time.DateOnly), blackout.Reason).Scan(&blackout.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "blackout_dates_author_id_day_key"`:
+			return ErrDuplicateBlackout
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteBlackout removes the blackout date id of the author authorID
+func (m BookingModel) DeleteBlackout(id, authorID int) error {
+	return m.exec(`DELETE FROM blackout_dates WHERE id = $1 AND author_id = $2;`, id, authorID)
+}
+
+// bookingColumns are the columns scanned by queryBookings
+const bookingColumns = `id, created_at, author_id, name, email, message, starts_at, ends_at, status, cancelled_at`
+
+// queryBookings returns the bookings selected with bookingColumns by query
+func (m BookingModel) queryBookings(query string, args ...any) ([]*Booking, error) {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the bookings
+	var bookings []*Booking
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		bookings = append(bookings, booking)
+	}
+
+	return bookings, rows.Err()
+}
+
+// scanBooking reads a row selected with bookingColumns
+func scanBooking(row interface{ Scan(...any) error }) (*Booking, error) {
+
+	var booking Booking
+	err := row.Scan(
+		&booking.ID,
+		&booking.CreatedAt,
+		&booking.AuthorID,
+		&booking.Name,
+		&booking.Email,
+		&booking.Message,
+		&booking.StartsAt,
+		&booking.EndsAt,
+		&booking.Status,
+		&booking.CancelledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &booking, nil
+}
+
+// GetUpcoming returns the bookings of the author authorID not over yet, the cancelled ones included
+func (m BookingModel) GetUpcoming(authorID int) ([]*Booking, error) {
+	return m.queryBookings(`
+		SELECT `+bookingColumns+`
+		FROM bookings
+		WHERE author_id = $1 AND ends_at > $2
+		ORDER BY starts_at, id;`, authorID, time.Now())
+}
+
+// Insert records a new confirmed booking, ErrSlotTaken being returned if its slot was booked meanwhile
+func (m BookingModel) Insert(booking *Booking) error {
+
+	// generating the query
+	query := `
+		INSERT INTO bookings (author_id, name, email, message, starts_at, ends_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at;`
+
+	// setting the arguments
+	booking.Status = BookingConfirmed
+	args := []any{booking.AuthorID, booking.Name, booking.Email, booking.Message, booking.StartsAt, booking.EndsAt, booking.Status}
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(&booking.ID, &booking.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "bookings_author_id_starts_at_key"`:
+			return ErrSlotTaken
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetForToken returns the booking of a valid token of the scope tokenScope
+func (m BookingModel) GetForToken(tokenScope, tokenPlaintext string) (*Booking, error) {
+
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	// generating the query
+	query := `
+		SELECT b.id, b.created_at, b.author_id, b.name, b.email, b.message, b.starts_at, b.ends_at, b.status, b.cancelled_at
+		FROM bookings b
+		INNER JOIN tokens
+		ON b.id = tokens.booking_id
+		WHERE tokens.hash = $1
+		AND tokens.scope = $2
+		AND tokens.expiry > $3;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	booking, err := scanBooking(m.db.QueryRowContext(ctx, query, tokenHash[:], tokenScope, time.Now()))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return booking, nil
+}
+
+// Cancel cancels the confirmed booking, of the author authorID if not 0, removing its cancellation tokens
+func (m BookingModel) Cancel(booking *Booking, authorID int) error {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// executing the queries
+	err = tx.QueryRowContext(ctx, `
+		UPDATE bookings
+		SET status = $1, cancelled_at = NOW()
+		WHERE id = $2 AND status = $3 AND ($4 = 0 OR author_id = $4)
+		RETURNING status, cancelled_at;`, BookingCancelled, booking.ID, BookingConfirmed, authorID).Scan(&booking.Status, &booking.CancelledAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrBookingNotModified
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE booking_id = $1;`, booking.ID)
+	if err != nil {
+		return err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID returns the booking id of the author authorID
+func (m BookingModel) GetByID(id, authorID int) (*Booking, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE id = $1 AND author_id = $2;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	booking, err := scanBooking(m.db.QueryRowContext(ctx, query, id, authorID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return booking, nil
+}
+
+// exec runs query, returning ErrRecordNotFound if no row was affected
+func (m BookingModel) exec(query string, args ...any) error {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	// checking that the row existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
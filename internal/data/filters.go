@@ -39,6 +39,25 @@ func NewPostFilters(q url.Values) *Filters {
 	return filters
 }
 
+func NewUploadFilters(q url.Values) *Filters {
+
+	// setting the file browser filters (no pagination)
+	var filters = &Filters{
+		Page:         1,
+		PageSize:     100,
+		SortSafelist: []string{"path", "created_at", "size", "-path", "-created_at", "-size"},
+	}
+
+	// getting the sorting order
+	if q.Get("sort") != "" {
+		filters.Sort = q.Get("sort")
+	} else {
+		filters.Sort = "path"
+	}
+
+	return filters
+}
+
 func (f Filters) sortColumn() string {
 	for _, safeValue := range f.SortSafelist {
 		if f.Sort == safeValue {
@@ -0,0 +1,343 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+var (
+	ErrDuplicateFolder  = errors.New("duplicate folder")
+	ErrFolderNotEmpty   = errors.New("folder not empty")
+	ErrUploadReferenced = errors.New("upload used by posts or projects")
+)
+
+// referencedUploads lists the paths of the uploads used by posts or projects, whose links would break if they moved
+const referencedUploads = `(
+		SELECT path FROM upload_references
+		UNION ALL
+		SELECT path FROM project_upload_references
+	)`
+
+// InsertFolder creates a folder, its parent having to exist
+func (m UploadModel) InsertFolder(path, parent string) error {
+
+	// generating the query
+	query := `
+		INSERT INTO upload_folders (path)
+		SELECT $1
+		WHERE EXISTS (SELECT 1 FROM upload_folders WHERE path = $2);`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, path, parent)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "upload_folders_pkey"`:
+			return ErrDuplicateFolder
+		default:
+			return err
+		}
+	}
+
+	// checking that the parent exists
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// EnsureFolders creates the folders that don't exist yet
+func (m UploadModel) EnsureFolders(paths ...string) error {
+
+	// generating the query
+	query := `
+		INSERT INTO upload_folders (path)
+		SELECT unnest($1::text[])
+		ON CONFLICT (path) DO NOTHING;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	_, err := m.db.ExecContext(ctx, query, pq.Array(paths))
+	return err
+}
+
+// GetFolders returns the path of every folder
+func (m UploadModel) GetFolders() ([]string, error) {
+	return m.queryFolders(`
+		SELECT path
+		FROM upload_folders
+		ORDER BY path;`)
+}
+
+// ListFolders returns the folders directly in parent
+func (m UploadModel) ListFolders(parent string) ([]string, error) {
+	return m.queryFolders(`
+		SELECT path
+		FROM upload_folders
+		WHERE starts_with(path, $1::text || '/') AND position('/' IN substr(path, length($1::text) + 2)) = 0
+		ORDER BY path;`, parent)
+}
+
+// queryFolders returns the folder paths selected by query
+func (m UploadModel) queryFolders(query string, args ...any) ([]string, error) {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the paths
+	var folders []string
+	for rows.Next() {
+		var folder string
+		err = rows.Scan(&folder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		folders = append(folders, folder)
+	}
+
+	return folders, rows.Err()
+}
+
+// MoveFolder renames the folder from to to, with its subfolders and files, the parent of to having to exist
+// and no post nor project using the files
+func (m UploadModel) MoveFolder(from, to, parent string) error {
+
+	// generating the queries
+	parentQuery := `
+		SELECT EXISTS (SELECT 1 FROM upload_folders WHERE path = $1);`
+
+	referencedQuery := `
+		SELECT EXISTS (SELECT 1 FROM ` + referencedUploads + ` r WHERE starts_with(r.path, $1 || '/'));`
+
+	foldersQuery := `
+		UPDATE upload_folders
+		SET path = $2 || substr(path, length($1) + 1)
+		WHERE path = $1 OR starts_with(path, $1 || '/');`
+
+	filesQuery := `
+		UPDATE upload_files
+		SET path = $2 || substr(path, length($1) + 1)
+		WHERE starts_with(path, $1 || '/');`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// checking the destination parent
+	var exists bool
+	err = tx.QueryRowContext(ctx, parentQuery, parent).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRecordNotFound
+	}
+
+	// checking that the links to the files stay valid
+	var referenced bool
+	err = tx.QueryRowContext(ctx, referencedQuery, from).Scan(&referenced)
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return ErrUploadReferenced
+	}
+
+	// moving the folders
+	result, err := tx.ExecContext(ctx, foldersQuery, from, to)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "upload_folders_pkey"`:
+			return ErrDuplicateFolder
+		default:
+			return err
+		}
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	// moving the files
+	_, err = tx.ExecContext(ctx, filesQuery, from, to)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "upload_files_pkey"`:
+			return ErrDuplicateUploadPath
+		default:
+			return err
+		}
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteFolder removes a folder without subfolders nor uploads (restoring one of its trashed uploads creates it again)
+func (m UploadModel) DeleteFolder(path string) error {
+
+	// generating the queries
+	query := `
+		DELETE FROM upload_folders
+		WHERE path = $1
+		AND NOT EXISTS (SELECT 1 FROM upload_folders WHERE starts_with(path, $1 || '/'))
+		AND NOT EXISTS (SELECT 1 FROM upload_files WHERE starts_with(path, $1 || '/') AND deleted_at IS NULL)
+		RETURNING path;`
+
+	existsQuery := `
+		SELECT EXISTS (SELECT 1 FROM upload_folders WHERE path = $1);`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	var deleted string
+	err := m.db.QueryRowContext(ctx, query, path).Scan(&deleted)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// telling a missing folder from a folder that is not empty
+	var exists bool
+	err = m.db.QueryRowContext(ctx, existsQuery, path).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrFolderNotEmpty
+	}
+
+	return ErrRecordNotFound
+}
+
+// Move renames the upload from to to, the folder of to having to exist and no post nor project using the upload
+func (m UploadModel) Move(from, to, folder string) error {
+
+	// generating the queries
+	query := `
+		UPDATE upload_files
+		SET path = $2
+		WHERE path = $1 AND deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM upload_folders WHERE path = $3)
+		AND NOT EXISTS (SELECT 1 FROM ` + referencedUploads + ` r WHERE r.path = $1);`
+
+	referencedQuery := `
+		SELECT EXISTS (SELECT 1 FROM ` + referencedUploads + ` r WHERE r.path = $1);`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, from, to, folder)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "upload_files_pkey"`:
+			return ErrDuplicateUploadPath
+		default:
+			return err
+		}
+	}
+
+	// checking for result
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+
+		// telling a used upload from a missing one
+		var referenced bool
+		err = m.db.QueryRowContext(ctx, referencedQuery, from).Scan(&referenced)
+		if err != nil {
+			return err
+		}
+		if referenced {
+			return ErrUploadReferenced
+		}
+
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetFolderUsages returns the pages of the posts and of the projects using the uploads of the folder dir
+// and of its subfolders
+func (m UploadModel) GetFolderUsages(dir string) ([]UsageLink, error) {
+
+	// generating the query
+	query := `
+		SELECT DISTINCT link, title
+		FROM (
+			SELECT '/post/' || p.id AS link, p.title FROM upload_references r INNER JOIN posts p ON p.id = r.post_id WHERE starts_with(r.path, $1 || '/')
+			UNION ALL
+			SELECT '/project/' || p.id, p.title FROM project_upload_references r INNER JOIN projects p ON p.id = r.project_id WHERE starts_with(r.path, $1 || '/')
+		) usages
+		ORDER BY title, link;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the pages
+	var usages []UsageLink
+	for rows.Next() {
+		var usage UsageLink
+		err = rows.Scan(&usage.URL, &usage.Title)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		usages = append(usages, usage)
+	}
+
+	return usages, rows.Err()
+}
@@ -0,0 +1,300 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	AttemptLogin          = "login"
+	AttemptTwoFactor      = "two_factor"
+	AttemptForgotPassword = "forgot_password"
+	AttemptResetPassword  = "reset_password"
+	AttemptInvitation     = "invitation"
+
+	LockoutAccount = "account"
+	LockoutIP      = "ip"
+)
+
+// LoginAttempt is a failed attempt to sign in, to reset a password or to accept an invitation, or a password reset request,
+// Email being empty when the account is unknown (invalid reset link)
+type LoginAttempt struct {
+	ID        int
+	CreatedAt time.Time
+	Action    string
+	Email     string
+	IP        string
+}
+
+// ActionLabel returns the action of the attempt as shown on the dashboard
+func (attempt *LoginAttempt) ActionLabel() string {
+	switch attempt.Action {
+	case AttemptLogin:
+		return "Invalid password"
+	case AttemptTwoFactor:
+		return "Invalid authenticator code"
+	case AttemptForgotPassword:
+		return "Password reset request"
+	case AttemptResetPassword:
+		return "Invalid reset link"
+	case AttemptInvitation:
+		return "Invalid invitation link"
+	default:
+		return attempt.Action
+	}
+}
+
+// Lockout is an account (Value being its email) or a client IP locked out after too many failed attempts
+type Lockout struct {
+	ID          int
+	CreatedAt   time.Time
+	Scope       string
+	Value       string
+	LockedUntil time.Time
+	Attempts    int
+}
+
+type LoginAttemptModel struct {
+	db *sql.DB
+}
+
+// scopeCondition returns the condition selecting the attempts counting for the lockout of the account or the IP $1,
+// the password reset requests only counting for the IPs (not to lock the users out when they lost their password)
+func scopeCondition(scope string) string {
+	if scope == LockoutAccount {
+		return `email = $1 AND action <> '` + AttemptForgotPassword + `'`
+	}
+	return `ip = $1`
+}
+
+// Insert records a failed attempt, setting its ID and its creation date
+func (m LoginAttemptModel) Insert(attempt *LoginAttempt) error {
+
+	// generating the query
+	query := `
+		INSERT INTO login_attempts (action, email, ip)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	return m.db.QueryRowContext(ctx, query, attempt.Action, attempt.Email, attempt.IP).Scan(&attempt.ID, &attempt.CreatedAt)
+}
+
+// Failures returns the number of failed attempts of the account or the IP value since since, not cleared yet,
+// and the time of the last one
+func (m LoginAttemptModel) Failures(scope, value string, since time.Time) (int, time.Time, error) {
+
+	// generating the query
+	query := `
+		SELECT count(*), max(created_at)
+		FROM login_attempts
+		WHERE ` + scopeCondition(scope) + ` AND NOT cleared AND created_at > $2;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	var count int
+	var last sql.NullTime
+	err := m.db.QueryRowContext(ctx, query, value, since).Scan(&count, &last)
+
+	return count, last.Time, err
+}
+
+// Clear clears the failed attempts of the account or the IP value, once the account signed in or was unlocked
+func (m LoginAttemptModel) Clear(scope, value string) error {
+
+	// generating the query
+	query := `UPDATE login_attempts SET cleared = true WHERE ` + scopeCondition(scope) + ` AND NOT cleared;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	_, err := m.db.ExecContext(ctx, query, value)
+	return err
+}
+
+// GetRecent returns the last limit attempts since since, the latest first
+func (m LoginAttemptModel) GetRecent(since time.Time, limit int) ([]*LoginAttempt, error) {
+
+	// generating the query
+	query := `
+		SELECT id, created_at, action, email, ip
+		FROM login_attempts
+		WHERE created_at > $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the attempts
+	var attempts []*LoginAttempt
+	for rows.Next() {
+		var attempt LoginAttempt
+		err = rows.Scan(&attempt.ID, &attempt.CreatedAt, &attempt.Action, &attempt.Email, &attempt.IP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		attempts = append(attempts, &attempt)
+	}
+
+	return attempts, rows.Err()
+}
+
+// Lock records a new lockout, setting its ID and its creation date
+func (m LoginAttemptModel) Lock(lockout *Lockout) error {
+
+	// generating the query
+	query := `
+		INSERT INTO lockouts (scope, value, locked_until, attempts)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	args := []any{lockout.Scope, lockout.Value, lockout.LockedUntil, lockout.Attempts}
+	return m.db.QueryRowContext(ctx, query, args...).Scan(&lockout.ID, &lockout.CreatedAt)
+}
+
+// lockoutColumns are the columns scanned by queryLockouts
+const lockoutColumns = `id, created_at, scope, value, locked_until, attempts`
+
+// queryLockouts returns the lockouts selected with lockoutColumns by query
+func (m LoginAttemptModel) queryLockouts(query string, args ...any) ([]*Lockout, error) {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the lockouts
+	var lockouts []*Lockout
+	for rows.Next() {
+		var lockout Lockout
+		err = rows.Scan(&lockout.ID, &lockout.CreatedAt, &lockout.Scope, &lockout.Value, &lockout.LockedUntil, &lockout.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		lockouts = append(lockouts, &lockout)
+	}
+
+	return lockouts, rows.Err()
+}
+
+// GetLockout returns the current lockout of the account or the IP value, ErrRecordNotFound being returned if there is none
+func (m LoginAttemptModel) GetLockout(scope, value string) (*Lockout, error) {
+
+	lockouts, err := m.queryLockouts(`
+		SELECT `+lockoutColumns+`
+		FROM lockouts
+		WHERE scope = $1 AND value = $2 AND locked_until > NOW()
+		ORDER BY locked_until DESC
+		LIMIT 1;`, scope, value)
+	if err != nil {
+		return nil, err
+	}
+	if len(lockouts) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return lockouts[0], nil
+}
+
+// GetLockouts returns the current lockouts, the first to end first
+func (m LoginAttemptModel) GetLockouts() ([]*Lockout, error) {
+	return m.queryLockouts(`SELECT ` + lockoutColumns + ` FROM lockouts WHERE locked_until > NOW() ORDER BY locked_until;`)
+}
+
+// Unlock ends the current lockout id now and clears the failed attempts of its account or IP,
+// ErrRecordNotFound being returned if it already ended
+func (m LoginAttemptModel) Unlock(id int) (*Lockout, error) {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// executing the queries
+	lockout := Lockout{ID: id}
+	err = tx.QueryRowContext(ctx, `
+		UPDATE lockouts
+		SET locked_until = date_trunc('second', NOW())
+		WHERE id = $1 AND locked_until > NOW()
+		RETURNING created_at, scope, value, locked_until, attempts;`, id).Scan(
+		&lockout.CreatedAt, &lockout.Scope, &lockout.Value, &lockout.LockedUntil, &lockout.Attempts)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE login_attempts SET cleared = true WHERE `+scopeCondition(lockout.Scope)+` AND NOT cleared;`, lockout.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &lockout, nil
+}
+
+// DeleteExpired removes the attempts and the lockouts older than retention
+func (m LoginAttemptModel) DeleteExpired(retention time.Duration) error {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the queries
+	before := time.Now().Add(-retention)
+	_, err := m.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE created_at < $1;`, before)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired login attempts: %w", err)
+	}
+	_, err = m.db.ExecContext(ctx, `DELETE FROM lockouts WHERE locked_until < $1;`, before)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired lockouts: %w", err)
+	}
+
+	return nil
+}
@@ -8,9 +8,14 @@ import (
 const (
 	UserToActivate = "to-activate"
 	UserActivated  = "activated"
+	UserInvited    = "invited"
+	UserDisabled   = "disabled"
 
-	TokenActivation = "activation"
-	TokenReset      = "reset"
+	TokenActivation    = "activation"
+	TokenReset         = "reset"
+	TokenInvitation    = "invitation"
+	TokenTestimonial   = "testimonial"
+	TokenBookingCancel = "booking-cancellation"
 )
 
 var (
@@ -19,17 +24,33 @@ var (
 )
 
 type Models struct {
-	TokenModel  *TokenModel
-	UserModel   *UserModel
-	PostModel   *PostModel
-	AuthorModel *AuthorModel
+	TokenModel        *TokenModel
+	UserModel         *UserModel
+	PostModel         *PostModel
+	AuthorModel       *AuthorModel
+	UploadModel       *UploadModel
+	ProjectModel      *ProjectModel
+	TestimonialModel  *TestimonialModel
+	BookingModel      *BookingModel
+	RecoveryCodeModel *RecoveryCodeModel
+	PasskeyModel      *PasskeyModel
+	LoginAttemptModel *LoginAttemptModel
+	SessionModel      *SessionModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		TokenModel:  &TokenModel{db},
-		UserModel:   &UserModel{db},
-		PostModel:   &PostModel{db},
-		AuthorModel: &AuthorModel{db},
+		TokenModel:        &TokenModel{db},
+		UserModel:         &UserModel{db},
+		PostModel:         &PostModel{db},
+		AuthorModel:       &AuthorModel{db},
+		UploadModel:       &UploadModel{db},
+		ProjectModel:      &ProjectModel{db},
+		TestimonialModel:  &TestimonialModel{db},
+		BookingModel:      &BookingModel{db},
+		RecoveryCodeModel: &RecoveryCodeModel{db},
+		PasskeyModel:      &PasskeyModel{db},
+		LoginAttemptModel: &LoginAttemptModel{db},
+		SessionModel:      &SessionModel{db},
 	}
 }
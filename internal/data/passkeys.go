@@ -0,0 +1,169 @@
+package data
+
+import (
+	"Portfolio/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrDuplicatePasskey = errors.New("duplicate passkey")
+)
+
+// Passkey is a WebAuthn credential the user signs in with, PublicKey being its COSE_Key
+type Passkey struct {
+	ID           int
+	UserID       int
+	CredentialID []byte
+	PublicKey    []byte
+	SignCount    uint32
+	Name         string
+	CreatedAt    time.Time
+	LastUsedAt   *time.Time
+}
+
+func (passkey *Passkey) Validate(v *validator.Validator) {
+	v.StringCheck(passkey.Name, 1, 60, true, "name")
+}
+
+type PasskeyModel struct {
+	db *sql.DB
+}
+
+// passkeyColumns are the columns scanned by queryPasskeys
+const passkeyColumns = `id, user_id, credential_id, public_key, sign_count, name, created_at, last_used_at`
+
+// queryPasskeys returns the passkeys selected with passkeyColumns by query
+func (m PasskeyModel) queryPasskeys(query string, args ...any) ([]*Passkey, error) {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the passkeys
+	var passkeys []*Passkey
+	for rows.Next() {
+		var passkey Passkey
+		err = rows.Scan(&passkey.ID, &passkey.UserID, &passkey.CredentialID, &passkey.PublicKey, &passkey.SignCount,
+			&passkey.Name, &passkey.CreatedAt, &passkey.LastUsedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		passkeys = append(passkeys, &passkey)
+	}
+
+	return passkeys, rows.Err()
+}
+
+// GetForUser returns the passkeys of the user userID, the last registered first
+func (m PasskeyModel) GetForUser(userID int) ([]*Passkey, error) {
+	return m.queryPasskeys(`SELECT `+passkeyColumns+` FROM passkeys WHERE user_id = $1 ORDER BY created_at DESC, id DESC;`, userID)
+}
+
+// GetByCredentialID returns the passkey of the WebAuthn credential id
+func (m PasskeyModel) GetByCredentialID(credentialID []byte) (*Passkey, error) {
+
+	passkeys, err := m.queryPasskeys(`SELECT `+passkeyColumns+` FROM passkeys WHERE credential_id = $1;`, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if len(passkeys) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return passkeys[0], nil
+}
+
+// Insert records a new passkey, setting its ID and its creation date
+func (m PasskeyModel) Insert(passkey *Passkey) error {
+
+	// generating the query
+	query := `
+		INSERT INTO passkeys (user_id, credential_id, public_key, sign_count, name)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	args := []any{passkey.UserID, passkey.CredentialID, passkey.PublicKey, passkey.SignCount, passkey.Name}
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(&passkey.ID, &passkey.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "passkeys_credential_id_key"`:
+			return ErrDuplicatePasskey
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Use records that the passkey was just used to sign in, with its new signature counter
+func (m PasskeyModel) Use(passkey *Passkey) error {
+
+	// generating the query
+	query := `
+		UPDATE passkeys
+		SET sign_count = $1, last_used_at = NOW()
+		WHERE id = $2
+		RETURNING last_used_at;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	err := m.db.QueryRowContext(ctx, query, passkey.SignCount, passkey.ID).Scan(&passkey.LastUsedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the passkey id of the user userID, ErrRecordNotFound being returned if it doesn't exist
+func (m PasskeyModel) Delete(id, userID int) error {
+
+	// generating the query
+	query := `DELETE FROM passkeys WHERE id = $1 AND user_id = $2;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	// checking that the passkey existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
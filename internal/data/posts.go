@@ -7,22 +7,32 @@ import (
 	"errors"
 	"fmt"
 	"github.com/lib/pq"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
 	"time"
 )
 
 var (
 	ErrDuplicatePostTitle = errors.New("duplicate post title")
+
+	// uploadLinkRX matches the links to the uploads in the markdown and HTML of the posts
+	uploadLinkRX = regexp.MustCompile(`(?:^|[\s("'=])(/uploads/[^\s)"'<>?#]+)`)
 )
 
 type Post struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	Images    []string  `json:"images"`
-	Content   []byte    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Views     int       `json:"views,omitempty"`
-	Version   int       `json:"version,omitempty"`
+	ID         int       `json:"id"`
+	AuthorID   int       `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	AuthorSlug string    `json:"author_slug"`
+	Title      string    `json:"title"`
+	Images     []string  `json:"images"`
+	Content    []byte    `json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Views      int       `json:"views,omitempty"`
+	Version    int       `json:"version,omitempty"`
 }
 
 func (post *Post) Validate(v *validator.Validator) {
@@ -32,6 +42,53 @@ func (post *Post) Validate(v *validator.Validator) {
 	v.Check(len(post.Images) > 1, "images", "must contain at least 1 image")
 }
 
+// AuthorURL returns the path of the public page of the author of the post
+func (post *Post) AuthorURL() string {
+	return "/author/" + post.AuthorSlug
+}
+
+// UploadPaths returns the uploads used by the post, as images or as links of its content
+func (post *Post) UploadPaths() []string {
+	return uploadPaths(append(slices.Clone(post.Images), string(post.Content))...)
+}
+
+// uploadPaths returns the uploads linked in the texts, once each
+func uploadPaths(texts ...string) []string {
+
+	var paths []string
+
+	for _, text := range texts {
+		for _, match := range uploadLinkRX.FindAllStringSubmatch(text, -1) {
+			link, err := url.PathUnescape(match[1])
+			if err != nil {
+				link = match[1]
+			}
+			link = path.Clean(link)[1:]
+			if !slices.Contains(paths, link) {
+				paths = append(paths, link)
+			}
+		}
+	}
+
+	return paths
+}
+
+// setUploadReferences replaces the uploads used by the post id within the transaction tx
+func setUploadReferences(ctx context.Context, tx *sql.Tx, id int, paths []string) error {
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM upload_references WHERE post_id = $1;`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO upload_references (path, post_id)
+		SELECT unnest($2::text[]), $1
+		ON CONFLICT DO NOTHING;`, id, pq.Array(paths))
+
+	return err
+}
+
 type PostFeed struct {
 	Last    *Post
 	Popular []*Post
@@ -52,26 +109,26 @@ func (m PostModel) Insert(post *Post) error {
 
 	// generating the query
 	query := `
-		INSERT INTO posts (title, images, content)
-		VALUES ($1, $2, $3)
+		INSERT INTO posts (title, images, content, author_id)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at, version;`
 
 	// setting the arguments
-	args := []any{post.Content, pq.Array(post.Images), post.Content}
+	args := []any{post.Title, pq.Array(post.Images), post.Content, post.AuthorID}
 
 	// setting the timeout context for the query execution
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	// preparing the query
-	stmt, err := m.db.PrepareContext(ctx, query)
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to prepare query: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
 	// executing the query
-	err = stmt.QueryRowContext(ctx, args...).Scan(&post.ID, &post.CreatedAt, &post.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&post.ID, &post.CreatedAt, &post.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "posts_title_key"`:
@@ -81,19 +138,106 @@ func (m PostModel) Insert(post *Post) error {
 		}
 	}
 
+	// recording the uploads used by the post
+	err = setUploadReferences(ctx, tx, post.ID, post.UploadPaths())
+	if err != nil {
+		return err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
+func (m PostModel) Import(post *Post) error {
+
+	// generating the query (keeping the original publication dates)
+	query := `
+		INSERT INTO posts (title, images, content, created_at, updated_at, author_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, version;`
+
+	// setting the arguments
+	args := []any{post.Title, pq.Array(post.Images), post.Content, post.CreatedAt, post.UpdatedAt, post.AuthorID}
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// executing the query
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&post.ID, &post.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "posts_title_key"`:
+			return ErrDuplicatePostTitle
+		default:
+			return err
+		}
+	}
+
+	// recording the uploads used by the post
+	err = setUploadReferences(ctx, tx, post.ID, post.UploadPaths())
+	if err != nil {
+		return err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (m PostModel) TitleExists(title string) (bool, error) {
+
+	// generating the query
+	query := `
+		SELECT EXISTS (
+		SELECT 1 FROM posts WHERE lower(title) = lower($1));`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// preparing the query
+	stmt, err := m.db.PrepareContext(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer stmt.Close()
+
+	// executing the query
+	var exists bool
+	err = stmt.QueryRowContext(ctx, title).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
 func (m PostModel) Get(search string, filters *Filters) ([]*Post, Metadata, error) {
 
 	// generating the query
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, created_at, updated_at, title, images, content, views, version
-		FROM posts
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		OR (to_tsvector('simple', content) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		OR (images @> $2 OR $2 = '{}')
-		ORDER BY %s %s, id ASC
+		SELECT count(*) OVER(), p.id, p.created_at, p.updated_at, p.title, p.images, p.content, p.views, p.version, p.author_id, a.name, a.slug
+		FROM posts p
+		INNER JOIN author a ON a.id = p.author_id
+		WHERE (to_tsvector('simple', p.title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		OR (to_tsvector('simple', p.content) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		OR (p.images @> $2 OR $2 = '{}')
+		ORDER BY p.%s %s, p.id ASC
 		LIMIT $3 OFFSET $4;`, filters.sortColumn(), filters.sortDirection())
 
 	// setting the arguments
@@ -135,6 +279,9 @@ func (m PostModel) Get(search string, filters *Filters) ([]*Post, Metadata, erro
 			&post.Content,
 			&post.Views,
 			&post.Version,
+			&post.AuthorID,
+			&post.AuthorName,
+			&post.AuthorSlug,
 		)
 
 		if err != nil {
@@ -154,13 +301,51 @@ func (m PostModel) Get(search string, filters *Filters) ([]*Post, Metadata, erro
 	return posts, metadata, nil
 }
 
-func (m PostModel) GetFeed() (*PostFeed, error) {
+// GetTitles returns the ID and the title of every post of the author authorID, the most recent first
+func (m PostModel) GetTitles(authorID int) ([]*Post, error) {
 
-	// generating the first query (popular posts)
+	// generating the query
 	query := `
-		SELECT id, created_at, updated_at, title, images, content, views, version
+		SELECT id, title
 		FROM posts
-		ORDER BY views DESC
+		WHERE author_id = $1
+		ORDER BY created_at DESC, id DESC;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, authorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the posts
+	var posts []*Post
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(&post.ID, &post.Title)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		posts = append(posts, &post)
+	}
+
+	return posts, rows.Err()
+}
+
+// GetFeed returns the most popular posts and the last one of the author authorID (of all the authors if 0)
+func (m PostModel) GetFeed(authorID int) (*PostFeed, error) {
+
+	// generating the first query (popular posts)
+	query := `
+		SELECT p.id, p.created_at, p.updated_at, p.title, p.images, p.content, p.views, p.version, p.author_id, a.name, a.slug
+		FROM posts p
+		INNER JOIN author a ON a.id = p.author_id
+		WHERE p.author_id = $1 OR $1 = 0
+		ORDER BY p.views DESC
 		LIMIT 5;`
 
 	// setting the timeout context for the query execution
@@ -182,7 +367,7 @@ func (m PostModel) GetFeed() (*PostFeed, error) {
 	defer stmt.Close()
 
 	// executing the query
-	rows, err := stmt.QueryContext(ctx)
+	rows, err := stmt.QueryContext(ctx, authorID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query rows: %w", err)
 	}
@@ -194,7 +379,7 @@ func (m PostModel) GetFeed() (*PostFeed, error) {
 
 		// getting each popular post one at a time
 		var post Post
-		err := rows.Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt, &post.Title, pq.Array(&post.Images), &post.Content, &post.Views, &post.Version)
+		err := rows.Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt, &post.Title, pq.Array(&post.Images), &post.Content, &post.Views, &post.Version, &post.AuthorID, &post.AuthorName, &post.AuthorSlug)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
@@ -205,9 +390,11 @@ func (m PostModel) GetFeed() (*PostFeed, error) {
 
 	// generating the second query (last post)
 	query = `
-		SELECT id, created_at, updated_at, title, images, content, views, version
-		FROM posts
-		ORDER BY created_at DESC
+		SELECT p.id, p.created_at, p.updated_at, p.title, p.images, p.content, p.views, p.version, p.author_id, a.name, a.slug
+		FROM posts p
+		INNER JOIN author a ON a.id = p.author_id
+		WHERE p.author_id = $1 OR $1 = 0
+		ORDER BY p.created_at DESC
 		LIMIT 1;`
 
 	// preparing the second query
@@ -217,10 +404,16 @@ func (m PostModel) GetFeed() (*PostFeed, error) {
 	}
 	defer stmt.Close()
 
-	// executing the query
-	err = stmt.QueryRowContext(ctx).Scan(&postFeed.Last.ID, &postFeed.Last.CreatedAt, &postFeed.Last.UpdatedAt, &postFeed.Last.Title, pq.Array(&postFeed.Last.Images), &postFeed.Last.Content, &postFeed.Last.Views, &postFeed.Last.Version)
+	// executing the query (an author without posts having no last post)
+	last := postFeed.Last
+	err = stmt.QueryRowContext(ctx, authorID).Scan(&last.ID, &last.CreatedAt, &last.UpdatedAt, &last.Title, pq.Array(&last.Images), &last.Content, &last.Views, &last.Version, &last.AuthorID, &last.AuthorName, &last.AuthorSlug)
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan row: %w", err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			postFeed.Last = nil
+		default:
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
 	}
 
 	// executing the transaction
@@ -235,9 +428,10 @@ func (m PostModel) GetByID(id int) (*Post, error) {
 
 	// generating the query
 	query := `
-		SELECT id, created_at, updated_at, title, images, content, views, version
-		FROM posts
-		WHERE id = $1;`
+		SELECT p.id, p.created_at, p.updated_at, p.title, p.images, p.content, p.views, p.version, p.author_id, a.name, a.slug
+		FROM posts p
+		INNER JOIN author a ON a.id = p.author_id
+		WHERE p.id = $1;`
 
 	// setting the timeout context for the query execution
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -263,6 +457,9 @@ func (m PostModel) GetByID(id int) (*Post, error) {
 		&post.Content,
 		&post.Views,
 		&post.Version,
+		&post.AuthorID,
+		&post.AuthorName,
+		&post.AuthorSlug,
 	)
 
 	// looking for errors
@@ -300,15 +497,15 @@ func (m PostModel) Update(post Post) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	// preparing the query
-	stmt, err := m.db.PrepareContext(ctx, query)
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to prepare query: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
 	// executing the query
-	err = stmt.QueryRowContext(ctx, args...).Scan(&post.UpdatedAt, &post.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&post.UpdatedAt, &post.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -318,6 +515,17 @@ func (m PostModel) Update(post Post) error {
 		}
 	}
 
+	// recording the uploads used by the post
+	err = setUploadReferences(ctx, tx, post.ID, post.UploadPaths())
+	if err != nil {
+		return err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
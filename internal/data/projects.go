@@ -0,0 +1,379 @@
+package data
+
+import (
+	"Portfolio/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ProjectPlanned    = "planned"
+	ProjectInProgress = "in-progress"
+	ProjectCompleted  = "completed"
+	ProjectArchived   = "archived"
+)
+
+var (
+	ErrDuplicateProjectTitle = errors.New("duplicate project title")
+
+	// ProjectStatuses contains the statuses of the projects, in the order of the forms
+	ProjectStatuses = []string{ProjectPlanned, ProjectInProgress, ProjectCompleted, ProjectArchived}
+)
+
+// Project is a work of the author presented in the showcase, Description being markdown
+type Project struct {
+	ID            int        `json:"id"`
+	Title         string     `json:"title"`
+	Summary       string     `json:"summary"`
+	Description   []byte     `json:"description"`
+	Role          string     `json:"role"`
+	TechStack     []string   `json:"tech_stack"`
+	RepositoryURL string     `json:"repository_url"`
+	DemoURL       string     `json:"demo_url"`
+	Screenshots   []string   `json:"screenshots"`
+	Status        string     `json:"status"`
+	StartedOn     *time.Time `json:"started_on"`
+	EndedOn       *time.Time `json:"ended_on"`
+	Featured      bool       `json:"featured"`
+	Position      int        `json:"position"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Version       int        `json:"version,omitempty"`
+}
+
+func (project *Project) Validate(v *validator.Validator) {
+	v.StringCheck(project.Title, 2, 120, true, "title")
+	v.StringCheck(project.Summary, 0, 300, false, "summary")
+	v.Check(len(project.Description) <= 20_000, "description", "must not be more than 20000 bytes long")
+	v.StringCheck(project.Role, 0, 120, false, "role")
+	v.Check(len(project.TechStack) <= 20, "tech_stack", "must not contain more than 20 technologies")
+	for _, tech := range project.TechStack {
+		v.Check(len(tech) <= 40, "tech_stack", "must not contain technologies of more than 40 bytes")
+	}
+	v.CheckURL(project.RepositoryURL, "repository_url")
+	v.CheckURL(project.DemoURL, "demo_url")
+	v.Check(len(project.Screenshots) <= 12, "screenshots", "limit: 12 screenshots max")
+	for _, screenshot := range project.Screenshots {
+		v.Check(strings.HasPrefix(screenshot, "/uploads/"), "screenshots", "must be uploaded files")
+	}
+	v.Check(validator.PermittedValue(project.Status, ProjectStatuses...), "status", "invalid status")
+	v.Check(project.StartedOn == nil || project.EndedOn == nil || !project.EndedOn.Before(*project.StartedOn), "ended_on", "must not be before the start date")
+}
+
+// Period returns the month range of the project ("Sep 2020 – Jun 2023", "Since Sep 2020"...)
+func (project *Project) Period() string {
+	return monthRange(project.StartedOn, project.EndedOn)
+}
+
+// UploadPaths returns the uploads used by the project, as screenshots or as links of its description
+func (project *Project) UploadPaths() []string {
+	return uploadPaths(append(slices.Clone(project.Screenshots), string(project.Description))...)
+}
+
+// setProjectUploadReferences replaces the uploads used by the project id within the transaction tx
+func setProjectUploadReferences(ctx context.Context, tx *sql.Tx, id int, paths []string) error {
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM project_upload_references WHERE project_id = $1;`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO project_upload_references (path, project_id)
+		SELECT unnest($2::text[]), $1
+		ON CONFLICT DO NOTHING;`, id, pq.Array(paths))
+
+	return err
+}
+
+type ProjectModel struct {
+	db *sql.DB
+}
+
+// projectColumns are the columns scanned by scanProject
+const projectColumns = `
+	id, created_at, updated_at, title, summary, description, role, tech_stack, repository_url, demo_url,
+	screenshots, status, started_on, ended_on, featured, position, version`
+
+// scanProject reads a row selected with projectColumns
+func scanProject(row interface{ Scan(...any) error }) (*Project, error) {
+
+	var project Project
+	err := row.Scan(
+		&project.ID,
+		&project.CreatedAt,
+		&project.UpdatedAt,
+		&project.Title,
+		&project.Summary,
+		&project.Description,
+		&project.Role,
+		pq.Array(&project.TechStack),
+		&project.RepositoryURL,
+		&project.DemoURL,
+		pq.Array(&project.Screenshots),
+		&project.Status,
+		&project.StartedOn,
+		&project.EndedOn,
+		&project.Featured,
+		&project.Position,
+		&project.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &project, nil
+}
+
+// saveProject runs query (returning the ID, the dates and the version of the project) and records the uploads the project uses
+func (m ProjectModel) saveProject(query string, args []any, project *Project) error {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// executing the query
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&project.ID, &project.CreatedAt, &project.UpdatedAt, &project.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "projects_title_key"`:
+			return ErrDuplicateProjectTitle
+		default:
+			return err
+		}
+	}
+
+	// recording the uploads used by the project
+	err = setProjectUploadReferences(ctx, tx, project.ID, project.UploadPaths())
+	if err != nil {
+		return err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (m ProjectModel) Insert(project *Project) error {
+
+	// generating the query
+	query := `
+		INSERT INTO projects (title, summary, description, role, tech_stack, repository_url, demo_url, screenshots, status, started_on, ended_on, featured, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at, version;`
+
+	// setting the arguments
+	args := []any{
+		project.Title,
+		project.Summary,
+		project.Description,
+		project.Role,
+		pq.Array(project.TechStack),
+		project.RepositoryURL,
+		project.DemoURL,
+		pq.Array(project.Screenshots),
+		project.Status,
+		project.StartedOn,
+		project.EndedOn,
+		project.Featured,
+		project.Position,
+	}
+
+	return m.saveProject(query, args, project)
+}
+
+func (m ProjectModel) Update(project *Project) error {
+
+	// generating the query
+	query := `
+		UPDATE projects
+		SET updated_at = NOW(), title = $1, summary = $2, description = $3, role = $4, tech_stack = $5, repository_url = $6, demo_url = $7,
+		    screenshots = $8, status = $9, started_on = $10, ended_on = $11, featured = $12, position = $13, version = version + 1
+		WHERE id = $14 AND version = $15
+		RETURNING id, created_at, updated_at, version;`
+
+	// setting the arguments
+	args := []any{
+		project.Title,
+		project.Summary,
+		project.Description,
+		project.Role,
+		pq.Array(project.TechStack),
+		project.RepositoryURL,
+		project.DemoURL,
+		pq.Array(project.Screenshots),
+		project.Status,
+		project.StartedOn,
+		project.EndedOn,
+		project.Featured,
+		project.Position,
+		project.ID,
+		project.Version,
+	}
+
+	return m.saveProject(query, args, project)
+}
+
+// Get returns the projects using the technology tech (all of them if empty), the featured ones first
+func (m ProjectModel) Get(tech string) ([]*Project, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + projectColumns + `
+		FROM projects
+		WHERE (tech_stack @> ARRAY[$1] OR $1 = '')
+		ORDER BY featured DESC, position, started_on DESC NULLS LAST, id DESC;`
+
+	return m.query(query, tech)
+}
+
+// GetFeatured returns the featured projects, in their order, limit at most
+func (m ProjectModel) GetFeatured(limit int) ([]*Project, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + projectColumns + `
+		FROM projects
+		WHERE featured
+		ORDER BY position, started_on DESC NULLS LAST, id DESC
+		LIMIT $1;`
+
+	return m.query(query, limit)
+}
+
+// query returns the projects selected with projectColumns by query
+func (m ProjectModel) query(query string, args ...any) ([]*Project, error) {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the projects
+	var projects []*Project
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		projects = append(projects, project)
+	}
+
+	return projects, rows.Err()
+}
+
+// GetTechs returns the technologies used by the projects, the most used first
+func (m ProjectModel) GetTechs() ([]string, error) {
+
+	// generating the query
+	query := `
+		SELECT tech
+		FROM projects, unnest(tech_stack) AS tech
+		GROUP BY tech
+		ORDER BY count(*) DESC, lower(tech);`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the technologies
+	var techs []string
+	for rows.Next() {
+		var tech string
+		err := rows.Scan(&tech)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		techs = append(techs, tech)
+	}
+
+	return techs, rows.Err()
+}
+
+func (m ProjectModel) GetByID(id int) (*Project, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + projectColumns + `
+		FROM projects
+		WHERE id = $1;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	project, err := scanProject(m.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return project, nil
+}
+
+func (m ProjectModel) Delete(id int) error {
+
+	// generating the query
+	query := `
+		DELETE FROM projects
+		WHERE id = $1;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	// checking for result
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// if nothing found
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
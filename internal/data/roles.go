@@ -0,0 +1,54 @@
+package data
+
+import "slices"
+
+const (
+	RoleAdmin       = "admin"
+	RoleEditor      = "editor"
+	RoleContributor = "contributor"
+
+	// PermissionEditPosts allows editing the posts of the other authors
+	PermissionEditPosts = "posts:edit"
+
+	// PermissionProjects allows creating, editing and deleting the projects of the showcase
+	PermissionProjects = "projects:manage"
+
+	// PermissionFiles allows organizing, deleting and sharing the uploaded files
+	PermissionFiles = "files:manage"
+
+	// PermissionUsers allows inviting the users, changing their roles, disabling and deleting them,
+	// and unlocking the accounts and the client IPs locked out
+	PermissionUsers = "users:manage"
+)
+
+var (
+	// Roles contains the roles of the users, from the most to the least privileged
+	Roles = []string{RoleAdmin, RoleEditor, RoleContributor}
+
+	roleLabels = map[string]string{
+		RoleAdmin:       "Administrator",
+		RoleEditor:      "Editor",
+		RoleContributor: "Contributor",
+	}
+
+	// rolePermissions contains the permissions of each role on top of those every user has
+	// (their profile, their posts, their testimonials and meetings, uploading files)
+	rolePermissions = map[string][]string{
+		RoleAdmin:       {PermissionEditPosts, PermissionProjects, PermissionFiles, PermissionUsers},
+		RoleEditor:      {PermissionEditPosts, PermissionProjects, PermissionFiles},
+		RoleContributor: {},
+	}
+)
+
+// RoleLabel returns the name of role as displayed
+func RoleLabel(role string) string {
+	if label, ok := roleLabels[role]; ok {
+		return label
+	}
+	return role
+}
+
+// Can reports whether the users of role have permission
+func Can(role, permission string) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
@@ -0,0 +1,189 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Session is an authenticated session of a user, Token being the one of their session cookie
+// (never shown) and Current reporting whether it is the session of the request listing them
+type Session struct {
+	ID         int
+	UserID     int
+	Token      string
+	Device     string
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	Current    bool
+}
+
+type SessionModel struct {
+	db *sql.DB
+}
+
+// Save records the session once the user signed in, or else records that it was just seen from its IP,
+// setting its ID and its dates
+func (m SessionModel) Save(session *Session) error {
+
+	// generating the query
+	query := `
+		INSERT INTO user_sessions (user_id, token, device, ip, user_agent)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (token) DO UPDATE
+		SET ip = EXCLUDED.ip, last_seen_at = NOW()
+		RETURNING id, created_at, last_seen_at;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	args := []any{session.UserID, session.Token, session.Device, session.IP, session.UserAgent}
+	return m.db.QueryRowContext(ctx, query, args...).Scan(&session.ID, &session.CreatedAt, &session.LastSeenAt)
+}
+
+// GetForUser returns the sessions of the user userID not ended yet, the session currentToken first
+// and then the last seen first
+func (m SessionModel) GetForUser(userID int, currentToken string) ([]*Session, error) {
+
+	// generating the query
+	query := `
+		SELECT us.id, us.user_id, us.device, us.ip, us.user_agent, us.created_at, us.last_seen_at, s.expiry, us.token = $2
+		FROM user_sessions us
+		INNER JOIN sessions s
+		ON s.token = us.token
+		WHERE us.user_id = $1 AND s.expiry > NOW()
+		ORDER BY us.token = $2 DESC, us.last_seen_at DESC, us.id DESC;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, userID, currentToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the sessions
+	var sessions []*Session
+	for rows.Next() {
+		var session Session
+		err = rows.Scan(&session.ID, &session.UserID, &session.Device, &session.IP, &session.UserAgent,
+			&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.Current)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Revoke ends the session id of the user userID, the session currentToken being kept (logging out ends it),
+// ErrRecordNotFound being returned if it already ended
+func (m SessionModel) Revoke(id, userID int, currentToken string) error {
+
+	// generating the query
+	query := `
+		WITH revoked AS (
+			DELETE FROM user_sessions
+			WHERE id = $1 AND user_id = $2 AND token <> $3
+			RETURNING token
+		)
+		DELETE FROM sessions
+		WHERE token IN (SELECT token FROM revoked);`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, id, userID, currentToken)
+	if err != nil {
+		return err
+	}
+
+	// checking that the session was still going on
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// RevokeAll ends the sessions of the user userID but the session exceptToken (all of them if it is empty),
+// returning how many were going on
+func (m SessionModel) RevokeAll(userID int, exceptToken string) (int, error) {
+
+	// generating the query
+	query := `
+		WITH revoked AS (
+			DELETE FROM user_sessions
+			WHERE user_id = $1 AND token <> $2
+			RETURNING token
+		)
+		DELETE FROM sessions
+		WHERE token IN (SELECT token FROM revoked);`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, userID, exceptToken)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// Delete forgets the session token once the user logged out
+func (m SessionModel) Delete(token string) error {
+
+	// generating the query
+	query := `DELETE FROM user_sessions WHERE token = $1;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	_, err := m.db.ExecContext(ctx, query, token)
+	return err
+}
+
+// DeleteExpired forgets the sessions which ended, those just recorded being kept until their session is saved
+// at the end of the request
+func (m SessionModel) DeleteExpired() error {
+
+	// generating the query
+	query := `
+		DELETE FROM user_sessions us
+		WHERE us.created_at < NOW() - INTERVAL '1 minute'
+		AND NOT EXISTS (SELECT 1 FROM sessions s WHERE s.token = us.token AND s.expiry > NOW());`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	_, err := m.db.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	return nil
+}
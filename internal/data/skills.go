@@ -0,0 +1,352 @@
+package data
+
+import (
+	"Portfolio/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	SkillLanguage  = "languages"
+	SkillFramework = "frameworks"
+	SkillTool      = "tools"
+	SkillOther     = "others"
+
+	MinSkillLevel = 1
+	MaxSkillLevel = 5
+)
+
+var (
+	ErrDuplicateSkillName = errors.New("duplicate skill name")
+
+	// SkillCategories contains the categories of the skills, in the order of the skills section
+	SkillCategories = []string{SkillLanguage, SkillFramework, SkillTool, SkillOther}
+
+	skillCategoryLabels = map[string]string{
+		SkillLanguage:  "Languages",
+		SkillFramework: "Frameworks",
+		SkillTool:      "Tools",
+		SkillOther:     "Others",
+	}
+
+	skillLevelNames = [MaxSkillLevel + 1]string{"", "Beginner", "Elementary", "Intermediate", "Advanced", "Expert"}
+)
+
+// Skill is a skill of the author, with the projects and the posts showing it
+type Skill struct {
+	ID         int         `json:"id"`
+	AuthorID   int         `json:"-"`
+	Name       string      `json:"name"`
+	Category   string      `json:"category"`
+	Level      int         `json:"level"`
+	Years      int         `json:"years"`
+	Position   int         `json:"position"`
+	ProjectIDs []int       `json:"project_ids"`
+	PostIDs    []int       `json:"post_ids"`
+	Links      []UsageLink `json:"links,omitempty"`
+}
+
+func (skill *Skill) Validate(v *validator.Validator) {
+	v.StringCheck(skill.Name, 1, 60, true, "name")
+	v.Check(validator.PermittedValue(skill.Category, SkillCategories...), "category", "invalid category")
+	v.Check(skill.Level >= MinSkillLevel && skill.Level <= MaxSkillLevel, "level", fmt.Sprintf("must be between %d and %d", MinSkillLevel, MaxSkillLevel))
+	v.Check(skill.Years >= 0 && skill.Years <= 60, "years", "must be between 0 and 60")
+	v.Check(len(skill.ProjectIDs)+len(skill.PostIDs) <= 10, "links", "must not be more than 10")
+}
+
+// SkillLevelName returns the name of the proficiency level ("Beginner" to "Expert")
+func SkillLevelName(level int) string {
+	if level < MinSkillLevel || level > MaxSkillLevel {
+		return ""
+	}
+	return skillLevelNames[level]
+}
+
+// SkillCategoryLabel returns the title of the skill category
+func SkillCategoryLabel(category string) string {
+	return skillCategoryLabels[category]
+}
+
+// LevelName returns the name of the proficiency level of the skill
+func (skill *Skill) LevelName() string {
+	return SkillLevelName(skill.Level)
+}
+
+// SkillGroup is the skills of a category
+type SkillGroup struct {
+	Category string
+	Label    string
+	Skills   []*Skill
+}
+
+// Names returns the names of the skills of the group
+func (group SkillGroup) Names() []string {
+	names := make([]string, len(group.Skills))
+	for i, skill := range group.Skills {
+		names[i] = skill.Name
+	}
+	return names
+}
+
+// SkillGroups returns the skills of the author by category, leaving out the empty ones
+func (author *Author) SkillGroups() []SkillGroup {
+
+	var groups []SkillGroup
+	for _, category := range SkillCategories {
+		group := SkillGroup{Category: category, Label: SkillCategoryLabel(category)}
+		for _, skill := range author.Skills {
+			if skill.Category == category {
+				group.Skills = append(group.Skills, skill)
+			}
+		}
+		if len(group.Skills) > 0 {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+// TopSkills returns the n skills of the author with the highest level, in their order otherwise
+func (author *Author) TopSkills(n int) []*Skill {
+	skills := slices.Clone(author.Skills)
+	sort.SliceStable(skills, func(i, j int) bool { return skills[i].Level > skills[j].Level })
+	return skills[:min(n, len(skills))]
+}
+
+// skillColumns are the columns scanned by scanSkill, s being the skills table
+const skillColumns = `
+	s.id, s.author_id, s.name, s.category, s.level, s.years, s.position,
+	ARRAY(SELECT l.project_id FROM skill_links l WHERE l.skill_id = s.id AND l.project_id IS NOT NULL ORDER BY l.project_id),
+	ARRAY(SELECT l.post_id FROM skill_links l WHERE l.skill_id = s.id AND l.post_id IS NOT NULL ORDER BY l.post_id),
+	ARRAY(SELECT link FROM ` + skillUsages + ` ORDER BY rank, id),
+	ARRAY(SELECT title FROM ` + skillUsages + ` ORDER BY rank, id)`
+
+// skillUsages lists the projects and the posts showing the skill s, with their page
+const skillUsages = `(
+		SELECT 0 AS rank, p.id, '/project/' || p.id AS link, p.title FROM skill_links l INNER JOIN projects p ON p.id = l.project_id WHERE l.skill_id = s.id
+		UNION ALL
+		SELECT 1, p.id, '/post/' || p.id, p.title FROM skill_links l INNER JOIN posts p ON p.id = l.post_id WHERE l.skill_id = s.id
+	) usages`
+
+// scanSkill reads a row selected with skillColumns
+func scanSkill(row interface{ Scan(...any) error }) (*Skill, error) {
+
+	var (
+		skill      Skill
+		projectIDs []int64
+		postIDs    []int64
+		links      []string
+		titles     []string
+	)
+
+	err := row.Scan(
+		&skill.ID,
+		&skill.AuthorID,
+		&skill.Name,
+		&skill.Category,
+		&skill.Level,
+		&skill.Years,
+		&skill.Position,
+		pq.Array(&projectIDs),
+		pq.Array(&postIDs),
+		pq.Array(&links),
+		pq.Array(&titles),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range projectIDs {
+		skill.ProjectIDs = append(skill.ProjectIDs, int(id))
+	}
+	for _, id := range postIDs {
+		skill.PostIDs = append(skill.PostIDs, int(id))
+	}
+	for i := range min(len(links), len(titles)) {
+		skill.Links = append(skill.Links, UsageLink{URL: links[i], Title: titles[i]})
+	}
+
+	return &skill, nil
+}
+
+// GetSkills returns the skills of the author authorID, in their order
+func (m AuthorModel) GetSkills(authorID int) ([]*Skill, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + skillColumns + `
+		FROM skills s
+		WHERE s.author_id = $1
+		ORDER BY s.position, s.level DESC, lower(s.name);`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, authorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the skills
+	var skills []*Skill
+	for rows.Next() {
+		skill, err := scanSkill(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		skills = append(skills, skill)
+	}
+
+	return skills, rows.Err()
+}
+
+// GetSkill returns the skill id
+func (m AuthorModel) GetSkill(id int) (*Skill, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + skillColumns + `
+		FROM skills s
+		WHERE s.id = $1;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	skill, err := scanSkill(m.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return skill, nil
+}
+
+// saveSkill runs query (returning the ID of the skill) and replaces the links of the skill
+func (m AuthorModel) saveSkill(query string, args []any, skill *Skill) error {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// executing the query
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&skill.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case err.Error() == `pq: duplicate key value violates unique constraint "skills_name_key"`:
+			return ErrDuplicateSkillName
+		default:
+			return err
+		}
+	}
+
+	// replacing the links to the projects and the posts (the missing ones being ignored)
+	_, err = tx.ExecContext(ctx, `DELETE FROM skill_links WHERE skill_id = $1;`, skill.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO skill_links (skill_id, project_id)
+		SELECT $1, p.id FROM projects p WHERE p.id = ANY($2::bigint[]);`, skill.ID, pq.Array(skill.ProjectIDs))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO skill_links (skill_id, post_id)
+		SELECT $1, p.id FROM posts p WHERE p.id = ANY($2::bigint[]);`, skill.ID, pq.Array(skill.PostIDs))
+	if err != nil {
+		return err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// InsertSkill records a new skill, setting its ID
+func (m AuthorModel) InsertSkill(skill *Skill) error {
+
+	// generating the query
+	query := `
+		INSERT INTO skills (author_id, name, category, level, years, position)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id;`
+
+	// setting the arguments
+	args := []any{skill.AuthorID, skill.Name, skill.Category, skill.Level, skill.Years, skill.Position}
+
+	return m.saveSkill(query, args, skill)
+}
+
+// UpdateSkill saves the changes of a skill
+func (m AuthorModel) UpdateSkill(skill *Skill) error {
+
+	// generating the query
+	query := `
+		UPDATE skills
+		SET name = $1, category = $2, level = $3, years = $4, position = $5
+		WHERE id = $6 AND author_id = $7
+		RETURNING id;`
+
+	// setting the arguments
+	args := []any{skill.Name, skill.Category, skill.Level, skill.Years, skill.Position, skill.ID, skill.AuthorID}
+
+	return m.saveSkill(query, args, skill)
+}
+
+// DeleteSkill removes the skill id from the skills of the author authorID
+func (m AuthorModel) DeleteSkill(id, authorID int) error {
+
+	// generating the query
+	query := `
+		DELETE FROM skills
+		WHERE id = $1 AND author_id = $2;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, id, authorID)
+	if err != nil {
+		return err
+	}
+
+	// checking that the skill existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,345 @@
+package data
+
+import (
+	"Portfolio/internal/validator"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	TestimonialUnverified = "unverified"
+	TestimonialPending    = "pending"
+	TestimonialApproved   = "approved"
+	TestimonialRejected   = "rejected"
+
+	RelationshipColleague = "colleague"
+	RelationshipManager   = "manager"
+	RelationshipReport    = "report"
+	RelationshipClient    = "client"
+	RelationshipTeacher   = "teacher"
+	RelationshipClassmate = "classmate"
+	RelationshipOther     = "other"
+)
+
+var (
+	// TestimonialRelationships contains the relationships of the submitters with the author, in the order of the form
+	TestimonialRelationships = []string{
+		RelationshipColleague, RelationshipManager, RelationshipReport, RelationshipClient,
+		RelationshipTeacher, RelationshipClassmate, RelationshipOther,
+	}
+
+	relationshipLabels = map[string]string{
+		RelationshipColleague: "Worked with me",
+		RelationshipManager:   "Managed me",
+		RelationshipReport:    "Reported to me",
+		RelationshipClient:    "Was my client",
+		RelationshipTeacher:   "Taught me",
+		RelationshipClassmate: "Studied with me",
+		RelationshipOther:     "Other",
+	}
+)
+
+// Testimonial is a recommendation of the author, shown once its submitter verified their email and the author approved it
+type Testimonial struct {
+	ID           int        `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	AuthorID     int        `json:"-"`
+	Name         string     `json:"name"`
+	Email        string     `json:"-"`
+	Role         string     `json:"role"`
+	Company      string     `json:"company"`
+	Relationship string     `json:"relationship"`
+	Message      string     `json:"message"`
+	Status       string     `json:"status"`
+	VerifiedAt   *time.Time `json:"verified_at"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+}
+
+func (testimonial *Testimonial) Validate(v *validator.Validator) {
+	v.StringCheck(testimonial.Name, 2, 70, true, "name")
+	v.ValidateEmail(testimonial.Email)
+	v.StringCheck(testimonial.Role, 0, 120, false, "role")
+	v.StringCheck(testimonial.Company, 0, 120, false, "company")
+	v.Check(validator.PermittedValue(testimonial.Relationship, TestimonialRelationships...), "relationship", "invalid relationship")
+	v.StringCheck(testimonial.Message, 20, 2_000, true, "message")
+}
+
+// RelationshipLabel returns the description of the relationship ("Worked with me"...)
+func RelationshipLabel(relationship string) string {
+	return relationshipLabels[relationship]
+}
+
+// RelationshipLabel returns the description of the relationship of the submitter with the author
+func (testimonial *Testimonial) RelationshipLabel() string {
+	return RelationshipLabel(testimonial.Relationship)
+}
+
+type TestimonialModel struct {
+	db *sql.DB
+}
+
+// testimonialColumns are the columns scanned by scanTestimonial, t being the testimonials table
+const testimonialColumns = `
+	t.id, t.created_at, t.author_id, t.name, t.email, t.role, t.company, t.relationship, t.message, t.status, t.verified_at, t.reviewed_at`
+
+// scanTestimonial reads a row selected with testimonialColumns
+func scanTestimonial(row interface{ Scan(...any) error }) (*Testimonial, error) {
+
+	var testimonial Testimonial
+	err := row.Scan(
+		&testimonial.ID,
+		&testimonial.CreatedAt,
+		&testimonial.AuthorID,
+		&testimonial.Name,
+		&testimonial.Email,
+		&testimonial.Role,
+		&testimonial.Company,
+		&testimonial.Relationship,
+		&testimonial.Message,
+		&testimonial.Status,
+		&testimonial.VerifiedAt,
+		&testimonial.ReviewedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &testimonial, nil
+}
+
+// Insert records a new testimonial waiting for the verification of the email of its submitter
+func (m TestimonialModel) Insert(testimonial *Testimonial) error {
+
+	// generating the query
+	query := `
+		INSERT INTO testimonials (author_id, name, email, role, company, relationship, message, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at;`
+
+	// setting the arguments
+	testimonial.Status = TestimonialUnverified
+	args := []any{
+		testimonial.AuthorID,
+		testimonial.Name,
+		testimonial.Email,
+		testimonial.Role,
+		testimonial.Company,
+		testimonial.Relationship,
+		testimonial.Message,
+		testimonial.Status,
+	}
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	return m.db.QueryRowContext(ctx, query, args...).Scan(&testimonial.ID, &testimonial.CreatedAt)
+}
+
+// GetForToken returns the testimonial of a valid token of the scope tokenScope
+func (m TestimonialModel) GetForToken(tokenScope, tokenPlaintext string) (*Testimonial, error) {
+
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	// generating the query
+	query := `
+		SELECT ` + testimonialColumns + `
+		FROM testimonials t
+		INNER JOIN tokens
+		ON t.id = tokens.testimonial_id
+		WHERE tokens.hash = $1
+		AND tokens.scope = $2
+		AND tokens.expiry > $3;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	testimonial, err := scanTestimonial(m.db.QueryRowContext(ctx, query, tokenHash[:], tokenScope, time.Now()))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return testimonial, nil
+}
+
+// Verify submits the testimonial id to the approval of the author once the email of its submitter is verified,
+// removing its tokens
+func (m TestimonialModel) Verify(id int) error {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// executing the queries
+	result, err := tx.ExecContext(ctx, `
+		UPDATE testimonials
+		SET status = $1, verified_at = NOW()
+		WHERE id = $2 AND status = $3;`, TestimonialPending, id, TestimonialUnverified)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE testimonial_id = $1;`, id)
+	if err != nil {
+		return err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetForAuthor returns the verified testimonials of the author authorID, the pending ones first
+func (m TestimonialModel) GetForAuthor(authorID int) ([]*Testimonial, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + testimonialColumns + `
+		FROM testimonials t
+		WHERE t.author_id = $1 AND t.status <> $2
+		ORDER BY t.status = $3 DESC, t.created_at DESC, t.id DESC;`
+
+	return m.query(query, authorID, TestimonialUnverified, TestimonialPending)
+}
+
+// GetApproved returns the approved testimonials of the author authorID, the most recent first
+func (m TestimonialModel) GetApproved(authorID int) ([]*Testimonial, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + testimonialColumns + `
+		FROM testimonials t
+		WHERE t.author_id = $1 AND t.status = $2
+		ORDER BY t.created_at DESC, t.id DESC;`
+
+	return m.query(query, authorID, TestimonialApproved)
+}
+
+// query returns the testimonials selected with testimonialColumns by query
+func (m TestimonialModel) query(query string, args ...any) ([]*Testimonial, error) {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the testimonials
+	var testimonials []*Testimonial
+	for rows.Next() {
+		testimonial, err := scanTestimonial(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		testimonials = append(testimonials, testimonial)
+	}
+
+	return testimonials, rows.Err()
+}
+
+// Review approves or rejects the verified testimonial id of the author authorID
+func (m TestimonialModel) Review(id, authorID int, status string) error {
+
+	if status != TestimonialApproved && status != TestimonialRejected {
+		return ErrRecordNotFound
+	}
+
+	// generating the query
+	query := `
+		UPDATE testimonials
+		SET status = $1, reviewed_at = NOW()
+		WHERE id = $2 AND author_id = $3 AND status <> $4;`
+
+	return m.exec(query, status, id, authorID, TestimonialUnverified)
+}
+
+// Delete removes the testimonial id of the author authorID
+func (m TestimonialModel) Delete(id, authorID int) error {
+
+	// generating the query
+	query := `
+		DELETE FROM testimonials
+		WHERE id = $1 AND author_id = $2;`
+
+	return m.exec(query, id, authorID)
+}
+
+// exec runs query, returning ErrRecordNotFound if no testimonial was affected
+func (m TestimonialModel) exec(query string, args ...any) error {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	// checking that the testimonial existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the testimonials whose submitter didn't verify their email in time
+func (m TestimonialModel) DeleteExpired(ttl time.Duration) error {
+
+	// generating the query
+	query := `
+		DELETE FROM testimonials
+		WHERE status = $1 AND created_at < $2;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	_, err := m.db.ExecContext(ctx, query, TestimonialUnverified, time.Now().Add(-ttl))
+	if err != nil {
+		return fmt.Errorf("failed to delete expired testimonials: %w", err)
+	}
+
+	return nil
+}
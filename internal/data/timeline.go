@@ -0,0 +1,290 @@
+package data
+
+import (
+	"Portfolio/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	TimelineFormation  = "formations"
+	TimelineExperience = "experiences"
+)
+
+// TimelineKinds contains the kinds of timeline entries, in the order of the author profile
+var TimelineKinds = []string{TimelineFormation, TimelineExperience}
+
+// timelineKinds maps the kinds of timeline entries to their table and to the column holding their title
+var timelineKinds = map[string]struct{ table, title string }{
+	TimelineFormation:  {"formations", "degree"},
+	TimelineExperience: {"experiences", "role"},
+}
+
+// TimelineEntry is a formation or an experience of the author, Title being the degree or the role
+type TimelineEntry struct {
+	ID           int        `json:"id"`
+	Kind         string     `json:"kind"`
+	AuthorID     int        `json:"-"`
+	Organization string     `json:"organization"`
+	Title        string     `json:"title"`
+	Location     string     `json:"location"`
+	StartDate    *time.Time `json:"start_date"`
+	EndDate      *time.Time `json:"end_date"`
+	Description  string     `json:"description"`
+	URL          string     `json:"url"`
+	Position     int        `json:"position"`
+}
+
+// IsTimelineKind checks if kind is a kind of timeline entries
+func IsTimelineKind(kind string) bool {
+	_, ok := timelineKinds[kind]
+	return ok
+}
+
+func (entry *TimelineEntry) Validate(v *validator.Validator) {
+	v.Check(IsTimelineKind(entry.Kind), "kind", "invalid timeline kind")
+	v.StringCheck(entry.Title, 2, 200, true, "title")
+	v.Check(len(entry.Organization) <= 200, "organization", "must not be more than 200 bytes long")
+	v.Check(len(entry.Location) <= 120, "location", "must not be more than 120 bytes long")
+	v.Check(len(entry.Description) <= 2_000, "description", "must not be more than 2000 bytes long")
+	v.Check(entry.StartDate == nil || entry.EndDate == nil || !entry.EndDate.Before(*entry.StartDate), "end_date", "must not be before the start date")
+	v.CheckURL(entry.URL, "url")
+}
+
+// Period returns the month range of the entry ("Sep 2020 – Jun 2023", "Since Sep 2020"...)
+func (entry *TimelineEntry) Period() string {
+	return monthRange(entry.StartDate, entry.EndDate)
+}
+
+// monthRange returns the months from start to end, any of them being optional
+func monthRange(start, end *time.Time) string {
+	switch {
+	case start != nil && end != nil:
+		if start.Year() == end.Year() && start.Month() == end.Month() {
+			return start.Format("Jan 2006")
+		}
+		return start.Format("Jan 2006") + " – " + end.Format("Jan 2006")
+	case start != nil:
+		return "Since " + start.Format("Jan 2006")
+	case end != nil:
+		return "Until " + end.Format("Jan 2006")
+	default:
+		return ""
+	}
+}
+
+// Timeline returns the formations and the experiences of the author, the most recent first (the undated ones last)
+func (author *Author) Timeline() []*TimelineEntry {
+
+	entries := append(append([]*TimelineEntry{}, author.Formations...), author.Experiences...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].StartDate, entries[j].StartDate
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.After(*b)
+	})
+
+	return entries
+}
+
+// GetTimeline returns the formations and the experiences of the author authorID, in their order
+func (m AuthorModel) GetTimeline(authorID int) ([]*TimelineEntry, []*TimelineEntry, error) {
+
+	// generating the query
+	query := `
+		SELECT 'formations', id, author_id, organization, degree, location, start_date, end_date, description, url, position
+		FROM formations
+		WHERE author_id = $1
+		UNION ALL
+		SELECT 'experiences', id, author_id, organization, role, location, start_date, end_date, description, url, position
+		FROM experiences
+		WHERE author_id = $1
+		ORDER BY position, start_date DESC NULLS LAST, id;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, authorID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// splitting the entries by kind
+	var formations, experiences []*TimelineEntry
+	for rows.Next() {
+		entry, err := scanTimelineEntry(rows)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if entry.Kind == TimelineFormation {
+			formations = append(formations, entry)
+		} else {
+			experiences = append(experiences, entry)
+		}
+	}
+
+	return formations, experiences, rows.Err()
+}
+
+// scanTimelineEntry reads a timeline entry from the columns of a row (with its kind first)
+func scanTimelineEntry(row interface{ Scan(...any) error }) (*TimelineEntry, error) {
+
+	var entry TimelineEntry
+	err := row.Scan(
+		&entry.Kind,
+		&entry.ID,
+		&entry.AuthorID,
+		&entry.Organization,
+		&entry.Title,
+		&entry.Location,
+		&entry.StartDate,
+		&entry.EndDate,
+		&entry.Description,
+		&entry.URL,
+		&entry.Position,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// GetTimelineEntry returns the entry id of the kind kind
+func (m AuthorModel) GetTimelineEntry(kind string, id int) (*TimelineEntry, error) {
+
+	kindInfo, ok := timelineKinds[kind]
+	if !ok || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	// generating the query
+	query := fmt.Sprintf(`
+		SELECT $1::text, id, author_id, organization, %s, location, start_date, end_date, description, url, position
+		FROM %s
+		WHERE id = $2;`, kindInfo.title, kindInfo.table)
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	entry, err := scanTimelineEntry(m.db.QueryRowContext(ctx, query, kind, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return entry, nil
+}
+
+// InsertTimelineEntry records a new formation or experience, setting its ID
+func (m AuthorModel) InsertTimelineEntry(entry *TimelineEntry) error {
+
+	kindInfo, ok := timelineKinds[entry.Kind]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	// generating the query
+	query := fmt.Sprintf(`
+		INSERT INTO %s (author_id, organization, %s, location, start_date, end_date, description, url, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id;`, kindInfo.table, kindInfo.title)
+
+	// setting the arguments
+	args := []any{entry.AuthorID, entry.Organization, entry.Title, entry.Location, entry.StartDate, entry.EndDate, entry.Description, entry.URL, entry.Position}
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	return m.db.QueryRowContext(ctx, query, args...).Scan(&entry.ID)
+}
+
+// UpdateTimelineEntry saves the changes of a formation or an experience
+func (m AuthorModel) UpdateTimelineEntry(entry *TimelineEntry) error {
+
+	kindInfo, ok := timelineKinds[entry.Kind]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	// generating the query
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET organization = $1, %s = $2, location = $3, start_date = $4, end_date = $5, description = $6, url = $7, position = $8
+		WHERE id = $9 AND author_id = $10;`, kindInfo.table, kindInfo.title)
+
+	// setting the arguments
+	args := []any{entry.Organization, entry.Title, entry.Location, entry.StartDate, entry.EndDate, entry.Description, entry.URL, entry.Position, entry.ID, entry.AuthorID}
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	// checking that the entry existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteTimelineEntry removes the entry id of the kind kind from the timeline of the author authorID
+func (m AuthorModel) DeleteTimelineEntry(kind string, id, authorID int) error {
+
+	kindInfo, ok := timelineKinds[kind]
+	if !ok || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	// generating the query
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE id = $1 AND author_id = $2;`, kindInfo.table)
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, id, authorID)
+	if err != nil {
+		return err
+	}
+
+	// checking that the entry existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
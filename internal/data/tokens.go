@@ -16,11 +16,13 @@ var (
 )
 
 type Token struct {
-	Plaintext string    `json:"token"`
-	Hash      []byte    `json:"-"`
-	UserID    int       `json:"-"`
-	Expiry    time.Time `json:"expiry"`
-	Scope     string    `json:"-"`
+	Plaintext     string    `json:"token"`
+	Hash          []byte    `json:"-"`
+	UserID        int       `json:"-"`
+	TestimonialID int       `json:"-"`
+	BookingID     int       `json:"-"`
+	Expiry        time.Time `json:"expiry"`
+	Scope         string    `json:"-"`
 }
 
 func generateToken(userID int, ttl time.Duration, scope string) (*Token, error) {
@@ -53,20 +55,37 @@ type TokenModel struct {
 }
 
 func (m TokenModel) New(userID int, ttl time.Duration, scope string) (*Token, error) {
+	return m.new(ttl, scope, func(token *Token) { token.UserID = userID })
+}
+
+// NewForTestimonial returns a new token for the testimonial testimonialID, whose submitter has no user account
+func (m TokenModel) NewForTestimonial(testimonialID int, ttl time.Duration, scope string) (*Token, error) {
+	return m.new(ttl, scope, func(token *Token) { token.TestimonialID = testimonialID })
+}
+
+// NewForBooking returns a new token for the booking bookingID, whose visitor has no user account
+func (m TokenModel) NewForBooking(bookingID int, ttl time.Duration, scope string) (*Token, error) {
+	return m.new(ttl, scope, func(token *Token) { token.BookingID = bookingID })
+}
+
+// new generates and saves a token, setOwner setting the user or the record it belongs to
+func (m TokenModel) new(ttl time.Duration, scope string, setOwner func(*Token)) (*Token, error) {
 
 	// generating a new token
-	token, err := generateToken(userID, ttl, scope)
+	token, err := generateToken(0, ttl, scope)
 	if err != nil {
 		return nil, err
 	}
+	setOwner(token)
 
 	// saving it in the DB (and regenerate it if it's duplicated)
 	err = m.Insert(token)
 	if errors.Is(err, ErrDuplicateToken) {
-		token, err = generateToken(userID, ttl, scope)
+		token, err = generateToken(0, ttl, scope)
 		if err != nil {
 			return nil, err
 		}
+		setOwner(token)
 
 		err = m.Insert(token)
 	}
@@ -78,11 +97,11 @@ func (m TokenModel) Insert(token *Token) error {
 
 	// generating the query
 	query := `
-		INSERT INTO tokens (hash, user_id, expiry, scope)
-		VALUES ($1, $2, $3, $4);`
+		INSERT INTO tokens (hash, user_id, testimonial_id, booking_id, expiry, scope)
+		VALUES ($1, NULLIF($2::bigint, 0), NULLIF($3::bigint, 0), NULLIF($4::bigint, 0), $5, $6);`
 
 	// setting the arguments
-	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
+	args := []any{token.Hash, token.UserID, token.TestimonialID, token.BookingID, token.Expiry, token.Scope}
 
 	// setting the timeout context for the query execution
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -109,35 +128,27 @@ func (m TokenModel) Insert(token *Token) error {
 	return nil
 }
 
+// DeleteAllForUser removes the tokens of scope of the user userID, all their tokens if scope is "*"
 func (m TokenModel) DeleteAllForUser(scope string, userID int) error {
 
 	// generating the query
 	query := `
 		DELETE FROM tokens
-       	WHERE scope = $1 AND user_id = $2;`
-
-	// setting the timeout context for the query execution
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+		WHERE scope = $1 AND user_id = $2;`
+	args := []any{scope, userID}
 
 	if scope == "*" {
 		// regenerating query
 		query = `
 			DELETE FROM tokens
-       		WHERE user_id = ?;`
-
-		// preparing the query
-		stmt, err := m.db.PrepareContext(ctx, query)
-		if err != nil {
-			return err
-		}
-		defer stmt.Close()
-
-		// executing the query
-		_, err = m.db.ExecContext(ctx, query, userID)
-		return err
+			WHERE user_id = $1;`
+		args = []any{userID}
 	}
 
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
 	// preparing the query
 	stmt, err := m.db.PrepareContext(ctx, query)
 	if err != nil {
@@ -146,7 +157,7 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int) error {
 	defer stmt.Close()
 
 	// executing the query
-	_, err = stmt.ExecContext(ctx, query, scope, userID)
+	_, err = stmt.ExecContext(ctx, args...)
 	return err
 }
 
@@ -0,0 +1,256 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// RecoveryCodeCount is the number of recovery codes generated at once
+	RecoveryCodeCount = 10
+)
+
+var (
+	ErrTOTPReused = errors.New("one-time password already used")
+
+	recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// SetTOTPSecret records the secret of the pending enrollment of the user userID in the two-factor authentication,
+// ErrRecordNotFound being returned if it is already enabled
+func (m UserModel) SetTOTPSecret(userID int, secret []byte) error {
+
+	// generating the query
+	query := `
+		UPDATE users
+		SET totp_secret = $1, totp_last_counter = 0
+		WHERE id = $2 AND totp_enabled_at IS NULL;`
+
+	return m.exec(query, secret, userID)
+}
+
+// EnableTOTP enables the two-factor authentication of the user once they typed the code of the step counter
+func (m UserModel) EnableTOTP(user *User, counter int64) error {
+
+	// generating the query
+	query := `
+		UPDATE users
+		SET totp_enabled_at = NOW(), totp_last_counter = $1
+		WHERE id = $2 AND totp_enabled_at IS NULL AND totp_secret IS NOT NULL
+		RETURNING totp_enabled_at;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	err := m.db.QueryRowContext(ctx, query, counter, user.ID).Scan(&user.TOTPEnabledAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseTOTP records the step counter of the code the user userID signed in with, ErrTOTPReused being returned
+// if this code, or a later one, was already used
+func (m UserModel) UseTOTP(userID int, counter int64) error {
+
+	// generating the query
+	query := `
+		UPDATE users
+		SET totp_last_counter = $1
+		WHERE id = $2 AND totp_enabled_at IS NOT NULL AND totp_last_counter < $1;`
+
+	err := m.exec(query, counter, userID)
+	if errors.Is(err, ErrRecordNotFound) {
+		return ErrTOTPReused
+	}
+
+	return err
+}
+
+// DisableTOTP disables the two-factor authentication of the user userID, removing their secret and their recovery codes
+func (m UserModel) DisableTOTP(userID int) error {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// executing the queries
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = 0
+		WHERE id = $1;`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1;`, userID)
+	if err != nil {
+		return err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// exec runs query, returning ErrRecordNotFound if no user was affected
+func (m UserModel) exec(query string, args ...any) error {
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	// checking that the user existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+type RecoveryCodeModel struct {
+	db *sql.DB
+}
+
+// hashRecoveryCode returns the hash of a recovery code as stored, whatever its case and its separators
+func hashRecoveryCode(code string) []byte {
+	code = strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+
+	hash := sha256.Sum256([]byte(code))
+	return hash[:]
+}
+
+// New replaces the recovery codes of the user userID with RecoveryCodeCount new ones, returning them in plaintext
+// ("abcd-efgh-ijkl-mnop") to be shown once
+func (m RecoveryCodeModel) New(userID int) ([]string, error) {
+
+	// generating the codes
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		randomBytes := make([]byte, 10)
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(randomBytes))
+		codes[i] = code[:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:]
+	}
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// executing the queries
+	_, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1;`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		_, err = tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, hash) VALUES ($1, $2);`, userID, hashRecoveryCode(code))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return codes, nil
+}
+
+// Use removes the recovery code of the user userID they signed in with, ErrRecordNotFound being returned if it doesn't exist
+func (m RecoveryCodeModel) Use(userID int, code string) error {
+
+	// generating the query
+	query := `
+		DELETE FROM recovery_codes
+		WHERE user_id = $1 AND hash = $2;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+
+	// checking that the code existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Count returns the number of recovery codes the user userID has left
+func (m RecoveryCodeModel) Count(userID int) (int, error) {
+
+	// generating the query
+	query := `SELECT count(*) FROM recovery_codes WHERE user_id = $1;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	var count int
+	err := m.db.QueryRowContext(ctx, query, userID).Scan(&count)
+
+	return count, err
+}
@@ -0,0 +1,603 @@
+package data
+
+import (
+	"Portfolio/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDuplicateUploadPath = errors.New("duplicate upload path")
+)
+
+// Upload is a name of the uploads directory pointing to a stored blob, with its metadata
+type Upload struct {
+	Path         string      `json:"path"`
+	CreatedAt    time.Time   `json:"created_at"`
+	Hash         string      `json:"hash"`
+	Size         int64       `json:"size"`
+	MIME         string      `json:"mime"`
+	Width        int         `json:"width,omitempty"`
+	Height       int         `json:"height,omitempty"`
+	OriginalName string      `json:"original_name"`
+	UploadedBy   int         `json:"uploaded_by,omitempty"`
+	Uploader     string      `json:"uploader,omitempty"`
+	Alt          string      `json:"alt"`
+	Caption      string      `json:"caption"`
+	DeletedAt    *time.Time  `json:"deleted_at,omitempty"`
+	Deleter      string      `json:"deleter,omitempty"`
+	UsedIn       []UsageLink `json:"used_in,omitempty"`
+}
+
+// UsageLink is the page of a post or of a project using an upload or showing a skill
+type UsageLink struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+func (upload *Upload) Validate(v *validator.Validator) {
+	v.StringCheck(upload.Alt, 0, 250, false, "alt")
+	v.StringCheck(upload.Caption, 0, 500, false, "caption")
+}
+
+// uploadColumns are the columns scanned by scanUpload
+const uploadColumns = `
+	f.path, f.created_at, f.hash, b.size, b.mime, COALESCE(b.width, 0), COALESCE(b.height, 0),
+	f.original_name, COALESCE(f.uploaded_by, 0), COALESCE(u.name, ''), f.alt, f.caption,
+	f.deleted_at, COALESCE(d.name, ''),
+	ARRAY(SELECT link FROM ` + uploadUsages + ` ORDER BY rank, id),
+	ARRAY(SELECT title FROM ` + uploadUsages + ` ORDER BY rank, id)`
+
+// uploadUsages lists the posts and the projects using the upload f, with their page
+const uploadUsages = `(
+		SELECT 0 AS rank, p.id, '/post/' || p.id AS link, p.title FROM upload_references r INNER JOIN posts p ON p.id = r.post_id WHERE r.path = f.path
+		UNION ALL
+		SELECT 1, p.id, '/project/' || p.id, p.title FROM project_upload_references r INNER JOIN projects p ON p.id = r.project_id WHERE r.path = f.path
+	) usages`
+
+// uploadJoins are the tables joined to get the uploadColumns
+const uploadJoins = `
+	upload_files f
+	INNER JOIN upload_blobs b ON b.hash = f.hash
+	LEFT JOIN users u ON u.id = f.uploaded_by
+	LEFT JOIN users d ON d.id = f.deleted_by`
+
+// scanUpload reads a row selected with uploadColumns
+func scanUpload(row interface{ Scan(...any) error }) (*Upload, error) {
+
+	var (
+		upload Upload
+		links  []string
+		titles []string
+	)
+
+	err := row.Scan(
+		&upload.Path,
+		&upload.CreatedAt,
+		&upload.Hash,
+		&upload.Size,
+		&upload.MIME,
+		&upload.Width,
+		&upload.Height,
+		&upload.OriginalName,
+		&upload.UploadedBy,
+		&upload.Uploader,
+		&upload.Alt,
+		&upload.Caption,
+		&upload.DeletedAt,
+		&upload.Deleter,
+		pq.Array(&links),
+		pq.Array(&titles),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range min(len(links), len(titles)) {
+		upload.UsedIn = append(upload.UsedIn, UsageLink{URL: links[i], Title: titles[i]})
+	}
+
+	return &upload, nil
+}
+
+type UploadModel struct {
+	db *sql.DB
+}
+
+// Insert adds a name pointing to a blob, creating the blob record if it is the first reference to it
+func (m UploadModel) Insert(upload *Upload) error {
+
+	// generating the queries
+	blobQuery := `
+		INSERT INTO upload_blobs (hash, size, mime, width, height, ref_count)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0), 1)
+		ON CONFLICT (hash) DO UPDATE SET ref_count = upload_blobs.ref_count + 1;`
+
+	fileQuery := `
+		INSERT INTO upload_files (path, hash, original_name, uploaded_by, alt, caption)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6)
+		RETURNING created_at;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// referencing the blob
+	_, err = tx.ExecContext(ctx, blobQuery, upload.Hash, upload.Size, upload.MIME, upload.Width, upload.Height)
+	if err != nil {
+		return err
+	}
+
+	// adding the name
+	args := []any{upload.Path, upload.Hash, upload.OriginalName, upload.UploadedBy, upload.Alt, upload.Caption}
+	err = tx.QueryRowContext(ctx, fileQuery, args...).Scan(&upload.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "upload_files_pkey"`:
+			return ErrDuplicateUploadPath
+		default:
+			return err
+		}
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the upload stored under path, unless it is in the trash
+func (m UploadModel) Get(path string) (*Upload, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM ` + uploadJoins + `
+		WHERE f.path = $1 AND f.deleted_at IS NULL;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	upload, err := scanUpload(m.db.QueryRowContext(ctx, query, path))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return upload, nil
+}
+
+// Exists checks if a name is already taken, the names of the trashed uploads being kept for their restoration
+func (m UploadModel) Exists(path string) (bool, error) {
+
+	// generating the query
+	query := `
+		SELECT EXISTS (SELECT 1 FROM upload_files WHERE path = $1);`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	var exists bool
+	err := m.db.QueryRowContext(ctx, query, path).Scan(&exists)
+
+	return exists, err
+}
+
+// GetByHash returns the names pointing to a blob, the trashed uploads aside
+func (m UploadModel) GetByHash(hash string) ([]string, error) {
+
+	// generating the query
+	query := `
+		SELECT path
+		FROM upload_files
+		WHERE hash = $1 AND deleted_at IS NULL
+		ORDER BY created_at, path;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the names
+	var paths []string
+	for rows.Next() {
+		var path string
+		err = rows.Scan(&path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, rows.Err()
+}
+
+// Search returns the uploads stored directly in dir whose name, original name, alt text or caption contain search,
+// filtered on the MIME types matching one of the mimes LIKE patterns when there are any
+func (m UploadModel) Search(dir, search string, mimes []string, filters *Filters) ([]*Upload, error) {
+
+	// generating the query
+	query := fmt.Sprintf(`
+		SELECT `+uploadColumns+`
+		FROM `+uploadJoins+`
+		WHERE starts_with(f.path, $1::text || '/') AND position('/' IN substr(f.path, length($1::text) + 2)) = 0
+		AND f.deleted_at IS NULL
+		AND ($2 = '' OR concat_ws(' ', f.path, f.original_name, f.alt, f.caption) ILIKE '%%' || $2 || '%%')
+		AND (cardinality($3::text[]) = 0 OR b.mime LIKE ANY($3))
+		ORDER BY %s %s, f.path ASC;`, filters.sortColumn(), filters.sortDirection())
+
+	// escaping the LIKE wildcards of the search
+	search = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(search)
+
+	// setting the arguments
+	args := []any{dir, search, pq.Array(mimes)}
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the uploads
+	var uploads []*Upload
+	for rows.Next() {
+		upload, err := scanUpload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		uploads = append(uploads, upload)
+	}
+
+	return uploads, rows.Err()
+}
+
+// UpdateMetadata sets the alt text and the caption of an upload
+func (m UploadModel) UpdateMetadata(upload *Upload) error {
+
+	// generating the query
+	query := `
+		UPDATE upload_files
+		SET alt = $1, caption = $2
+		WHERE path = $3 AND deleted_at IS NULL;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, upload.Alt, upload.Caption, upload.Path)
+	if err != nil {
+		return err
+	}
+
+	// checking for result
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// if nothing found
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a name of the trash for good and dereferences its blob,
+// returning the blob hash and whether nothing points to the blob anymore (its record being kept for DeleteBlob)
+func (m UploadModel) Delete(path string) (string, bool, error) {
+
+	// generating the queries
+	fileQuery := `
+		DELETE FROM upload_files
+		WHERE path = $1 AND deleted_at IS NOT NULL
+		RETURNING hash;`
+
+	blobQuery := `
+		UPDATE upload_blobs
+		SET ref_count = ref_count - 1
+		WHERE hash = $1
+		RETURNING ref_count;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// removing the name
+	var hash string
+	err = tx.QueryRowContext(ctx, fileQuery, path).Scan(&hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", false, ErrRecordNotFound
+		default:
+			return "", false, err
+		}
+	}
+
+	// dereferencing the blob
+	var refCount int
+	err = tx.QueryRowContext(ctx, blobQuery, hash).Scan(&refCount)
+	if err != nil {
+		return "", false, err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return "", false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return hash, refCount <= 0, nil
+}
+
+// DeleteBlob removes the record of the blob hash once remove deleted its content, unless a name points to it again.
+// The record stays locked meanwhile, so that an upload of the same content waits for the content to be gone
+// before referencing the blob again and storing its content anew.
+func (m UploadModel) DeleteBlob(hash string, remove func() error) error {
+
+	// generating the queries
+	lockQuery := `
+		SELECT ref_count
+		FROM upload_blobs
+		WHERE hash = $1
+		FOR UPDATE;`
+
+	deleteQuery := `
+		DELETE FROM upload_blobs
+		WHERE hash = $1;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// setting the transaction
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// checking that the blob is still unused
+	var refCount int
+	err = tx.QueryRowContext(ctx, lockQuery, hash).Scan(&refCount)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil
+		default:
+			return err
+		}
+	}
+	if refCount > 0 {
+		return nil
+	}
+
+	// removing the content, then the record
+	err = remove()
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, deleteQuery, hash)
+	if err != nil {
+		return err
+	}
+
+	// executing the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetUnusedBlobs returns the hashes of the blobs nothing points to anymore whose content is still to be removed
+func (m UploadModel) GetUnusedBlobs() ([]string, error) {
+
+	// generating the query
+	query := `
+		SELECT hash
+		FROM upload_blobs
+		WHERE ref_count <= 0;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the hashes
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err = rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// Trash moves an upload to the trash on behalf of the user userID (0 when unknown)
+func (m UploadModel) Trash(path string, userID int) error {
+
+	// generating the query
+	query := `
+		UPDATE upload_files
+		SET deleted_at = NOW(), deleted_by = NULLIF($2, 0)
+		WHERE path = $1 AND deleted_at IS NULL;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, path, userID)
+	if err != nil {
+		return err
+	}
+
+	// checking for result
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// if nothing found
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Restore takes an upload out of the trash
+func (m UploadModel) Restore(path string) error {
+
+	// generating the query
+	query := `
+		UPDATE upload_files
+		SET deleted_at = NULL, deleted_by = NULL
+		WHERE path = $1 AND deleted_at IS NOT NULL;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	result, err := m.db.ExecContext(ctx, query, path)
+	if err != nil {
+		return err
+	}
+
+	// checking for result
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// if nothing found
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetTrash returns the uploads of the trash deleted before the time before, the latest first
+func (m UploadModel) GetTrash(before time.Time) ([]*Upload, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM ` + uploadJoins + `
+		WHERE f.deleted_at IS NOT NULL AND f.deleted_at <= $1
+		ORDER BY f.deleted_at DESC, f.path;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the uploads
+	var uploads []*Upload
+	for rows.Next() {
+		upload, err := scanUpload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		uploads = append(uploads, upload)
+	}
+
+	return uploads, rows.Err()
+}
+
+// GetOrphans returns the uploads no post nor project uses, the avatars and the CV file aside, the largest first
+func (m UploadModel) GetOrphans() ([]*Upload, error) {
+
+	// generating the query
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM ` + uploadJoins + `
+		WHERE f.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM upload_references r WHERE r.path = f.path)
+		AND NOT EXISTS (SELECT 1 FROM project_upload_references r WHERE r.path = f.path)
+		AND NOT EXISTS (SELECT 1 FROM author a WHERE '/' || f.path IN (a.avatar, a.cv_file))
+		AND NOT EXISTS (SELECT 1 FROM users au WHERE au.avatar = '/' || f.path)
+		ORDER BY b.size DESC, f.path;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the uploads
+	var uploads []*Upload
+	for rows.Next() {
+		upload, err := scanUpload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		uploads = append(uploads, upload)
+	}
+
+	return uploads, rows.Err()
+}
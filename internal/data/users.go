@@ -17,20 +17,43 @@ var (
 )
 
 type User struct {
-	ID        int       `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  password  `json:"-"`
-	Avatar    string    `json:"avatar,omitempty"`
-	Status    string    `json:"status"`
-	Version   int       `json:"-"`
+	ID            int        `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	Password      password   `json:"-"`
+	Avatar        string     `json:"avatar,omitempty"`
+	Status        string     `json:"status"`
+	Role          string     `json:"role"`
+	TOTPSecret    []byte     `json:"-"`
+	TOTPEnabledAt *time.Time `json:"-"`
+	Version       int        `json:"-"`
 }
 
 func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
+// IsActive reports whether the user can sign in, their account being activated and not disabled
+func (u *User) IsActive() bool {
+	return u.Status == UserActivated
+}
+
+// Can reports whether the role of the user has permission
+func (u *User) Can(permission string) bool {
+	return Can(u.Role, permission)
+}
+
+// RoleLabel returns the role of the user as displayed
+func (u *User) RoleLabel() string {
+	return RoleLabel(u.Role)
+}
+
+// TOTPEnabled reports whether the user signs in with a code of their authenticator app after their password
+func (u *User) TOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
 type password struct {
 	plaintext *string
 	hash      []byte
@@ -96,12 +119,12 @@ func (m UserModel) Insert(user *User) error {
 
 	// creating the query
 	query := `
-		INSERT INTO users (name, email, password_hash, avatar, status)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (name, email, password_hash, avatar, status, role)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, version;`
 
 	// setting the arguments
-	args := []any{user.Name, user.Email, user.Password.hash, user.Avatar, user.Status}
+	args := []any{user.Name, user.Email, user.Password.hash, user.Avatar, user.Status, user.Role}
 
 	// setting the timeout context for the query execution
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -133,8 +156,8 @@ func (m UserModel) Update(user *User) error {
 	// creating the query
 	query := `
 		UPDATE users
-		SET name = $1, email = $2, password_hash = $3, avatar = $4, status = $5, version = version + 1
-		WHERE id = $6 AND version = $7
+		SET name = $1, email = $2, password_hash = $3, avatar = $4, status = $5, role = $6, version = version + 1
+		WHERE id = $7 AND version = $8
 		RETURNING version;`
 
 	// setting the arguments
@@ -144,6 +167,7 @@ func (m UserModel) Update(user *User) error {
 		user.Password.hash,
 		user.Avatar,
 		user.Status,
+		user.Role,
 		user.ID,
 		user.Version,
 	}
@@ -163,6 +187,8 @@ func (m UserModel) Update(user *User) error {
 	err = stmt.QueryRowContext(ctx, args...).Scan(&user.Version)
 	if err != nil {
 		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
 			return ErrDuplicateEmail
 		default:
@@ -259,11 +285,45 @@ func (m UserModel) Exists(id int) (bool, error) {
 	return exists, nil
 }
 
+// GetAll returns the users without their password, the first registered first
+func (m UserModel) GetAll() ([]*User, error) {
+
+	// creating the query
+	query := `
+		SELECT id, created_at, name, email, avatar, status, role, version, totp_enabled_at
+		FROM users
+		ORDER BY created_at, id;`
+
+	// setting the timeout context for the query execution
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// executing the query
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer rows.Close()
+
+	// getting the users
+	var users []*User
+	for rows.Next() {
+		var user User
+		err = rows.Scan(&user.ID, &user.CreatedAt, &user.Name, &user.Email, &user.Avatar, &user.Status, &user.Role, &user.Version, &user.TOTPEnabledAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
 func (m UserModel) GetByID(id int) (*User, error) {
 
 	// creating the query
 	query := `
-		SELECT id, created_at, name, email, password_hash, avatar, status, version
+		SELECT id, created_at, name, email, password_hash, avatar, status, role, version, totp_secret, totp_enabled_at
 		FROM users
 		WHERE id = $1;`
 
@@ -290,7 +350,10 @@ func (m UserModel) GetByID(id int) (*User, error) {
 		&user.Password.hash,
 		&user.Avatar,
 		&user.Status,
+		&user.Role,
 		&user.Version,
+		&user.TOTPSecret,
+		&user.TOTPEnabledAt,
 	)
 
 	if err != nil {
@@ -309,7 +372,7 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 
 	// creating the query
 	query := `
-		SELECT id, created_at, name, email, password_hash, avatar, status, version
+		SELECT id, created_at, name, email, password_hash, avatar, status, role, version, totp_secret, totp_enabled_at
 		FROM users
 		WHERE email = $1;`
 
@@ -336,7 +399,10 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 		&user.Password.hash,
 		&user.Avatar,
 		&user.Status,
+		&user.Role,
 		&user.Version,
+		&user.TOTPSecret,
+		&user.TOTPEnabledAt,
 	)
 
 	if err != nil {
@@ -357,7 +423,7 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 
 	// creating the query
 	query := `
-		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.avatar, users.status, users.version
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.avatar, users.status, users.role, users.version, users.totp_secret, users.totp_enabled_at
 		FROM users
 		INNER JOIN tokens
 		ON users.id = tokens.user_id
@@ -391,7 +457,10 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		&user.Password.hash,
 		&user.Avatar,
 		&user.Status,
+		&user.Role,
 		&user.Version,
+		&user.TOTPSecret,
+		&user.TOTPEnabledAt,
 	)
 
 	if err != nil {
@@ -0,0 +1,127 @@
+// Package ics writes meeting invites in the iCalendar format (RFC 5545), as attached to the mails of the bookings.
+package ics
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MethodRequest = "REQUEST"
+	MethodCancel  = "CANCEL"
+
+	// prodID identifies the site as the producer of the invites
+	prodID = "-//adebarbarin.com//Portfolio//EN"
+
+	// dateTimeFormat is the format of the UTC date-times
+	dateTimeFormat = "20060102T150405Z"
+
+	// maxLineLength is the length in bytes the lines of an invite are folded at
+	maxLineLength = 75
+)
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `;`, `\;`, "\r\n", `\n`, "\n", `\n`)
+
+// ContentType returns the media type of the invites of the method method
+func ContentType(method string) string {
+	return "text/calendar; charset=utf-8; method=" + method
+}
+
+// Person is the organizer or an attendee of a meeting
+type Person struct {
+	Name  string
+	Email string
+}
+
+// Event is a meeting, Sequence being incremented at each change (its cancellation included)
+type Event struct {
+	UID         string
+	Sequence    int
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	URL         string
+	Organizer   Person
+	Attendee    Person
+	Cancelled   bool
+}
+
+// Method returns the method of the invite of the event, cancelling it if cancelled
+func (event *Event) Method() string {
+	if event.Cancelled {
+		return MethodCancel
+	}
+	return MethodRequest
+}
+
+// Calendar returns the invite of the event
+func (event *Event) Calendar() []byte {
+
+	status := "CONFIRMED"
+	if event.Cancelled {
+		status = "CANCELLED"
+	}
+
+	var buf bytes.Buffer
+	line := func(property, value string) {
+		writeLine(&buf, property+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", prodID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", event.Method())
+	line("BEGIN", "VEVENT")
+	line("UID", event.UID)
+	line("DTSTAMP", time.Now().UTC().Format(dateTimeFormat))
+	line("DTSTART", event.Start.UTC().Format(dateTimeFormat))
+	line("DTEND", event.End.UTC().Format(dateTimeFormat))
+	line("SEQUENCE", strconv.Itoa(event.Sequence))
+	line("STATUS", status)
+	line("SUMMARY", escape(event.Summary))
+	if event.Description != "" {
+		line("DESCRIPTION", escape(event.Description))
+	}
+	if event.URL != "" {
+		line("URL", event.URL)
+	}
+	line("ORGANIZER;CN="+param(event.Organizer.Name), "mailto:"+event.Organizer.Email)
+	line("ATTENDEE;CN="+param(event.Attendee.Name)+";ROLE=REQ-PARTICIPANT;PARTSTAT=ACCEPTED;RSVP=FALSE", "mailto:"+event.Attendee.Email)
+	line("END", "VEVENT")
+	line("END", "VCALENDAR")
+
+	return buf.Bytes()
+}
+
+// escape escapes the special characters of a text value
+func escape(value string) string {
+	return textEscaper.Replace(value)
+}
+
+// param quotes a parameter value, removing the characters it can't contain
+func param(value string) string {
+	return `"` + strings.Map(func(r rune) rune {
+		if r == '"' || r < ' ' {
+			return -1
+		}
+		return r
+	}, value) + `"`
+}
+
+// writeLine writes a content line to buf, folded at maxLineLength bytes without splitting the UTF-8 characters
+func writeLine(buf *bytes.Buffer, line string) {
+	for limit := maxLineLength; len(line) > limit; limit = maxLineLength - 1 {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	buf.WriteString(line + "\r\n")
+}
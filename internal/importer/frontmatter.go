@@ -0,0 +1,246 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frontMatter contains the metadata of a Hugo or Jekyll content file
+//
+// Values are strings, booleans or string lists; nested keys are flattened with dots (e.g. "cover.image").
+type frontMatter map[string]any
+
+// frontMatterDateLayouts contains the date formats accepted in the front matter
+var frontMatterDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// str returns the first string value found in keys
+func (fm frontMatter) str(keys ...string) string {
+	for _, key := range keys {
+		switch value := fm[key].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case []string:
+			if len(value) > 0 {
+				return value[0]
+			}
+		}
+	}
+	return ""
+}
+
+// boolean returns the value of key, or def if it is not set
+func (fm frontMatter) boolean(key string, def bool) bool {
+	if value, ok := fm[key].(bool); ok {
+		return value
+	}
+	return def
+}
+
+// date returns the first valid date found in keys
+func (fm frontMatter) date(keys ...string) time.Time {
+	for _, key := range keys {
+		value := fm.str(key)
+		for _, layout := range frontMatterDateLayouts {
+			if date, err := time.Parse(layout, value); err == nil {
+				return date
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// splitFrontMatter separates the front matter (YAML, TOML or JSON) from the content of a file
+func splitFrontMatter(file []byte) (frontMatter, string, error) {
+
+	file = bytes.TrimPrefix(file, []byte("\xef\xbb\xbf"))
+	text := strings.ReplaceAll(string(file), "\r\n", "\n")
+
+	switch {
+	case strings.HasPrefix(text, "---\n"):
+		head, body, ok := strings.Cut(text[4:], "\n---")
+		if !ok {
+			return nil, "", fmt.Errorf("unclosed YAML front matter")
+		}
+		return parseYAML(head), trimDelimiterLine(body), nil
+
+	case strings.HasPrefix(text, "+++\n"):
+		head, body, ok := strings.Cut(text[4:], "\n+++")
+		if !ok {
+			return nil, "", fmt.Errorf("unclosed TOML front matter")
+		}
+		return parseTOML(head), trimDelimiterLine(body), nil
+
+	case strings.HasPrefix(text, "{"):
+		dec := json.NewDecoder(strings.NewReader(text))
+		var values map[string]any
+		err := dec.Decode(&values)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid JSON front matter: %w", err)
+		}
+		return flattenJSON("", values, frontMatter{}), text[dec.InputOffset():], nil
+	}
+
+	return frontMatter{}, text, nil
+}
+
+// trimDelimiterLine removes the end of the closing delimiter line
+func trimDelimiterLine(body string) string {
+	if _, rest, ok := strings.Cut(body, "\n"); ok {
+		return strings.TrimSpace(rest)
+	}
+	return ""
+}
+
+// parseScalar converts a YAML or TOML value to a string, a boolean or a string list
+func parseScalar(value string) any {
+
+	value = strings.TrimSpace(value)
+
+	switch {
+	case value == "true" || value == "yes":
+		return true
+	case value == "false" || value == "no":
+		return false
+	case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+		var list []string
+		for _, elem := range strings.Split(value[1:len(value)-1], ",") {
+			if elem = unquote(elem); elem != "" {
+				list = append(list, elem)
+			}
+		}
+		return list
+	}
+
+	return unquote(value)
+}
+
+// unquote removes the quotes and trailing comments of a value
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			if value[0] == '"' {
+				if s, err := strconv.Unquote(value[:end+2]); err == nil {
+					return s
+				}
+			}
+			return value[1 : end+1]
+		}
+	}
+	if before, _, ok := strings.Cut(value, " #"); ok {
+		value = before
+	}
+	return strings.TrimSpace(value)
+}
+
+// parseYAML reads the simple YAML subset used in front matters (scalars, lists and one level of nesting)
+func parseYAML(head string) frontMatter {
+
+	fm := frontMatter{}
+	var parent string
+
+	for _, line := range strings.Split(head, "\n") {
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+
+		// block list item under the last key
+		if strings.HasPrefix(trimmed, "- ") && parent != "" {
+			list, _ := fm[parent].([]string)
+			fm[parent] = append(list, unquote(trimmed[2:]))
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+
+		if indented && parent != "" {
+			key = parent + "." + key
+		} else {
+			parent = key
+		}
+
+		if strings.TrimSpace(value) != "" {
+			fm[key] = parseScalar(value)
+		}
+	}
+
+	return fm
+}
+
+// parseTOML reads the simple TOML subset used in front matters (scalars, arrays and tables)
+func parseTOML(head string) frontMatter {
+
+	fm := frontMatter{}
+	var table string
+
+	for _, line := range strings.Split(head, "\n") {
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") && !strings.Contains(line, "=") {
+			table = strings.Trim(line, "[] ")
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		if table != "" {
+			key = table + "." + key
+		}
+
+		fm[key] = parseScalar(value)
+	}
+
+	return fm
+}
+
+// flattenJSON converts the JSON front matter values to the frontMatter format
+func flattenJSON(prefix string, values map[string]any, fm frontMatter) frontMatter {
+	for key, value := range values {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case string, bool:
+			fm[key] = value
+		case map[string]any:
+			flattenJSON(key, value, fm)
+		case []any:
+			var list []string
+			for _, elem := range value {
+				list = append(list, fmt.Sprint(elem))
+			}
+			fm[key] = list
+		default:
+			fm[key] = fmt.Sprint(value)
+		}
+	}
+	return fm
+}
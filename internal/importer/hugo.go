@@ -0,0 +1,135 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	hugoFigureRX    = regexp.MustCompile(`\{\{[<%]\s*figure\s+([^>%]*?)\s*/?[>%]\}\}`)
+	hugoAttrRX      = regexp.MustCompile(`(\w+)\s*=\s*"([^"]*)"`)
+	hugoShortcodeRX = regexp.MustCompile(`\{\{[<%]\s*/?\s*(\w+)[^>%]*[>%]\}\}`)
+)
+
+// convertHugoShortcodes turns the figure shortcodes into markdown images and lists the unsupported ones
+func convertHugoShortcodes(content string) (string, []string) {
+
+	content = hugoFigureRX.ReplaceAllStringFunc(content, func(shortcode string) string {
+		attrs := make(map[string]string)
+		for _, match := range hugoAttrRX.FindAllStringSubmatch(shortcode, -1) {
+			attrs[match[1]] = match[2]
+		}
+		alt := attrs["alt"]
+		if alt == "" {
+			alt = attrs["caption"]
+		}
+		return fmt.Sprintf("![%s](%s)", alt, attrs["src"])
+	})
+
+	var notes []string
+	seen := make(map[string]bool)
+	for _, match := range hugoShortcodeRX.FindAllStringSubmatch(content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			notes = append(notes, fmt.Sprintf("unsupported shortcode %q kept as text", match[1]))
+		}
+	}
+
+	return content, notes
+}
+
+// readHugo reads the markdown pages of a Hugo site (or of its content directory)
+func readHugo(root string) ([]*entry, error) {
+
+	// finding the content and static directories
+	content := filepath.Join(root, "content")
+	if info, err := os.Stat(content); err != nil || !info.IsDir() {
+		content = root
+	}
+	static := []string{filepath.Join(root, "static"), filepath.Join(root, "assets")}
+
+	var entries []*entry
+
+	err := filepath.WalkDir(content, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// skipping the directories, the list pages and the non-markdown files
+		if d.IsDir() || strings.HasPrefix(d.Name(), "_index.") {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+
+		file, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		fm, body, err := splitFrontMatter(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		body, notes := convertHugoShortcodes(body)
+		dir := filepath.Dir(path)
+
+		entries = append(entries, &entry{
+			Source:  path,
+			Title:   fm.str("title"),
+			Date:    fm.date("date", "publishDate"),
+			Updated: fm.date("lastmod"),
+			Draft:   fm.boolean("draft", false),
+			Content: body,
+			Cover:   fm.str("image", "featured_image", "featureImage", "cover.image", "images"),
+			Notes:   notes,
+			open: func(ref string) (io.ReadCloser, error) {
+				// absolute links point to the static directories, relative ones to the page bundle
+				if strings.HasPrefix(ref, "/") {
+					return openFirst(ref, static...)
+				}
+				return openFirst(ref, dir)
+			},
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// openFirst opens ref in the first directory where it exists, without leaving the directory
+func openFirst(ref string, dirs ...string) (io.ReadCloser, error) {
+
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		ref = ref[:i]
+	}
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, filepath.FromSlash(ref))
+		if rel, err := filepath.Rel(dir, path); err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		f, err := os.Open(path)
+		if err == nil {
+			return f, nil
+		}
+	}
+
+	return nil, fmt.Errorf("file not found")
+}
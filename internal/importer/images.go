@@ -0,0 +1,167 @@
+package importer
+
+import (
+	"Portfolio/internal/uploads"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdImageRX  = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	htmlImgRX  = regexp.MustCompile(`(?i)<img\s[^>]*?src\s*=\s*["']([^"']+)["'][^>]*>`)
+	srcsetRX   = regexp.MustCompile(`(?i)\s(?:srcset|sizes)\s*=\s*("[^"]*"|'[^']*')`)
+	imageExtRX = regexp.MustCompile(`(?i)\.(png|jpe?g|gif|webp|bmp|ico|svg)$`)
+)
+
+// imageRefs returns the image references of the entry, the cover first, without duplicates
+func imageRefs(e *entry) []string {
+
+	var refs []string
+	seen := make(map[string]bool)
+
+	add := func(ref string) {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || seen[ref] || strings.HasPrefix(ref, "data:") {
+			return
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+
+	add(e.Cover)
+	for _, match := range mdImageRX.FindAllStringSubmatch(e.Content, -1) {
+		add(match[1])
+	}
+	for _, match := range htmlImgRX.FindAllStringSubmatch(e.Content, -1) {
+		add(match[1])
+	}
+
+	return refs
+}
+
+// isImage checks the extension of a reference, ignoring its query string
+func isImage(ref string) bool {
+	if u, err := url.Parse(ref); err == nil {
+		ref = u.Path
+	}
+	return imageExtRX.MatchString(ref)
+}
+
+// copyImages copies the images of the entry in the uploads directory, rewrites their links in the content,
+// and returns the new content with the list of image URLs
+func (imp *Importer) copyImages(e *entry) (string, []string, []string) {
+
+	var (
+		links  = make(map[string]string)
+		images []string
+		notes  []string
+	)
+
+	for _, ref := range imageRefs(e) {
+
+		if !isImage(ref) {
+			notes = append(notes, fmt.Sprintf("ignored non-image reference %s", ref))
+			continue
+		}
+
+		// only listing the images in dry-run mode
+		if imp.dryRun {
+			images = append(images, ref)
+			continue
+		}
+
+		link, err := imp.copyImage(e, ref)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("image %s not copied: %s", ref, err.Error()))
+			continue
+		}
+
+		links[ref] = link
+		images = append(images, link)
+	}
+
+	return rewriteImages(e.Content, links), images, notes
+}
+
+// rewriteImages replaces the image links in the markdown and HTML image tags of content,
+// dropping the responsive attributes pointing to the old site
+func rewriteImages(content string, links map[string]string) string {
+
+	replace := func(rx *regexp.Regexp) func(string) string {
+		return func(match string) string {
+			sub := rx.FindStringSubmatchIndex(match)
+			if link, ok := links[match[sub[2]:sub[3]]]; ok {
+				return match[:sub[2]] + link + match[sub[3]:]
+			}
+			return match
+		}
+	}
+
+	content = mdImageRX.ReplaceAllStringFunc(content, replace(mdImageRX))
+	content = htmlImgRX.ReplaceAllStringFunc(content, func(tag string) string {
+		return srcsetRX.ReplaceAllString(tag, "")
+	})
+	content = htmlImgRX.ReplaceAllStringFunc(content, replace(htmlImgRX))
+
+	return content
+}
+
+// copyImage saves a single image through the uploads package and returns its new URL
+func (imp *Importer) copyImage(e *entry, ref string) (string, error) {
+
+	var (
+		src io.ReadCloser
+		err error
+	)
+
+	// fetching remote images directly, local ones through the entry's source
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "//") {
+		src, err = imp.fetch(ref)
+	} else if e.open != nil {
+		src, err = e.open(ref)
+	} else {
+		err = fmt.Errorf("no way to resolve a relative link")
+	}
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	// keeping the original file name
+	name := ref
+	if u, err := url.Parse(ref); err == nil {
+		name = u.Path
+	}
+
+	upload, _, err := uploads.Save(path.Base(name), src, 0)
+	if err != nil {
+		return "", err
+	}
+
+	return "/" + upload.Path, nil
+}
+
+// fetch downloads a remote image
+func (imp *Importer) fetch(ref string) (io.ReadCloser, error) {
+
+	if strings.HasPrefix(ref, "//") {
+		ref = "https:" + ref
+	}
+
+	res, err := imp.client.Get(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return res.Body, nil
+}
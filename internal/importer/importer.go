@@ -0,0 +1,239 @@
+package importer
+
+import (
+	"Portfolio/internal/data"
+	"Portfolio/internal/validator"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownSource = errors.New("unknown import source")
+)
+
+// Sources contains all the supported import formats
+var Sources = []string{"wordpress", "hugo", "jekyll"}
+
+// entry is a post read from an export before being converted to a data.Post
+type entry struct {
+	Source  string
+	Title   string
+	Date    time.Time
+	Updated time.Time
+	Draft   bool
+	Content string
+	Cover   string
+	Notes   []string
+
+	// open returns the content of an image referenced in the entry
+	open func(ref string) (io.ReadCloser, error)
+}
+
+// Item is a line of the import report
+type Item struct {
+	Source string
+	Title  string
+	Date   time.Time
+	Images []string
+	Reason string
+	Notes  []string
+}
+
+// Report lists what has been (or would be in dry-run mode) created and skipped
+type Report struct {
+	DryRun  bool
+	Created []Item
+	Skipped []Item
+}
+
+// WriteTo prints the report in a human-readable form
+func (report *Report) WriteTo(w io.Writer) (int64, error) {
+
+	var sb strings.Builder
+
+	// setting the header according to the mode
+	action := "created"
+	if report.DryRun {
+		action = "would be created"
+		sb.WriteString("DRY RUN: nothing has been written\n\n")
+	}
+
+	fmt.Fprintf(&sb, "%d post(s) %s:\n", len(report.Created), action)
+	for _, item := range report.Created {
+		fmt.Fprintf(&sb, "  + %q (%s) from %s\n", item.Title, item.Date.Format(time.DateOnly), item.Source)
+		for _, img := range item.Images {
+			fmt.Fprintf(&sb, "      image: %s\n", img)
+		}
+		for _, note := range item.Notes {
+			fmt.Fprintf(&sb, "      note: %s\n", note)
+		}
+	}
+
+	fmt.Fprintf(&sb, "\n%d item(s) skipped:\n", len(report.Skipped))
+	for _, item := range report.Skipped {
+		fmt.Fprintf(&sb, "  - %q from %s: %s\n", item.Title, item.Source, item.Reason)
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// Importer converts exports from other blog engines into posts
+type Importer struct {
+	posts    *data.PostModel
+	authorID int
+	dryRun   bool
+	client   *http.Client
+	titles   map[string]bool
+}
+
+// New returns an importer attributing the posts to the author authorID
+func New(posts *data.PostModel, authorID int, dryRun bool) *Importer {
+	return &Importer{
+		posts:    posts,
+		authorID: authorID,
+		dryRun:   dryRun,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		titles:   make(map[string]bool),
+	}
+}
+
+// Run imports the export found at path according to its source format
+func (imp *Importer) Run(source, path string) (*Report, error) {
+
+	var (
+		entries []*entry
+		skipped []Item
+		err     error
+	)
+
+	// reading the entries from the export
+	switch source {
+	case "wordpress":
+		entries, skipped, err = imp.readWordPress(path)
+	case "hugo":
+		entries, err = readHugo(path)
+	case "jekyll":
+		entries, err = readJekyll(path)
+	default:
+		return nil, ErrUnknownSource
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		DryRun:  imp.dryRun,
+		Skipped: skipped,
+	}
+
+	// importing the entries one at a time
+	for _, e := range entries {
+		item, err := imp.importEntry(e)
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", e.Source, err)
+		}
+
+		if item.Reason != "" {
+			report.Skipped = append(report.Skipped, item)
+		} else {
+			report.Created = append(report.Created, item)
+		}
+	}
+
+	return report, nil
+}
+
+// importEntry checks an entry, copies its images and inserts the resulting post
+func (imp *Importer) importEntry(e *entry) (Item, error) {
+
+	item := Item{
+		Source: e.Source,
+		Title:  e.Title,
+		Date:   e.Date,
+		Notes:  e.Notes,
+	}
+
+	// skipping the unpublished entries
+	if e.Draft {
+		item.Reason = "draft or unpublished"
+		return item, nil
+	}
+
+	// checking the data like the post form does
+	v := validator.New()
+	v.StringCheck(e.Title, 2, 120, true, "title")
+	v.StringCheck(e.Content, 2, 10_000, true, "content")
+	if !v.Valid() {
+		var reasons []string
+		for field, msg := range v.FieldErrors {
+			reasons = append(reasons, fmt.Sprintf("%s %s", field, msg))
+		}
+		item.Reason = strings.Join(reasons, ", ")
+		return item, nil
+	}
+
+	// checking the title is not already used
+	key := strings.ToLower(e.Title)
+	if imp.titles[key] {
+		item.Reason = "duplicate title in the export"
+		return item, nil
+	}
+	exists, err := imp.posts.TitleExists(e.Title)
+	if err != nil {
+		return item, err
+	}
+	if exists {
+		item.Reason = "a post with this title already exists"
+		return item, nil
+	}
+
+	// copying the images and rewriting the links
+	content, images, notes := imp.copyImages(e)
+	item.Notes = append(item.Notes, notes...)
+	if len(images) == 0 {
+		item.Reason = "no image found (a post needs at least one)"
+		return item, nil
+	}
+	if len(images) > 5 {
+		images = images[:5]
+	}
+	item.Images = images
+
+	imp.titles[key] = true
+
+	if imp.dryRun {
+		return item, nil
+	}
+
+	// creating the post
+	post := &data.Post{
+		AuthorID:  imp.authorID,
+		Title:     e.Title,
+		Images:    images,
+		Content:   []byte(content),
+		CreatedAt: e.Date,
+		UpdatedAt: e.Updated,
+	}
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = time.Now()
+	}
+	if post.UpdatedAt.Before(post.CreatedAt) {
+		post.UpdatedAt = post.CreatedAt
+	}
+
+	err = imp.posts.Import(post)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicatePostTitle) {
+			item.Reason = "a post with this title already exists"
+			return item, nil
+		}
+		return item, err
+	}
+
+	return item, nil
+}
@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	jekyllFileRX      = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)\.(md|markdown|html)$`)
+	jekyllSiteVarRX   = regexp.MustCompile(`\{\{\s*site\.(?:baseurl|url)\s*\}\}`)
+	jekyllRelURLRX    = regexp.MustCompile(`\{\{\s*["']([^"']+)["']\s*\|\s*(?:relative_url|absolute_url)\s*\}\}`)
+	jekyllHighlightRX = regexp.MustCompile(`(?s)\{%-?\s*highlight\s+(\w+)[^%]*-?%\}(.*?)\{%-?\s*endhighlight\s*-?%\}`)
+	jekyllRawRX       = regexp.MustCompile(`\{%-?\s*(?:raw|endraw)\s*-?%\}`)
+	jekyllTagRX       = regexp.MustCompile(`\{%-?\s*(\w+)[^%]*-?%\}`)
+)
+
+// convertLiquid resolves the common Liquid tags of Jekyll posts and lists the unsupported ones
+func convertLiquid(content string) (string, []string) {
+
+	content = jekyllSiteVarRX.ReplaceAllString(content, "")
+	content = jekyllRelURLRX.ReplaceAllString(content, "$1")
+	content = jekyllHighlightRX.ReplaceAllString(content, "```$1\n$2\n```")
+	content = jekyllRawRX.ReplaceAllString(content, "")
+
+	var notes []string
+	seen := make(map[string]bool)
+	for _, match := range jekyllTagRX.FindAllStringSubmatch(content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			notes = append(notes, fmt.Sprintf("unsupported Liquid tag %q kept as text", match[1]))
+		}
+	}
+
+	return content, notes
+}
+
+// titleFromSlug builds a title from the slug of a post file name
+func titleFromSlug(slug string) string {
+	words := strings.Fields(strings.NewReplacer("-", " ", "_", " ").Replace(slug))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// readJekyll reads the posts and drafts of a Jekyll site
+func readJekyll(root string) ([]*entry, error) {
+
+	var entries []*entry
+
+	for _, dir := range []string{"_posts", "_drafts"} {
+
+		err := filepath.WalkDir(filepath.Join(root, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && dir == "_drafts" {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			// getting the date and slug from the file name (the date is optional for drafts)
+			var (
+				date time.Time
+				slug = strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
+			)
+			if match := jekyllFileRX.FindStringSubmatch(d.Name()); match != nil {
+				date, _ = time.Parse(time.DateOnly, match[1])
+				slug = match[2]
+			} else if dir == "_posts" {
+				return nil
+			}
+
+			file, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			fm, body, err := splitFrontMatter(file)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+
+			if fmDate := fm.date("date"); !fmDate.IsZero() {
+				date = fmDate
+			}
+			title := fm.str("title")
+			if title == "" {
+				title = titleFromSlug(slug)
+			}
+
+			body, notes := convertLiquid(body)
+
+			entries = append(entries, &entry{
+				Source:  path,
+				Title:   title,
+				Date:    date,
+				Updated: fm.date("last_modified_at", "updated"),
+				Draft:   dir == "_drafts" || !fm.boolean("published", true),
+				Content: body,
+				Cover:   fm.str("image", "image.path", "header.image", "feature_image", "cover"),
+				Notes:   notes,
+				open: func(ref string) (io.ReadCloser, error) {
+					// absolute links point to the site root, relative ones to the post directory
+					if strings.HasPrefix(ref, "/") {
+						return openFirst(ref, root)
+					}
+					return openFirst(ref, filepath.Dir(path), root)
+				},
+			})
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
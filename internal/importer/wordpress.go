@@ -0,0 +1,148 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// wxrDateLayout is the date format used in the WordPress eXtended RSS files
+const wxrDateLayout = "2006-01-02 15:04:05"
+
+var (
+	wpBlockCommentRX = regexp.MustCompile(`<!--\s*/?wp:[^>]*-->`)
+	wpCaptionRX      = regexp.MustCompile(`(?s)\[caption[^\]]*\](.*?)\[/caption\]`)
+)
+
+// wxr is the structure of a WordPress eXtended RSS export
+//
+// The wp: elements are matched by their local name to support every version of the format
+type wxr struct {
+	Channel struct {
+		Link  string    `xml:"link"`
+		Items []wxrItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type wxrItem struct {
+	Title         string `xml:"title"`
+	Link          string `xml:"link"`
+	Content       string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostID        int    `xml:"post_id"`
+	PostDate      string `xml:"post_date"`
+	PostDateGMT   string `xml:"post_date_gmt"`
+	ModifiedGMT   string `xml:"post_modified_gmt"`
+	Status        string `xml:"status"`
+	PostType      string `xml:"post_type"`
+	AttachmentURL string `xml:"attachment_url"`
+	Meta          []struct {
+		Key   string `xml:"meta_key"`
+		Value string `xml:"meta_value"`
+	} `xml:"postmeta"`
+}
+
+// meta returns the value of a post meta
+func (item wxrItem) meta(key string) string {
+	for _, meta := range item.Meta {
+		if meta.Key == key {
+			return meta.Value
+		}
+	}
+	return ""
+}
+
+// parseWXRDate parses a WordPress date, which is set to zeros for unpublished posts
+func parseWXRDate(values ...string) time.Time {
+	for _, value := range values {
+		if value == "" || strings.HasPrefix(value, "0000") {
+			continue
+		}
+		if date, err := time.Parse(wxrDateLayout, value); err == nil {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// cleanWordPressContent removes the block editor comments and the caption shortcodes
+func cleanWordPressContent(content string) string {
+	content = wpBlockCommentRX.ReplaceAllString(content, "")
+	content = wpCaptionRX.ReplaceAllString(content, "$1")
+	return strings.TrimSpace(content)
+}
+
+// readWordPress reads the posts of a WXR file, the other items being reported as skipped
+func (imp *Importer) readWordPress(file string) ([]*entry, []Item, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var export wxr
+	err = xml.NewDecoder(f).Decode(&export)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid WXR file: %w", err)
+	}
+
+	// mapping the attachments to find the featured images
+	attachments := make(map[string]string)
+	for _, item := range export.Channel.Items {
+		if item.PostType == "attachment" {
+			attachments[fmt.Sprint(item.PostID)] = item.AttachmentURL
+		}
+	}
+
+	// resolving the relative links against the exported site
+	site := strings.TrimSuffix(export.Channel.Link, "/")
+	open := func(ref string) (io.ReadCloser, error) {
+		if site == "" || !strings.HasPrefix(ref, "/") {
+			return nil, fmt.Errorf("cannot resolve relative link")
+		}
+		return imp.fetch(site + ref)
+	}
+
+	var (
+		entries []*entry
+		skipped []Item
+	)
+
+	for _, item := range export.Channel.Items {
+
+		source := item.Link
+		if source == "" {
+			source = fmt.Sprintf("%s#%d", file, item.PostID)
+		}
+
+		switch item.PostType {
+		case "attachment":
+			continue
+		case "post":
+		default:
+			skipped = append(skipped, Item{
+				Source: source,
+				Title:  item.Title,
+				Reason: fmt.Sprintf("unsupported post type %q", item.PostType),
+			})
+			continue
+		}
+
+		entries = append(entries, &entry{
+			Source:  source,
+			Title:   strings.TrimSpace(item.Title),
+			Date:    parseWXRDate(item.PostDateGMT, item.PostDate),
+			Updated: parseWXRDate(item.ModifiedGMT),
+			Draft:   item.Status != "publish",
+			Content: cleanWordPressContent(item.Content),
+			Cover:   attachments[item.meta("_thumbnail_id")],
+			open:    open,
+		})
+	}
+
+	return entries, skipped, nil
+}
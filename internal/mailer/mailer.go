@@ -5,12 +5,20 @@ import (
 	"embed"
 	"github.com/go-mail/mail/v2"
 	"html/template"
+	"io"
 	"time"
 )
 
 //go:embed "templates"
 var templateFS embed.FS
 
+// Attachment is a file attached to a mail
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
 type Mailer struct {
 	dialer *mail.Dialer
 	sender string
@@ -27,7 +35,7 @@ func New(host string, port int, username, password, sender string) Mailer {
 	}
 }
 
-func (m Mailer) Send(recipient, templateFile string, data any) error {
+func (m Mailer) Send(recipient, templateFile string, data any, attachments ...Attachment) error {
 
 	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
 	if err != nil {
@@ -59,6 +67,16 @@ func (m Mailer) Send(recipient, templateFile string, data any) error {
 	msg.SetBody("text/plain", plainBody.String())
 	msg.AddAlternative("text/html", htmlBody.String())
 
+	// attaching the files (written again at each sending attempt)
+	for _, attachment := range attachments {
+		header := map[string][]string{"Content-Type": {attachment.ContentType}}
+		copyData := func(w io.Writer) error {
+			_, err := w.Write(attachment.Data)
+			return err
+		}
+		msg.Attach(attachment.Filename, mail.SetHeader(header), mail.SetCopyFunc(copyData))
+	}
+
 	for range 3 {
 		err = m.dialer.DialAndSend(msg)
 		if nil == err {
@@ -0,0 +1,483 @@
+// Package qrcode encodes data as QR codes (byte mode, medium error correction level) and draws them as PNG images.
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+var ErrTooLong = errors.New("data too long for a QR code")
+
+// error correction of the level M (15% of the codewords restored), for the versions 1 to 40
+var (
+	eccCodewordsPerBlock = [41]int{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28}
+	eccBlocks            = [41]int{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49}
+)
+
+// formatLevelM is the error correction level M in the format information
+const formatLevelM = 0
+
+// Code is the grid of modules of a QR code, Size modules wide and high (without the quiet zone)
+type Code struct {
+	Size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode returns the QR code of data with the smallest version able to hold it
+func Encode(data []byte) (*Code, error) {
+
+	// choosing the version
+	version := 1
+	for ; version <= 40; version++ {
+		if 4+countBits(version)+len(data)*8 <= dataCodewords(version)*8 {
+			break
+		}
+	}
+	if version > 40 {
+		return nil, ErrTooLong
+	}
+
+	// writing the segment in byte mode, then the terminator and the padding
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := dataCodewords(version) * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+
+	// drawing the code with the mask giving the lowest penalty
+	code := newCode(version)
+	code.drawFunctionPatterns(version)
+	code.drawCodewords(addErrorCorrection(codewords, version))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		code.applyMask(mask)
+		code.drawFormatBits(mask)
+		if penalty := code.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		code.applyMask(mask) // undoing the mask (XOR)
+	}
+	code.applyMask(best)
+	code.drawFormatBits(best)
+
+	return code, nil
+}
+
+// Dark reports whether the module at the column x and the row y is dark
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y][x]
+}
+
+// Image returns the code with a quiet zone of 4 modules, each module being scale pixels wide
+func (c *Code) Image(scale int) image.Image {
+
+	const border = 4
+	size := (c.Size + 2*border) * scale
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if c.Dark(x/scale-border, y/scale-border) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	return img
+}
+
+// WritePNG writes the code to w as a PNG image, each module being scale pixels wide
+func (c *Code) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, c.Image(scale))
+}
+
+// bitBuffer is a sequence of bits, the most significant first
+type bitBuffer []bool
+
+// append adds the length lowest bits of value
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 == 1)
+	}
+}
+
+// countBits returns the length of the character count of a byte mode segment
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// rawModules returns the number of modules holding data or error correction (the format and version information aside)
+func rawModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		result -= (25*align-10)*align - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+// dataCodewords returns the number of data codewords of the version, the error correction ones aside
+func dataCodewords(version int) int {
+	return rawModules(version)/8 - eccCodewordsPerBlock[version]*eccBlocks[version]
+}
+
+// addErrorCorrection splits the data in blocks, adds their error correction codewords and interleaves them
+func addErrorCorrection(data []byte, version int) []byte {
+
+	numBlocks := eccBlocks[version]
+	eccLen := eccCodewordsPerBlock[version]
+	rawCodewords := rawModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	// the long blocks holding one more data codeword than the short ones
+	divisor := reedSolomonDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range blocks {
+		dataLen := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			dataLen++
+		}
+		block := append([]byte{}, data[k:k+dataLen]...)
+		k += dataLen
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	// interleaving the codewords of the blocks, skipping the padding of the short blocks
+	var result []byte
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+
+	return result
+}
+
+// reedSolomonDivisor returns the generator polynomial of the degree degree, its leading term aside
+func reedSolomonDivisor(degree int) []byte {
+
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	root := byte(1)
+	for range degree {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+
+	return result
+}
+
+// reedSolomonRemainder returns the error correction codewords of data
+func reedSolomonRemainder(data, divisor []byte) []byte {
+
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+
+	return result
+}
+
+// gfMultiply multiplies x and y in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func newCode(version int) *Code {
+	size := version*4 + 17
+	code := &Code{Size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for y := range size {
+		code.modules[y] = make([]bool, size)
+		code.function[y] = make([]bool, size)
+	}
+	return code
+}
+
+// setFunction draws a module of the function patterns, which the data and the masks leave out
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns and the version information,
+// reserving the format information drawn once the mask chosen
+func (c *Code) drawFunctionPatterns(version int) {
+
+	// timing patterns
+	for i := range c.Size {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// finder patterns with their separators
+	for _, center := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if x >= 0 && y >= 0 && x < c.Size && y < c.Size {
+					dist := max(abs(dx), abs(dy))
+					c.setFunction(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+
+	// alignment patterns, the ones overlapping the finder patterns aside
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// reserving the format information
+	c.drawFormatBits(0)
+
+	// version information
+	if version >= 7 {
+		rem := version
+		for range 12 {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := range 18 {
+			dark := (bits>>i)&1 == 1
+			a, b := c.Size-11+i%3, i/3
+			c.setFunction(a, b, dark)
+			c.setFunction(b, a, dark)
+		}
+	}
+}
+
+// alignmentPositions returns the coordinates of the centers of the alignment patterns, in both directions
+func alignmentPositions(version int) []int {
+
+	if version == 1 {
+		return nil
+	}
+
+	align := version/7 + 2
+	step := (version*8 + align*3 + 5) / (align*4 - 4) * 2
+	result := make([]int, align)
+	result[0] = 6
+	for i, pos := align-1, version*4+10; i > 0; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+
+	return result
+}
+
+// drawFormatBits draws both copies of the format information of the mask mask
+func (c *Code) drawFormatBits(mask int) {
+
+	data := formatLevelM<<3 | mask
+	rem := data
+	for range 10 {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	// around the top left finder pattern
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	// along the other finder patterns, with the dark module
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+// drawCodewords fills the modules left by the function patterns with data, in zigzag from the bottom right corner
+func (c *Code) drawCodewords(data []byte) {
+
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skipping the vertical timing pattern
+		}
+		for vert := range c.Size {
+			for j := range 2 {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert // upward
+				}
+				if !c.function[y][x] && i < len(data)*8 {
+					c.modules[y][x] = (data[i>>3]>>(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by the mask mask, applying it twice undoing it
+func (c *Code) applyMask(mask int) {
+	for y := range c.Size {
+		for x := range c.Size {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip && !c.function[y][x] {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to read: the runs of modules of the same color, the 2×2 blocks,
+// the patterns looking like the finder ones and the imbalance of dark and light modules
+func (c *Code) penalty() int {
+
+	result, dark := 0, 0
+
+	// the rows, then the columns
+	for _, at := range []func(i, j int) bool{
+		func(i, j int) bool { return c.modules[i][j] },
+		func(i, j int) bool { return c.modules[j][i] },
+	} {
+		for i := range c.Size {
+			run, window := 0, 0
+			for j := range c.Size {
+
+				// runs of 5 modules or more
+				if j > 0 && at(i, j) == at(i, j-1) {
+					run++
+					if run == 5 {
+						result += 3
+					} else if run > 5 {
+						result++
+					}
+				} else {
+					run = 1
+				}
+
+				// finder-like patterns (1:1:3:1:1 with 4 light modules on a side)
+				window = (window<<1 | btoi(at(i, j))) & 0x7FF
+				if j >= 10 && (window == 0b00001011101 || window == 0b10111010000) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	for y := range c.Size {
+		for x := range c.Size {
+
+			// blocks of 2×2 modules of the same color
+			m := c.modules[y][x]
+			if x > 0 && y > 0 && m == c.modules[y-1][x] && m == c.modules[y][x-1] && m == c.modules[y-1][x-1] {
+				result += 3
+			}
+
+			if m {
+				dark++
+			}
+		}
+	}
+
+	// balance of the dark modules, by steps of 5% away from the half
+	total := c.Size * c.Size
+	result += ((abs(dark*20-total*10)+total-1)/total - 1) * 10
+
+	return result
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,166 @@
+// Package resume exports the author profile in the JSON Resume schema (https://jsonresume.org/schema) and as a vCard.
+package resume
+
+import (
+	"Portfolio/internal/data"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schemaURL is the JSON schema the exported resumes follow
+const schemaURL = "https://raw.githubusercontent.com/jsonresume/resume-schema/v1.0.0/schema.json"
+
+// Resume is a resume in the JSON Resume schema
+type Resume struct {
+	Schema    string      `json:"$schema"`
+	Basics    Basics      `json:"basics"`
+	Work      []Work      `json:"work"`
+	Education []Education `json:"education"`
+	Skills    []Skill     `json:"skills"`
+	Projects  []Project   `json:"projects"`
+	Meta      Meta        `json:"meta"`
+}
+
+type Basics struct {
+	Name     string   `json:"name"`
+	Label    string   `json:"label,omitempty"`
+	Image    string   `json:"image,omitempty"`
+	Email    string   `json:"email,omitempty"`
+	URL      string   `json:"url"`
+	Summary  string   `json:"summary,omitempty"`
+	Location Location `json:"location"`
+}
+
+type Location struct {
+	City string `json:"city,omitempty"`
+}
+
+type Work struct {
+	Name      string `json:"name,omitempty"`
+	Position  string `json:"position"`
+	Location  string `json:"location,omitempty"`
+	URL       string `json:"url,omitempty"`
+	StartDate string `json:"startDate,omitempty"`
+	EndDate   string `json:"endDate,omitempty"`
+	Summary   string `json:"summary,omitempty"`
+}
+
+type Education struct {
+	Institution string `json:"institution,omitempty"`
+	URL         string `json:"url,omitempty"`
+	StudyType   string `json:"studyType"`
+	StartDate   string `json:"startDate,omitempty"`
+	EndDate     string `json:"endDate,omitempty"`
+}
+
+type Skill struct {
+	Name     string   `json:"name"`
+	Level    string   `json:"level"`
+	Keywords []string `json:"keywords"`
+}
+
+type Project struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	URL         string   `json:"url"`
+	StartDate   string   `json:"startDate,omitempty"`
+	EndDate     string   `json:"endDate,omitempty"`
+}
+
+type Meta struct {
+	Canonical    string `json:"canonical"`
+	LastModified string `json:"lastModified"`
+}
+
+// New returns the resume of author with the projects, siteURL being the scheme and the host of the site ("https://example.com")
+func New(author *data.Author, projects []*data.Project, siteURL string) *Resume {
+
+	resume := &Resume{
+		Schema: schemaURL,
+		Basics: Basics{
+			Name:     author.Name,
+			Label:    author.StatusActivity,
+			Image:    absolute(siteURL, author.Avatar),
+			Email:    author.Email,
+			URL:      siteURL + author.URL(),
+			Location: Location{City: author.Location},
+		},
+		Work:      []Work{},
+		Education: []Education{},
+		Skills:    []Skill{},
+		Projects:  []Project{},
+		Meta: Meta{
+			Canonical:    siteURL + author.URL() + "/resume.json",
+			LastModified: author.UpdatedAt.UTC().Format(time.RFC3339),
+		},
+	}
+	if author.Presentation != nil {
+		resume.Basics.Summary = *author.Presentation
+	}
+
+	for _, entry := range author.Experiences {
+		resume.Work = append(resume.Work, Work{
+			Name:      entry.Organization,
+			Position:  entry.Title,
+			Location:  entry.Location,
+			URL:       entry.URL,
+			StartDate: isoDate(entry.StartDate),
+			EndDate:   isoDate(entry.EndDate),
+			Summary:   entry.Description,
+		})
+	}
+
+	for _, entry := range author.Formations {
+		resume.Education = append(resume.Education, Education{
+			Institution: entry.Organization,
+			URL:         entry.URL,
+			StudyType:   entry.Title,
+			StartDate:   isoDate(entry.StartDate),
+			EndDate:     isoDate(entry.EndDate),
+		})
+	}
+
+	for _, skill := range author.Skills {
+		resume.Skills = append(resume.Skills, Skill{
+			Name:     skill.Name,
+			Level:    skill.LevelName(),
+			Keywords: []string{data.SkillCategoryLabel(skill.Category)},
+		})
+	}
+
+	for _, project := range projects {
+		item := Project{
+			Name:        project.Title,
+			Description: project.Summary,
+			Keywords:    project.TechStack,
+			URL:         siteURL + "/project/" + strconv.Itoa(project.ID),
+			StartDate:   isoDate(project.StartedOn),
+			EndDate:     isoDate(project.EndedOn),
+		}
+		if project.Role != "" {
+			item.Roles = []string{project.Role}
+		}
+		resume.Projects = append(resume.Projects, item)
+	}
+
+	return resume
+}
+
+// isoDate returns the month of date in the ISO 8601 format ("2020-09"), empty if nil
+func isoDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format("2006-01")
+}
+
+// absolute returns the URL of path on the site, path being kept if it is already an absolute URL
+func absolute(siteURL, path string) string {
+	if strings.HasPrefix(path, "/") {
+		return siteURL + path
+	}
+	return path
+}
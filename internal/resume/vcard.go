@@ -0,0 +1,67 @@
+package resume
+
+import (
+	"Portfolio/internal/data"
+	"bytes"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineLength is the length in bytes the lines of a vCard are folded at
+const maxLineLength = 75
+
+var vCardEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `;`, `\;`, "\r\n", `\n`, "\n", `\n`)
+
+// VCard returns the contact card of author in the vCard 3.0 format (RFC 2426)
+func VCard(author *data.Author, siteURL string) []byte {
+
+	// splitting the name on its first space ("Antoine de Barbarin" gives "Antoine" and "de Barbarin")
+	given, family, _ := strings.Cut(author.Name, " ")
+
+	var buf bytes.Buffer
+	line := func(property string, values ...string) {
+		writeLine(&buf, property+":"+strings.Join(values, ";"))
+	}
+
+	line("BEGIN", "VCARD")
+	line("VERSION", "3.0")
+	line("N", escape(family), escape(given), "", "", "")
+	line("FN", escape(author.Name))
+	if author.StatusActivity != "" {
+		line("TITLE", escape(author.StatusActivity))
+	}
+	line("EMAIL;TYPE=INTERNET", escape(author.Email))
+	if author.Location != "" {
+		line("ADR", "", "", "", escape(author.Location), "", "", "")
+	}
+	if birth := author.BirthDate(); birth != "" {
+		line("BDAY", birth)
+	}
+	if author.Avatar != "" {
+		line("PHOTO;VALUE=URI", absolute(siteURL, author.Avatar))
+	}
+	line("URL", siteURL+author.URL())
+	line("REV", author.UpdatedAt.UTC().Format(time.RFC3339))
+	line("END", "VCARD")
+
+	return buf.Bytes()
+}
+
+// escape escapes the special characters of a vCard text value
+func escape(value string) string {
+	return vCardEscaper.Replace(value)
+}
+
+// writeLine writes a content line to buf, folded at maxLineLength bytes without splitting the UTF-8 characters
+func writeLine(buf *bytes.Buffer, line string) {
+	for limit := maxLineLength; len(line) > limit; limit = maxLineLength - 1 {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	buf.WriteString(line + "\r\n")
+}
@@ -0,0 +1,103 @@
+// Package totp generates and checks the time-based one-time passwords (RFC 6238) of the two-factor authentication,
+// as computed by the authenticator apps (HMAC-SHA1, 6 digits, 30 seconds steps).
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the length of the codes, modulo being 10^Digits
+	Digits = 6
+	modulo = 1_000_000
+
+	// Period is the time a code is valid for
+	Period = 30 * time.Second
+
+	// secretSize is the size in bytes of the secrets (160 bits, as recommended for HMAC-SHA1)
+	secretSize = 20
+
+	// skew is the number of steps accepted before and after the current one, the clocks of the phones drifting
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a new random secret
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, secretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Encode returns the secret as typed in the authenticator apps ("JBSWY3DPEHPK3PXP...")
+func Encode(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// URI returns the otpauth URI of the secret the authenticator apps read from the QR codes,
+// the account being shown under the name of the issuer
+func URI(secret []byte, issuer, account string) string {
+
+	params := url.Values{}
+	params.Set("secret", Encode(secret))
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(Digits))
+	params.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Counter returns the number of the step of t
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code returns the code of the step counter
+func Code(secret []byte, counter int64) string {
+
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%modulo)
+}
+
+// Validate reports whether code is the code of the secret at t, give or take a step, returning the step it matched
+// (recorded to refuse the code once used)
+func Validate(secret []byte, code string, t time.Time) (int64, bool) {
+
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	current := Counter(t)
+	for counter := current - skew; counter <= current+skew; counter++ {
+		if hmac.Equal([]byte(Code(secret, counter)), []byte(code)) {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
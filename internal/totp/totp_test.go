@@ -0,0 +1,63 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 secret of the test vectors of RFC 6238 (appendix B)
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+
+	// the RFC codes have 8 digits, the last 6 being the codes of the authenticator apps
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got := Code(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+		if got != tt.want {
+			t.Errorf("Code at %d = %q, want %q", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	now := time.Unix(1111111111, 0)
+	current := Counter(now)
+
+	tests := []struct {
+		name    string
+		code    string
+		want    bool
+		counter int64
+	}{
+		{"current step", Code(rfcSecret, current), true, current},
+		{"previous step", Code(rfcSecret, current-1), true, current - 1},
+		{"next step", Code(rfcSecret, current+1), true, current + 1},
+		{"spaced code", "050 471", true, current},
+		{"two steps ago", Code(rfcSecret, current-2), false, 0},
+		{"two steps ahead", Code(rfcSecret, current+2), false, 0},
+		{"short code", "05047", false, 0},
+		{"wrong code", "000000", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := Validate(rfcSecret, tt.code, now)
+			if ok != tt.want || counter != tt.counter {
+				t.Errorf("Validate(%q) = %d, %t, want %d, %t", tt.code, counter, ok, tt.counter, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package uploads
+
+import (
+	"Portfolio/internal/data"
+	"Portfolio/internal/validator"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+var (
+	ErrArchive         = errors.New("invalid ZIP archive")
+	ErrArchiveTooLarge = errors.New("archive too large")
+)
+
+// ArchiveEntry is the outcome of the extraction of a file of a ZIP archive, Error being set when the file was rejected
+type ArchiveEntry struct {
+	Name  string `json:"name"`
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// archivePath returns the folder and the name an entry of an archive is extracted to in dir,
+// rejecting the absolute paths, the parent references (zip-slip) and the invalid names
+func archivePath(dir, name string) (string, string, error) {
+
+	if strings.Contains(name, `\`) || path.IsAbs(name) {
+		return "", "", ErrFileName
+	}
+
+	segments := strings.Split(name, "/")
+	for _, segment := range segments[:len(segments)-1] {
+		if !checkFolderName(segment) {
+			return "", "", ErrFolderName
+		}
+	}
+
+	base := segments[len(segments)-1]
+	if !validator.CheckFileName(base) || strings.HasPrefix(base, ".") {
+		return "", "", ErrFileName
+	}
+
+	return path.Join(append([]string{dir}, segments[:len(segments)-1]...)...), base, nil
+}
+
+// isArchiveMetadata checks if an entry of an archive holds the metadata of the system that created it (__MACOSX, .DS_Store...)
+func isArchiveMetadata(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "__MACOSX" || strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Extract saves the files of the ZIP archive of size bytes to the folder dir on behalf of the user userID,
+// the directories of the archive becoming subfolders of dir.
+//
+// Every file goes through the checks of Add, the rejected ones being reported with their error.
+// The whole archive is refused when it holds more files or more bytes than the policy allows.
+func Extract(archive io.ReaderAt, size int64, dir string, userID int) ([]ArchiveEntry, error) {
+
+	dir, err := checkFolder(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(archive, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrArchive, err)
+	}
+
+	// keeping the regular files only (no directory nor symbolic link)
+	var files []*zip.File
+	var total uint64
+	for _, f := range zr.File {
+		if f.Mode()&fs.ModeType != 0 || isArchiveMetadata(f.Name) {
+			continue
+		}
+		files = append(files, f)
+		total += f.UncompressedSize64
+	}
+
+	// checking the announced sizes before decompressing anything,
+	// the zip reader failing on the entries holding more bytes than announced
+	if len(files) > policy.MaxArchiveFiles {
+		return nil, fmt.Errorf("%w: the limit is %d files", ErrArchiveTooLarge, policy.MaxArchiveFiles)
+	}
+	if total > uint64(policy.MaxArchiveSize) {
+		return nil, fmt.Errorf("%w: the limit is %d bytes once extracted", ErrArchiveTooLarge, policy.MaxArchiveSize)
+	}
+
+	entries := make([]ArchiveEntry, 0, len(files))
+	created := map[string]bool{dir: true}
+
+	for _, f := range files {
+
+		entry := ArchiveEntry{Name: f.Name}
+		upload, err := extractFile(f, dir, userID, created)
+		switch {
+		case err == nil:
+			entry.Path = upload.Path
+		case errors.Is(err, ErrFileName), errors.Is(err, ErrFolderName), errors.Is(err, ErrFolderExists),
+			errors.Is(err, ErrForbiddenType), errors.Is(err, ErrFileTooLarge),
+			errors.Is(err, zip.ErrFormat), errors.Is(err, zip.ErrChecksum), errors.Is(err, zip.ErrAlgorithm), errors.Is(err, io.ErrUnexpectedEOF):
+			entry.Error = err.Error()
+		default:
+			return entries, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// extractFile saves a file of an archive in its folder of dir, creating the folders missing in the catalog
+func extractFile(f *zip.File, dir string, userID int, created map[string]bool) (*data.Upload, error) {
+
+	folder, name, err := archivePath(dir, f.Name)
+	if err != nil {
+		return nil, err
+	}
+	if f.UncompressedSize64 > uint64(policy.MaxFileSize) {
+		return nil, fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, policy.MaxFileSize)
+	}
+
+	// creating the folders from dir, a file and a folder not sharing a name
+	var missing []string
+	for parent := folder; !created[parent]; parent = path.Dir(parent) {
+		exists, err := catalog.Exists(parent)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrFolderExists
+		}
+		missing = append(missing, parent)
+	}
+	if len(missing) > 0 {
+		err = catalog.EnsureFolders(missing...)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range missing {
+			created[parent] = true
+		}
+	}
+
+	src, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	upload, _, err := save(name, folder, src, userID)
+	return upload, err
+}
+
+// WriteArchive streams a ZIP archive of the files of the folder dir and of its subfolders to w
+func WriteArchive(w io.Writer, dir string) error {
+
+	dir, err := checkFolder(dir)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	err = archiveFolder(zw, dir, dir)
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// archiveFolder adds the files of the folder dir to the archive, under their path relative to root
+func archiveFolder(zw *zip.Writer, root, dir string) error {
+
+	files, err := Get(dir, "", "", data.NewUploadFilters(nil))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+
+		name := strings.TrimPrefix(file.Path, root+"/")
+
+		// keeping the empty folders in the archive
+		if file.IsDir() {
+			_, err = zw.Create(name + "/")
+			if err != nil {
+				return err
+			}
+			err = archiveFolder(zw, root, file.Path)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: file.Upload.CreatedAt}
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		content, err := store.Open(blobKey(file.Upload.Hash))
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", file.Path, err)
+		}
+		_, err = io.Copy(fw, content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package uploads
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// TestArchivePath extracts the entries of a zip-slip archive, which must all stay in the destination folder
+func TestArchivePath(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		folder  string
+		file    string
+		wantErr error
+	}{
+		{name: "photo.png", folder: "uploads/images", file: "photo.png"},
+		{name: "holidays/2024/beach.jpg", folder: "uploads/images/holidays/2024", file: "beach.jpg"},
+		{name: "../../../etc/passwd", wantErr: ErrFolderName},
+		{name: "holidays/../../../cmd/web/main.go", wantErr: ErrFolderName},
+		{name: "./photo.png", wantErr: ErrFolderName},
+		{name: "/etc/passwd", wantErr: ErrFileName},
+		{name: `..\..\windows\system.ini`, wantErr: ErrFileName},
+		{name: "holidays/.hidden/photo.png", wantErr: ErrFolderName},
+		{name: "holidays//photo.png", wantErr: ErrFolderName},
+		{name: "holidays/..", wantErr: ErrFileName},
+		{name: "holidays/.env", wantErr: ErrFileName},
+	}
+
+	// writing the archive, the zip writer keeping the names as is
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, tt := range tests {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: tt.name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("content"))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(tests) {
+		t.Fatalf("archive holds %d entries, want %d", len(zr.File), len(tests))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folder, file, err := archivePath("uploads/images", zr.File[i].Name)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("archivePath(%q): got %q, %q, %v, want %v", tt.name, folder, file, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || folder != tt.folder || file != tt.file {
+				t.Errorf("archivePath(%q) = %q, %q, %v, want %q, %q", tt.name, folder, file, err, tt.folder, tt.file)
+			}
+		})
+	}
+}
+
+func TestIsArchiveMetadata(t *testing.T) {
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"photos/beach.jpg", false},
+		{"__MACOSX/photos/._beach.jpg", true},
+		{"photos/.DS_Store", true},
+		{"photos/.thumbnails/beach.jpg", true},
+	}
+
+	for _, tt := range tests {
+		if got := isArchiveMetadata(tt.name); got != tt.want {
+			t.Errorf("isArchiveMetadata(%q) = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
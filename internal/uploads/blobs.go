@@ -0,0 +1,179 @@
+package uploads
+
+import (
+	"Portfolio/internal/data"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// blobsDir is the storage prefix of the uploaded contents stored under their SHA-256 hash
+const blobsDir = ".blobs"
+
+// catalog maps the upload names to their blobs
+var catalog *data.UploadModel
+
+// blobKey returns the storage key of the blob with the given hash
+func blobKey(hash string) string {
+	return path.Join(blobsDir, hash[:2], hash)
+}
+
+// hashFile computes the SHA-256 hash of a file
+func hashFile(name string) (string, error) {
+
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// storeBlob moves the file tmp to the storage under its hash,
+// tmp being dropped if the same content is already stored
+func storeBlob(tmp, hash string) (bool, error) {
+
+	defer os.Remove(tmp)
+
+	exists, err := store.Exists(blobKey(hash))
+	if err != nil || exists {
+		return false, err
+	}
+
+	f, err := os.Open(tmp)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	err = store.Put(blobKey(hash), f, info.Size())
+	if err != nil {
+		return false, fmt.Errorf("error storing blob: %w", err)
+	}
+
+	return true, nil
+}
+
+// removeBlob deletes the content and the image data of a blob nothing points to anymore,
+// unless the same content was uploaded again in the meantime
+func removeBlob(hash string) error {
+
+	return catalog.DeleteBlob(hash, func() error {
+		removeImageData(hash)
+
+		err := store.Delete(blobKey(hash))
+		if err != nil {
+			return fmt.Errorf("error removing blob: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// adopt moves the files uploaded in the local directories before the blob store to the storage, keeping their names
+func adopt() error {
+
+	var errs []error
+
+	for _, dir := range dirList {
+
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, dirEntry := range entries {
+			if !dirEntry.Type().IsRegular() {
+				continue
+			}
+			file := filepath.Join(dir, dirEntry.Name())
+			if err := adoptFile(file); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// adoptFile moves one legacy file to the blob store
+func adoptFile(file string) error {
+
+	// reading the content type
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	head := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, head)
+	f.Close()
+	mime := sniff(head[:n])
+
+	// stripping the image metadata as a new upload would, the images too large to decode being kept without variants
+	img, err := cleanImage(file, mime)
+	if err != nil && !errors.Is(err, ErrFileTooLarge) {
+		return err
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	hash, err := hashFile(file)
+	if err != nil {
+		return fmt.Errorf("error hashing file: %w", err)
+	}
+
+	// recording the name first, so that a failed adoption is retried at the next start
+	upload := &data.Upload{
+		Path:         filepath.ToSlash(file),
+		Hash:         hash,
+		Size:         info.Size(),
+		MIME:         mime,
+		OriginalName: filepath.Base(file),
+	}
+	if img != nil {
+		upload.Width = img.Bounds().Dx()
+		upload.Height = img.Bounds().Dy()
+		upload.Alt = defaultAlt(file)
+	}
+	err = catalog.Insert(upload)
+	if err != nil && !errors.Is(err, data.ErrDuplicateUploadPath) {
+		return err
+	}
+
+	// the legacy variants are generated again from the blob
+	removeLegacyImageData(file)
+
+	isNew, err := storeBlob(file, hash)
+	if err != nil {
+		return err
+	}
+	if isNew && img != nil {
+		return writeVariants(hash, img, mime)
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+package uploads
+
+import (
+	"Portfolio/internal/data"
+	"Portfolio/internal/validator"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrFolderExists    = errors.New("a folder or a file already has this name")
+	ErrFolderNotEmpty  = errors.New("the folder is not empty")
+	ErrFolderProtected = errors.New("the built-in folders can't be renamed, moved or deleted")
+	ErrFolderName      = errors.New("invalid folder name")
+)
+
+// checkFolderName checks a single segment of a folder path,
+// the names starting with a dot being reserved for the storage (.blobs, .variants...)
+func checkFolderName(name string) bool {
+	return validator.CheckFileName(name) && !strings.HasPrefix(name, ".")
+}
+
+// cleanFolder returns dir as a slash-separated path of the uploads directory,
+// every segment of it having to be a valid folder name
+func cleanFolder(dir string) (string, error) {
+
+	dir = strings.Trim(filepath.ToSlash(dir), "/")
+	if dir != dirs.Root && !strings.HasPrefix(dir, dirs.Root+"/") {
+		return "", ErrForbiddenDirectory
+	}
+
+	for _, segment := range strings.Split(dir, "/") {
+		if !checkFolderName(segment) {
+			return "", ErrForbiddenDirectory
+		}
+	}
+
+	return dir, nil
+}
+
+// checkFolder cleans dir and checks that it is one of the folders of the catalog
+func checkFolder(dir string) (string, error) {
+
+	dir, err := cleanFolder(dir)
+	if err != nil {
+		return "", err
+	}
+
+	folders, err := catalog.GetFolders()
+	if err != nil {
+		return "", err
+	}
+	if !validator.PermittedValue(dir, folders...) {
+		return "", ErrForbiddenDirectory
+	}
+
+	return dir, nil
+}
+
+// IsBuiltinFolder checks if dir is one of the folders the uploads are saved to
+func IsBuiltinFolder(dir string) bool {
+	return validator.PermittedValue(dir, dirList...)
+}
+
+// Folders returns the path of every folder of the uploads directory
+func Folders() ([]string, error) {
+	return catalog.GetFolders()
+}
+
+// CreateFolder creates the folder name in parent and returns its path
+func CreateFolder(parent, name string) (string, error) {
+
+	parent, err := cleanFolder(parent)
+	if err != nil {
+		return "", err
+	}
+	if !checkFolderName(name) {
+		return "", ErrFolderName
+	}
+	dir := path.Join(parent, name)
+
+	// a file and a folder can't share a name
+	exists, err := catalog.Exists(dir)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", ErrFolderExists
+	}
+
+	err = catalog.InsertFolder(dir, parent)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return "", ErrForbiddenDirectory
+		case errors.Is(err, data.ErrDuplicateFolder):
+			return "", ErrFolderExists
+		default:
+			return "", fmt.Errorf("error creating folder: %w", err)
+		}
+	}
+
+	return dir, nil
+}
+
+// RenameFolder gives the name name to the folder dir and returns its new path,
+// unless posts or projects use its files
+func RenameFolder(dir, name string) (string, error) {
+
+	dir, err := cleanFolder(dir)
+	if err != nil {
+		return "", err
+	}
+	if !checkFolderName(name) {
+		return "", ErrFolderName
+	}
+
+	return moveFolder(dir, path.Join(path.Dir(dir), name))
+}
+
+// MoveFolder moves the folder dir, with its content, into the folder parent and returns its new path,
+// unless posts or projects use its files
+func MoveFolder(dir, parent string) (string, error) {
+
+	dir, err := cleanFolder(dir)
+	if err != nil {
+		return "", err
+	}
+	parent, err = cleanFolder(parent)
+	if err != nil {
+		return "", err
+	}
+
+	return moveFolder(dir, path.Join(parent, path.Base(dir)))
+}
+
+// moveFolder renames the folder from to to, the paths being already cleaned
+func moveFolder(from, to string) (string, error) {
+
+	if IsBuiltinFolder(from) {
+		return "", ErrFolderProtected
+	}
+	if from == to {
+		return to, nil
+	}
+
+	// a folder can't be moved into itself
+	if strings.HasPrefix(to, from+"/") {
+		return "", ErrForbiddenDirectory
+	}
+
+	// a file and a folder can't share a name
+	exists, err := catalog.Exists(to)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", ErrFolderExists
+	}
+
+	err = catalog.MoveFolder(from, to, path.Dir(to))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return "", ErrForbiddenDirectory
+		case errors.Is(err, data.ErrUploadReferenced):
+			usages, err := catalog.GetFolderUsages(from)
+			if err != nil {
+				return "", err
+			}
+			return "", referencedError(usages)
+		case errors.Is(err, data.ErrDuplicateFolder), errors.Is(err, data.ErrDuplicateUploadPath):
+			return "", ErrFolderExists
+		default:
+			return "", fmt.Errorf("error moving folder: %w", err)
+		}
+	}
+	forgetImages(from)
+
+	return to, nil
+}
+
+// DeleteFolder removes the folder dir, which must not contain any folder or file
+func DeleteFolder(dir string) error {
+
+	dir, err := cleanFolder(dir)
+	if err != nil {
+		return err
+	}
+	if IsBuiltinFolder(dir) {
+		return ErrFolderProtected
+	}
+
+	err = catalog.DeleteFolder(dir)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return ErrForbiddenDirectory
+		case errors.Is(err, data.ErrFolderNotEmpty):
+			return ErrFolderNotEmpty
+		default:
+			return fmt.Errorf("error deleting folder: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Move moves the file file into the folder dir and returns its new path, unless posts or projects use it
+func Move(file, dir string) (string, error) {
+
+	// cleaning the paths
+	file, err := cleanFile(file)
+	if err != nil {
+		return "", err
+	}
+	dir, err = cleanFolder(dir)
+	if err != nil {
+		return "", err
+	}
+
+	to := path.Join(dir, path.Base(file))
+	if to == file {
+		return to, nil
+	}
+
+	// checking the destination, a file and a folder not being able to share a name
+	folders, err := catalog.GetFolders()
+	if err != nil {
+		return "", err
+	}
+	if !validator.PermittedValue(dir, folders...) {
+		return "", ErrForbiddenDirectory
+	}
+	if validator.PermittedValue(to, folders...) {
+		return "", ErrFolderExists
+	}
+
+	err = catalog.Move(file, to, dir)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return "", ErrFileNotFound
+		case errors.Is(err, data.ErrUploadReferenced):
+			upload, err := catalog.Get(file)
+			if err != nil {
+				return "", err
+			}
+			return "", referencedError(upload.UsedIn)
+		case errors.Is(err, data.ErrDuplicateUploadPath):
+			return "", ErrFolderExists
+		default:
+			return "", fmt.Errorf("error moving file: %w", err)
+		}
+	}
+	forgetImage(file)
+
+	return to, nil
+}
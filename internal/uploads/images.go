@@ -0,0 +1,713 @@
+package uploads
+
+import (
+	"Portfolio/internal/validator"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// variantsDir is the storage prefix of the downscaled variants and the metadata of the uploaded images
+const variantsDir = ".variants"
+
+// maxImagePixels is the number of pixels an image can't exceed to be decoded, its variants and its oriented copy
+// each taking 4 bytes per pixel
+const maxImagePixels = 50_000_000
+
+// VariantWidths contains the widths of the downscaled variants generated for every image
+var VariantWidths = []int{320, 640, 1280}
+
+var (
+	ErrUnsupportedImage = errors.New("unsupported image format")
+
+	// svgMetadataRX matches the metadata elements of an SVG image (RDF, Dublin Core...)
+	svgMetadataRX = regexp.MustCompile(`(?is)<metadata[\s>].*?</metadata\s*>|<metadata\s*/>`)
+
+	// imageCache keeps the metadata of the images already read for a while
+	imageCache   = make(map[string]cachedImage)
+	imageCacheMu sync.RWMutex
+)
+
+const (
+	// imageCacheSize is the maximum number of images kept in the metadata cache
+	imageCacheSize = 1000
+
+	// imageCacheTTL is the time after which the cached metadata of an image is read again from the store,
+	// picking up the changes made by the other instances
+	imageCacheTTL = 5 * time.Minute
+)
+
+// cachedImage is an entry of the image metadata cache
+type cachedImage struct {
+	meta    *Image
+	expires time.Time
+}
+
+// Image contains the metadata recorded for an uploaded image
+type Image struct {
+	Width    int   `json:"width"`
+	Height   int   `json:"height"`
+	Variants []int `json:"variants"`
+}
+
+// SrcSet returns the srcset attribute value for the image available at src
+func (img *Image) SrcSet(src string) string {
+	var candidates []string
+	for _, width := range img.Variants {
+		candidates = append(candidates, fmt.Sprintf("%s?w=%d %dw", src, width, width))
+	}
+	candidates = append(candidates, fmt.Sprintf("%s %dw", src, img.Width))
+	return strings.Join(candidates, ", ")
+}
+
+// processable checks if the pipeline decodes the image format to generate its variants
+// (animated GIFs, WebP and vector images are only stripped of their metadata)
+func processable(mime string) bool {
+	return validator.PermittedValue(mime, "image/png", "image/jpeg")
+}
+
+// variantKey returns the storage key of the variant of the blob hash at the given width
+func variantKey(hash string, width int) string {
+	return path.Join(variantsDir, fmt.Sprint(hash, "_w", width))
+}
+
+// metadataKey returns the storage key of the metadata of the blob hash
+func metadataKey(hash string) string {
+	return path.Join(variantsDir, hash+".json")
+}
+
+// cleanImage strips the metadata of an uploaded image of type mime in place and returns the decoded image,
+// or nil if the format is not decoded by the pipeline (ErrFileTooLarge being returned for the images too large to decode)
+func cleanImage(file, mime string) (image.Image, error) {
+
+	// BMP images have no metadata, and WebM videos are kept as is
+	var strip func([]byte) ([]byte, error)
+	switch mime {
+	case "image/png":
+		strip = stripPNG
+	case "image/jpeg":
+		strip = stripJPEG
+	case "image/gif":
+		strip = stripGIF
+	case "image/webp":
+		strip = stripWebP
+	case "image/x-icon":
+		strip = stripICO
+	case "image/svg+xml":
+		strip = stripSVG
+	default:
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// removing the EXIF/GPS, XMP and text metadata
+	clean, err := strip(raw)
+	if err != nil {
+		return nil, fmt.Errorf("error stripping image metadata: %w", err)
+	}
+	err = os.WriteFile(file, clean, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error writing image: %w", err)
+	}
+	if !processable(mime) {
+		return nil, nil
+	}
+
+	// checking the dimensions before decoding, a small file being able to declare a huge image
+	config, _, err := image.DecodeConfig(bytes.NewReader(clean))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, fmt.Errorf("%w: images can't exceed %d pixels", ErrFileTooLarge, maxImagePixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(clean))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+
+	// applying the EXIF orientation that is about to be lost
+	if mime == "image/jpeg" {
+		if orientation := jpegOrientation(raw); orientation > 1 {
+			img = orient(img, orientation)
+			clean, err = encodeImage(img, mime)
+			if err != nil {
+				return nil, err
+			}
+			err = os.WriteFile(file, clean, 0o644)
+			if err != nil {
+				return nil, fmt.Errorf("error writing image: %w", err)
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// writeVariants generates the variants of the blob hash of type mime smaller than the original and records its dimensions
+func writeVariants(hash string, img image.Image, mime string) error {
+
+	meta := &Image{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	// converting the image once for all the variants
+	var src *image.RGBA
+	for _, width := range VariantWidths {
+		if width >= meta.Width {
+			break
+		}
+
+		if src == nil {
+			src = toRGBA(img)
+		}
+		height := max(1, meta.Height*width/meta.Width)
+		variant, err := encodeImage(resize(src, width, height), mime)
+		if err != nil {
+			return err
+		}
+
+		err = store.Put(variantKey(hash, width), bytes.NewReader(variant), int64(len(variant)))
+		if err != nil {
+			return fmt.Errorf("error writing variant: %w", err)
+		}
+
+		meta.Variants = append(meta.Variants, width)
+	}
+
+	js, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	err = store.Put(metadataKey(hash), bytes.NewReader(js), int64(len(js)))
+	if err != nil {
+		return fmt.Errorf("error writing image metadata: %w", err)
+	}
+
+	return nil
+}
+
+// readMetadata returns the recorded metadata of the blob hash, or nil if it is not a processed image
+func readMetadata(hash string) *Image {
+
+	f, err := store.Open(metadataKey(hash))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	js, err := io.ReadAll(f)
+	if err != nil {
+		return nil
+	}
+
+	meta := new(Image)
+	if json.Unmarshal(js, meta) != nil {
+		return nil
+	}
+
+	return meta
+}
+
+// removeImageData deletes the variants and metadata of the blob hash
+func removeImageData(hash string) {
+	for _, width := range VariantWidths {
+		store.Delete(variantKey(hash, width))
+	}
+	store.Delete(metadataKey(hash))
+}
+
+// removeLegacyImageData deletes the variants and metadata stored by file name in the local directory before the blob store
+func removeLegacyImageData(file string) {
+	dir := filepath.Join(dirs.Root, variantsDir)
+	ext := filepath.Ext(file)
+	for _, width := range VariantWidths {
+		os.Remove(filepath.Join(dir, fmt.Sprint(strings.TrimSuffix(file, ext), "_w", width, ext)))
+	}
+	os.Remove(filepath.Join(dir, file+".json"))
+}
+
+// forgetImage drops the cached metadata of the upload file
+func forgetImage(file string) {
+	imageCacheMu.Lock()
+	delete(imageCache, file)
+	imageCacheMu.Unlock()
+}
+
+// forgetImages drops the cached metadata of the upload files of the folder dir and its subfolders
+func forgetImages(dir string) {
+	imageCacheMu.Lock()
+	for file := range imageCache {
+		if strings.HasPrefix(file, dir+"/") {
+			delete(imageCache, file)
+		}
+	}
+	imageCacheMu.Unlock()
+}
+
+// ImageInfo returns the metadata of the uploaded image available at the URL src,
+// or nil if src is not a processed upload
+func ImageInfo(src string) *Image {
+
+	if !strings.HasPrefix(src, "/"+dirs.Root+"/") {
+		return nil
+	}
+	file := path.Clean(strings.TrimPrefix(src, "/"))
+	if _, err := cleanFolder(path.Dir(file)); err != nil || IsPrivate(file) {
+		return nil
+	}
+
+	imageCacheMu.RLock()
+	cached, ok := imageCache[file]
+	imageCacheMu.RUnlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.meta
+	}
+
+	upload, err := catalog.Get(file)
+	if err != nil {
+		return nil
+	}
+	meta := readMetadata(upload.Hash)
+	if meta == nil {
+		return nil
+	}
+
+	cacheImage(file, meta)
+
+	return meta
+}
+
+// cacheImage keeps the metadata of the upload file in the cache, evicting the expired entries
+// (or arbitrary ones if none expired) when it is full
+func cacheImage(file string, meta *Image) {
+
+	imageCacheMu.Lock()
+	defer imageCacheMu.Unlock()
+
+	if len(imageCache) >= imageCacheSize {
+		now := time.Now()
+		for f, cached := range imageCache {
+			if now.After(cached.expires) {
+				delete(imageCache, f)
+			}
+		}
+		for f := range imageCache {
+			if len(imageCache) < imageCacheSize {
+				break
+			}
+			delete(imageCache, f)
+		}
+	}
+
+	imageCache[file] = cachedImage{meta: meta, expires: time.Now().Add(imageCacheTTL)}
+}
+
+// encodeImage encodes img in the format of type mime
+func encodeImage(img image.Image, mime string) ([]byte, error) {
+
+	buf := new(bytes.Buffer)
+
+	var err error
+	if mime == "image/png" {
+		err = png.Encode(buf, img)
+	} else {
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error encoding image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// toRGBA converts img to premultiplied RGBA pixels, to resize it without halos around transparent areas
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// resize downscales src to width x height by averaging the source pixels covered by each destination pixel
+func resize(src *image.RGBA, width, height int) image.Image {
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(src.Pix[i])
+					g += uint64(src.Pix[i+1])
+					b += uint64(src.Pix[i+2])
+					a += uint64(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+
+	return dst
+}
+
+// orient applies an EXIF orientation (2 to 8) to img
+func orient(img image.Image, orientation int) image.Image {
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// orientations 5 to 8 swap the width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	return dst
+}
+
+// jpegSegments calls fn for every segment of a JPEG file before the image data, with the marker and the segment payload
+func jpegSegments(raw []byte, fn func(marker byte, payload []byte)) (int, error) {
+
+	if len(raw) < 4 || raw[0] != 0xFF || raw[1] != 0xD8 {
+		return 0, ErrUnsupportedImage
+	}
+
+	i := 2
+	for i+4 <= len(raw) {
+		if raw[i] != 0xFF {
+			return 0, ErrUnsupportedImage
+		}
+		marker := raw[i+1]
+
+		// the start of scan is followed by the entropy-coded data
+		if marker == 0xDA {
+			return i, nil
+		}
+
+		length := int(binary.BigEndian.Uint16(raw[i+2 : i+4]))
+		if length < 2 || i+2+length > len(raw) {
+			return 0, ErrUnsupportedImage
+		}
+		fn(marker, raw[i:i+2+length])
+		i += 2 + length
+	}
+
+	return 0, ErrUnsupportedImage
+}
+
+// stripJPEG removes the EXIF/XMP (APP1), IPTC (APP13) and comment segments of a JPEG file without re-encoding it
+func stripJPEG(raw []byte) ([]byte, error) {
+
+	out := bytes.NewBuffer([]byte{0xFF, 0xD8})
+
+	scan, err := jpegSegments(raw, func(marker byte, segment []byte) {
+		if marker != 0xE1 && marker != 0xED && marker != 0xFE {
+			out.Write(segment)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out.Write(raw[scan:])
+	return out.Bytes(), nil
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG file (1 if there is none)
+func jpegOrientation(raw []byte) int {
+
+	orientation := 1
+
+	jpegSegments(raw, func(marker byte, segment []byte) {
+		if marker != 0xE1 || len(segment) < 18 || string(segment[4:10]) != "Exif\x00\x00" {
+			return
+		}
+		tiff := segment[10:]
+
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return
+		}
+
+		// looking for the orientation tag (0x0112) in IFD0
+		ifd := int(order.Uint32(tiff[4:8]))
+		if ifd+2 > len(tiff) {
+			return
+		}
+		count := int(order.Uint16(tiff[ifd : ifd+2]))
+		for n := 0; n < count; n++ {
+			entry := ifd + 2 + n*12
+			if entry+12 > len(tiff) {
+				return
+			}
+			if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+				if value := int(order.Uint16(tiff[entry+8 : entry+10])); value >= 1 && value <= 8 {
+					orientation = value
+				}
+				return
+			}
+		}
+	})
+
+	return orientation
+}
+
+// stripPNG removes the EXIF, text and time chunks of a PNG file without re-encoding it
+func stripPNG(raw []byte) ([]byte, error) {
+
+	const signature = "\x89PNG\r\n\x1a\n"
+	if len(raw) < len(signature) || string(raw[:len(signature)]) != signature {
+		return nil, ErrUnsupportedImage
+	}
+
+	out := bytes.NewBufferString(signature)
+
+	for i := len(signature); i < len(raw); {
+		if i+8 > len(raw) {
+			return nil, ErrUnsupportedImage
+		}
+		length := int(binary.BigEndian.Uint32(raw[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end > len(raw) {
+			return nil, ErrUnsupportedImage
+		}
+
+		switch string(raw[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(raw[i:end])
+		}
+		i = end
+	}
+
+	return out.Bytes(), nil
+}
+
+// stripGIF removes the comment and application extensions of a GIF file (but the animation loop ones)
+// without re-encoding it
+func stripGIF(raw []byte) ([]byte, error) {
+
+	if len(raw) < 13 || (string(raw[:6]) != "GIF87a" && string(raw[:6]) != "GIF89a") {
+		return nil, ErrUnsupportedImage
+	}
+
+	// keeping the header, the screen descriptor and the global color table
+	i := 13
+	if flags := raw[10]; flags&0x80 != 0 {
+		i += 3 << (flags&0x07 + 1)
+	}
+	if i > len(raw) {
+		return nil, ErrUnsupportedImage
+	}
+	out := bytes.NewBuffer(raw[:i:i])
+
+	for i < len(raw) {
+		start := i
+		switch raw[i] {
+		case 0x21:
+			if i+2 > len(raw) {
+				return nil, ErrUnsupportedImage
+			}
+			label := raw[i+1]
+			end, err := gifSubBlocks(raw, i+2)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+			keep := label != 0xFE
+			if label == 0xFF {
+				id := raw[start+2 : end]
+				keep = bytes.HasPrefix(id, []byte("\x0bNETSCAPE2.0")) || bytes.HasPrefix(id, []byte("\x0bANIMEXTS1.0"))
+			}
+			if keep {
+				out.Write(raw[start:end])
+			}
+
+		case 0x2C:
+			// the image descriptor, its local color table and the minimum code size precede the image data
+			i += 10
+			if i > len(raw) {
+				return nil, ErrUnsupportedImage
+			}
+			if flags := raw[i-1]; flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			end, err := gifSubBlocks(raw, i+1)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			out.Write(raw[start:end])
+
+		case 0x3B:
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+
+		default:
+			return nil, ErrUnsupportedImage
+		}
+	}
+
+	return nil, ErrUnsupportedImage
+}
+
+// gifSubBlocks returns the end of the GIF data sub-blocks starting at i, after their terminator
+func gifSubBlocks(raw []byte, i int) (int, error) {
+	for {
+		if i >= len(raw) {
+			return 0, ErrUnsupportedImage
+		}
+		size := int(raw[i])
+		i += 1 + size
+		if size == 0 {
+			return i, nil
+		}
+	}
+}
+
+// stripWebP removes the EXIF and XMP chunks of a WebP file without re-encoding it
+func stripWebP(raw []byte) ([]byte, error) {
+
+	if len(raw) < 12 || string(raw[:4]) != "RIFF" || string(raw[8:12]) != "WEBP" {
+		return nil, ErrUnsupportedImage
+	}
+
+	out := bytes.NewBuffer(append([]byte(nil), raw[:12]...))
+
+	for i := 12; i < len(raw); {
+		if i+8 > len(raw) {
+			return nil, ErrUnsupportedImage
+		}
+		length := int(binary.LittleEndian.Uint32(raw[i+4 : i+8]))
+		end := i + 8 + length + length%2
+		if length < 0 || end > len(raw) {
+			return nil, ErrUnsupportedImage
+		}
+
+		switch string(raw[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			// the extended header announces the metadata chunks
+			chunk := append([]byte(nil), raw[i:end]...)
+			if length > 0 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(raw[i:end])
+		}
+		i = end
+	}
+
+	clean := out.Bytes()
+	binary.LittleEndian.PutUint32(clean[4:8], uint32(len(clean)-8))
+	return clean, nil
+}
+
+// stripICO strips the PNG images of an icon file, the bitmap ones having no metadata
+func stripICO(raw []byte) ([]byte, error) {
+
+	if len(raw) < 6 || binary.LittleEndian.Uint16(raw[:2]) != 0 || binary.LittleEndian.Uint16(raw[2:4]) != 1 {
+		return nil, ErrUnsupportedImage
+	}
+	count := int(binary.LittleEndian.Uint16(raw[4:6]))
+	if 6+count*16 > len(raw) {
+		return nil, ErrUnsupportedImage
+	}
+
+	// writing the images again after the directory, with their new sizes and offsets
+	out := bytes.NewBuffer(append([]byte(nil), raw[:6+count*16]...))
+	for n := 0; n < count; n++ {
+		entry := 6 + n*16
+		size := int(binary.LittleEndian.Uint32(raw[entry+8 : entry+12]))
+		offset := int(binary.LittleEndian.Uint32(raw[entry+12 : entry+16]))
+		if size < 0 || offset < 0 || offset+size > len(raw) {
+			return nil, ErrUnsupportedImage
+		}
+
+		img := raw[offset : offset+size]
+		if bytes.HasPrefix(img, []byte("\x89PNG")) {
+			var err error
+			img, err = stripPNG(img)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		clean := out.Bytes()
+		binary.LittleEndian.PutUint32(clean[entry+8:entry+12], uint32(len(img)))
+		binary.LittleEndian.PutUint32(clean[entry+12:entry+16], uint32(out.Len()))
+		out.Write(img)
+	}
+
+	return out.Bytes(), nil
+}
+
+// stripSVG removes the metadata elements of an SVG image
+func stripSVG(raw []byte) ([]byte, error) {
+	return svgMetadataRX.ReplaceAll(raw, nil), nil
+}
@@ -0,0 +1,196 @@
+package uploads
+
+import (
+	"Portfolio/internal/data"
+	"Portfolio/internal/validator"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxLinkLifetime is the longest time a signed link to a private file stays valid
+const MaxLinkLifetime = 30 * 24 * time.Hour
+
+var (
+	ErrNotPrivate  = errors.New("only the files of the private folder are shared through signed links")
+	ErrLinkExpired = errors.New("invalid or expired link")
+
+	// signingKey is the secret the links to the private files are signed with
+	signingKey []byte
+)
+
+// SetSigningKey sets the secret the links to the private files are signed with
+func SetSigningKey(key []byte) {
+	signingKey = key
+}
+
+// IsPrivate checks if file is in the private folder, which is not served publicly
+func IsPrivate(file string) bool {
+	file = strings.TrimPrefix(path.Clean("/"+file), "/")
+	return file == dirs.Private || strings.HasPrefix(file, dirs.Private+"/")
+}
+
+// sign returns the signature of the private file valid until expires
+func sign(file string, expires int64) string {
+	mac := hmac.New(sha256.New, signingKey)
+	fmt.Fprintf(mac, "%s\n%d", file, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignURL returns a link to the private file valid for ttl (capped to MaxLinkLifetime), with its expiration time
+func SignURL(file string, ttl time.Duration) (string, time.Time, error) {
+
+	file, err := cleanFile(file)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !IsPrivate(file) {
+		return "", time.Time{}, ErrNotPrivate
+	}
+
+	// the trashed files can't be shared
+	_, err = catalog.Get(file)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return "", time.Time{}, ErrFileNotFound
+		}
+		return "", time.Time{}, err
+	}
+
+	if ttl <= 0 || ttl > MaxLinkLifetime {
+		ttl = MaxLinkLifetime
+	}
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+
+	// escaping every segment of the path, the query holding the expiration and the signature
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimPrefix(file, dirs.Private+"/"), "/") {
+		segments = append(segments, url.PathEscape(segment))
+	}
+	query := url.Values{
+		"expires": {strconv.FormatInt(expires.Unix(), 10)},
+		"sig":     {sign(file, expires.Unix())},
+	}
+
+	return "/private/" + strings.Join(segments, "/") + "?" + query.Encode(), expires, nil
+}
+
+// checkSignature checks the expiration and the signature of a link to the private file
+func checkSignature(file string, query url.Values) error {
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return ErrLinkExpired
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(query.Get("sig"))
+	if err != nil {
+		return ErrLinkExpired
+	}
+	expected, _ := base64.RawURLEncoding.DecodeString(sign(file, expires))
+	if !hmac.Equal(sig, expected) {
+		return ErrLinkExpired
+	}
+
+	return nil
+}
+
+// ServePrivate returns the handler serving the files of the private folder to the holders of a valid signed link,
+// the path of the request being relative to the private folder
+func ServePrivate() http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		file := path.Join(dirs.Private, path.Clean("/"+r.URL.Path))
+
+		// the invalid links are not told apart from the missing files
+		if len(signingKey) == 0 || checkSignature(file, r.URL.Query()) != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		// the links must not be kept by shared caches, nor leak through the referer
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		serveFile(w, r, file, "private, no-store")
+	})
+}
+
+// serveFile sends the content of the upload file from its blob (or of the variant of an image set by the w query parameter),
+// with its ETag and its disposition, the ranges and the conditional requests being handled by http.ServeContent
+func serveFile(w http.ResponseWriter, r *http.Request, file, cacheControl string) {
+
+	// preventing the browsers from running any uploaded content (the PDF viewers don't work in a sandbox)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if !validator.PermittedValue(strings.ToLower(path.Ext(file)), pdfExt...) {
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self'; media-src 'self'; style-src 'unsafe-inline'; sandbox")
+	}
+
+	// resolving the name to its blob
+	upload, err := catalog.Get(file)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			http.NotFound(w, r)
+		default:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	key := blobKey(upload.Hash)
+	etag := upload.Hash
+
+	// looking for the variant of an image
+	if width, err := strconv.Atoi(r.URL.Query().Get("w")); err == nil {
+		if meta := ImageInfo("/" + file); meta != nil && validator.PermittedValue(width, meta.Variants...) {
+			key = variantKey(upload.Hash, width)
+			etag = fmt.Sprint(upload.Hash, "_w", width)
+		}
+	}
+
+	content, err := store.Open(key)
+	if err != nil {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			http.NotFound(w, r)
+		default:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer content.Close()
+
+	// the type sniffed at upload time is trusted over the extension, the variants keeping the format of their image
+	if upload.MIME != "" {
+		w.Header().Set("Content-Type", upload.MIME)
+	}
+	w.Header().Set("Content-Disposition", contentDisposition(path.Base(file), upload.MIME))
+
+	// the content never changes for a given hash
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.Header().Set("Cache-Control", cacheControl)
+	http.ServeContent(w, r, path.Base(file), upload.CreatedAt, content)
+}
+
+// contentDisposition returns the disposition of a file named filename, the types the browsers display being inline
+func contentDisposition(filename, mimeType string) string {
+
+	disposition := "attachment"
+	switch {
+	case strings.HasPrefix(mimeType, "image/"), strings.HasPrefix(mimeType, "audio/"), strings.HasPrefix(mimeType, "video/"),
+		strings.HasPrefix(mimeType, "text/plain"), mimeType == "application/pdf":
+		disposition = "inline"
+	}
+
+	return mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+}
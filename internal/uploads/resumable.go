@@ -0,0 +1,329 @@
+package uploads
+
+import (
+	"Portfolio/internal/data"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrResumableNotFound = errors.New("upload not found or expired")
+	ErrResumableBusy     = errors.New("upload already being written")
+	ErrOffsetMismatch    = errors.New("upload offset mismatch")
+
+	// resumableIDRX matches the identifiers of the resumable uploads
+	resumableIDRX = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+// Resumable is an upload sent in chunks (with the tus protocol), its bytes being staged until all of them are received
+type Resumable struct {
+	ID        string    `json:"id"`
+	Length    int64     `json:"length"`
+	Offset    int64     `json:"-"`
+	Filename  string    `json:"filename"`
+	UserID    int       `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// set once the last byte is received and the file is saved to the uploads
+	Upload     *data.Upload `json:"-"`
+	Duplicates []string     `json:"-"`
+}
+
+// stagingSaveTime is the time left to save a complete file to the uploads after its last chunk, before its lock is stale
+const stagingSaveTime = 5 * time.Minute
+
+// staging contains the settings of the staging area
+var staging = struct {
+	dir         string
+	expiry      time.Duration
+	lockTimeout time.Duration
+}{
+	dir:         filepath.Join(os.TempDir(), "portfolio-uploads"),
+	expiry:      24 * time.Hour,
+	lockTimeout: time.Minute + stagingSaveTime,
+}
+
+// InitStaging sets the directory keeping the partial uploads, the time they are kept after the last received chunk
+// and the maximum time to receive a chunk.
+//
+// The staged uploads and their locks are only kept in dir: when several instances serve the site,
+// dir must be shared by all of them (on a network file system) for the uploads to be resumed on any instance.
+func InitStaging(dir string, expiry, chunkTimeout time.Duration) error {
+
+	if expiry > 0 {
+		staging.expiry = expiry
+	}
+	if chunkTimeout > 0 {
+		staging.lockTimeout = chunkTimeout + stagingSaveTime
+	}
+	staging.dir = dir
+
+	return initDir(dir)
+}
+
+// stagingFiles returns the paths of the content and the information of the resumable upload id
+func stagingFiles(id string) (string, string) {
+	return filepath.Join(staging.dir, id+".part"), filepath.Join(staging.dir, id+".json")
+}
+
+// stagingLock returns the path of the lock of the resumable upload id
+func stagingLock(id string) string {
+	return filepath.Join(staging.dir, id+".lock")
+}
+
+// lockResumable takes the lock of the resumable upload id in the staging directory, reporting false if another
+// request holds it. The lock left by an instance stopped while writing is taken over once stale.
+func lockResumable(id string) (bool, error) {
+
+	lock := stagingLock(id)
+
+	f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if errors.Is(err, fs.ErrExist) && !isLocked(id) {
+		os.Remove(lock)
+		f, err = os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, f.Close()
+}
+
+// isLocked checks if a request is writing the resumable upload id
+func isLocked(id string) bool {
+	info, err := os.Stat(stagingLock(id))
+	return err == nil && time.Since(info.ModTime()) < staging.lockTimeout
+}
+
+// CreateResumable stages a new upload of length bytes, to be saved under filename on behalf of the user userID
+func CreateResumable(length int64, filename string, userID int) (*Resumable, error) {
+
+	if length > policy.MaxFileSize {
+		return nil, fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, policy.MaxFileSize)
+	}
+
+	// generating the identifier
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(filename) == "" {
+		filename = "file"
+	}
+
+	r := &Resumable{
+		ID:        hex.EncodeToString(b),
+		Length:    length,
+		Filename:  filename,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(staging.expiry),
+	}
+
+	// writing the information first, the content without information being cleaned up
+	err = r.save()
+	if err != nil {
+		return nil, fmt.Errorf("error creating staged upload: %w", err)
+	}
+
+	part, _ := stagingFiles(r.ID)
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		r.remove()
+		return nil, fmt.Errorf("error creating staged upload: %w", err)
+	}
+	f.Close()
+
+	return r, nil
+}
+
+// GetResumable returns the staged upload id of the user userID, with the number of bytes already received
+func GetResumable(id string, userID int) (*Resumable, error) {
+
+	// the identifier is part of the file names
+	if !resumableIDRX.MatchString(id) {
+		return nil, ErrResumableNotFound
+	}
+	part, info := stagingFiles(id)
+
+	content, err := os.ReadFile(info)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrResumableNotFound
+		}
+		return nil, err
+	}
+
+	var r Resumable
+	err = json.Unmarshal(content, &r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading staged upload: %w", err)
+	}
+	if r.UserID != userID || time.Now().After(r.ExpiresAt) {
+		return nil, ErrResumableNotFound
+	}
+
+	stat, err := os.Stat(part)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrResumableNotFound
+		}
+		return nil, err
+	}
+	r.Offset = stat.Size()
+
+	return &r, nil
+}
+
+// save writes the information of the staged upload
+func (r *Resumable) save() error {
+
+	content, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	_, info := stagingFiles(r.ID)
+	return os.WriteFile(info, content, 0o600)
+}
+
+// remove deletes the staged upload
+func (r *Resumable) remove() {
+	part, info := stagingFiles(r.ID)
+	os.Remove(info)
+	os.Remove(part)
+}
+
+// Write appends the bytes of src sent from offset, keeping the bytes received before an interrupted transfer.
+//
+// Once the last byte is received, the file is saved to the uploads directory matching its extension
+// and the staged upload is removed.
+func (r *Resumable) Write(offset int64, src io.Reader) error {
+
+	// a single chunk is written at a time, whichever instance receives it
+	locked, err := lockResumable(r.ID)
+	if err != nil {
+		return fmt.Errorf("error locking staged upload: %w", err)
+	}
+	if !locked {
+		return ErrResumableBusy
+	}
+	defer os.Remove(stagingLock(r.ID))
+
+	// the offset may have changed since r was read
+	current, err := GetResumable(r.ID, r.UserID)
+	if err != nil {
+		return err
+	}
+	r.Offset = current.Offset
+	if offset != r.Offset {
+		return ErrOffsetMismatch
+	}
+
+	// appending the chunk, without going past the announced length
+	part, _ := stagingFiles(r.ID)
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(src, r.Length-r.Offset))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	r.Offset += n
+
+	// postponing the expiration after each chunk
+	r.ExpiresAt = time.Now().Add(staging.expiry)
+	if saveErr := r.save(); err == nil {
+		err = saveErr
+	}
+	if err != nil || r.Offset < r.Length {
+		return err
+	}
+
+	// handing the complete file to the uploads
+	defer r.remove()
+
+	f, err = os.Open(part)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r.Upload, r.Duplicates, err = Save(r.Filename, f, r.UserID)
+	return err
+}
+
+// CleanStaging removes the expired staged uploads and returns how many were removed
+func CleanStaging() (int, error) {
+
+	entries, err := os.ReadDir(staging.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var (
+		removed int
+		errs    []error
+	)
+
+	for _, entry := range entries {
+
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !resumableIDRX.MatchString(id) {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(staging.dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		var r Resumable
+		err = json.Unmarshal(content, &r)
+		if err == nil && time.Now().Before(r.ExpiresAt) {
+			continue
+		}
+
+		// the unreadable information is removed too
+		if !isLocked(id) {
+			r.ID = id
+			r.remove()
+			removed++
+		}
+	}
+
+	// removing the content and the locks left without information
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".part")
+		if !ok {
+			id, ok = strings.CutSuffix(entry.Name(), ".lock")
+		}
+		if !ok || !resumableIDRX.MatchString(id) {
+			continue
+		}
+		if _, info := stagingFiles(id); !fileExists(info) && !isLocked(id) {
+			os.Remove(filepath.Join(staging.dir, entry.Name()))
+		}
+	}
+
+	return removed, errors.Join(errs...)
+}
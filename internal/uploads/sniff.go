@@ -0,0 +1,214 @@
+package uploads
+
+import (
+	"Portfolio/internal/validator"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// sniffLen is the number of bytes read to detect the content type of a file
+const sniffLen = 512
+
+var (
+	ErrFileTooLarge  = errors.New("file too large")
+	ErrForbiddenType = errors.New("forbidden file type")
+
+	// activeSVGRX matches the SVG content able to run scripts or load other documents
+	activeSVGRX = regexp.MustCompile(`(?i)<script|<foreignobject|\son[a-z]+\s*=|javascript:|<iframe|<embed|<object`)
+
+	// executableMagic contains the signatures of the executable formats
+	executableMagic = [][]byte{
+		[]byte("MZ"),               // Windows PE
+		[]byte("\x7fELF"),          // ELF
+		[]byte("\xfe\xed\xfa\xce"), // Mach-O 32 bits
+		[]byte("\xfe\xed\xfa\xcf"), // Mach-O 64 bits
+		[]byte("\xce\xfa\xed\xfe"), // Mach-O 32 bits (reversed)
+		[]byte("\xcf\xfa\xed\xfe"), // Mach-O 64 bits (reversed)
+		[]byte("\xca\xfe\xba\xbe"), // Mach-O universal / Java class
+		[]byte("dex\n"),            // Android Dalvik
+		[]byte("#!"),               // scripts
+	}
+
+	// extraMagic contains the signatures of the formats http.DetectContentType doesn't know
+	extraMagic = []struct {
+		sig  []byte
+		mime string
+	}{
+		{[]byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+		{[]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), "application/x-ole-storage"},
+		{[]byte("%!PS"), "application/postscript"},
+		{[]byte("fLaC"), "audio/flac"},
+		{[]byte("\x1a\x45\xdf\xa3"), "video/webm"},
+	}
+)
+
+// Policy sets the size limit and the MIME types allowed in each upload directory,
+// with the number of files and the extracted size a ZIP archive can't exceed
+type Policy struct {
+	MaxFileSize     int64
+	Allowed         map[string][]string
+	MaxArchiveFiles int
+	MaxArchiveSize  int64
+}
+
+// DefaultPolicy returns the policy used unless SetPolicy is called
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxFileSize:     10 << 20,
+		MaxArchiveFiles: 500,
+		MaxArchiveSize:  200 << 20,
+		Allowed: map[string][]string{
+			dirs.Image: {"image/png", "image/jpeg", "image/gif", "image/webp", "image/bmp", "image/x-icon", "image/svg+xml", "video/webm"},
+			dirs.PDF:   {"application/pdf", "application/postscript"},
+			dirs.Root: {
+				"text/plain", "application/pdf", "application/zip", "application/x-gzip", "application/x-rar-compressed",
+				"application/x-7z-compressed", "application/x-ole-storage", "audio/*", "video/*", "application/ogg",
+			},
+		},
+	}
+}
+
+// policy is the upload policy in use
+var policy = DefaultPolicy()
+
+// SetPolicy replaces the upload policy, the directories missing in p keeping their default allowlist
+func SetPolicy(p Policy) {
+	def := DefaultPolicy()
+	for dir, types := range p.Allowed {
+		def.Allowed[dir] = types
+	}
+	if p.MaxFileSize > 0 {
+		def.MaxFileSize = p.MaxFileSize
+	}
+	if p.MaxArchiveFiles > 0 {
+		def.MaxArchiveFiles = p.MaxArchiveFiles
+	}
+	if p.MaxArchiveSize > 0 {
+		def.MaxArchiveSize = p.MaxArchiveSize
+	}
+	policy = def
+}
+
+// ParseAllowlist reads an allowlist in the form "uploads/img=image/png,image/jpeg;uploads/docs=application/pdf"
+func ParseAllowlist(value string) (map[string][]string, error) {
+
+	allowed := make(map[string][]string)
+
+	for _, rule := range strings.Split(value, ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		dir, types, ok := strings.Cut(rule, "=")
+		dir = strings.TrimSpace(dir)
+		if !ok || !validator.PermittedValue(dir, dirList...) {
+			return nil, fmt.Errorf("invalid allowlist rule %q", rule)
+		}
+		for _, mime := range strings.Split(types, ",") {
+			if mime = strings.TrimSpace(mime); mime != "" {
+				allowed[dir] = append(allowed[dir], mime)
+			}
+		}
+	}
+
+	return allowed, nil
+}
+
+// sniff detects the MIME type of a file from its first bytes
+func sniff(head []byte) string {
+
+	for _, magic := range extraMagic {
+		if bytes.HasPrefix(head, magic.sig) {
+			return magic.mime
+		}
+	}
+
+	mime, _, _ := strings.Cut(http.DetectContentType(head), ";")
+
+	// SVG images are detected as XML or plain text
+	if mime == "text/xml" || mime == "text/plain" {
+		if bytes.Contains(bytes.ToLower(head), []byte("<svg")) {
+			return "image/svg+xml"
+		}
+	}
+
+	return mime
+}
+
+// isExecutable checks if the content of a file starts with an executable signature
+func isExecutable(head []byte) bool {
+	for _, magic := range executableMagic {
+		if bytes.HasPrefix(head, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed checks if the MIME type is in the allowlist of dir
+func allowed(dir, mime string) bool {
+	for _, pattern := range policy.Allowed[dir] {
+		if pattern == mime {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mime, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// verify checks the extension and the real content type of a file against the policy of its destination directory,
+// and returns the detected MIME type with a reader replaying the whole content
+func verify(dir, ext string, src io.Reader) (string, io.Reader, error) {
+
+	ext = strings.ToLower(ext)
+
+	// rejecting the applications and the HTML documents from their extension
+	if validator.PermittedValue(ext, appExt...) || validator.PermittedValue(ext, ".html", ".htm", ".xhtml", ".shtml") {
+		return "", nil, fmt.Errorf("%w: %s files are not allowed", ErrForbiddenType, ext)
+	}
+
+	// reading the first bytes to get the real type
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", nil, fmt.Errorf("error reading file: %w", err)
+	}
+	head = head[:n]
+
+	if n == 0 {
+		return "", nil, fmt.Errorf("%w: empty file", ErrForbiddenType)
+	}
+	if isExecutable(head) {
+		return "", nil, fmt.Errorf("%w: executable content", ErrForbiddenType)
+	}
+
+	mime := sniff(head)
+	if mime == "text/html" {
+		return "", nil, fmt.Errorf("%w: HTML content", ErrForbiddenType)
+	}
+	if !allowed(dir, mime) {
+		return "", nil, fmt.Errorf("%w: %s content is not allowed in %s", ErrForbiddenType, mime, dir)
+	}
+
+	src = io.MultiReader(bytes.NewReader(head), src)
+
+	// SVG images are read whole to look for scripts
+	if mime == "image/svg+xml" {
+		svg, err := io.ReadAll(io.LimitReader(src, policy.MaxFileSize+1))
+		if err != nil {
+			return "", nil, fmt.Errorf("error reading file: %w", err)
+		}
+		if activeSVGRX.Match(svg) {
+			return "", nil, fmt.Errorf("%w: SVG with active content", ErrForbiddenType)
+		}
+		src = bytes.NewReader(svg)
+	}
+
+	return mime, src, nil
+}
@@ -0,0 +1,236 @@
+package uploads
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage is the backend keeping the blobs, variants and metadata of the uploads under slash-separated keys.
+//
+// Missing keys are reported with fs.ErrNotExist.
+type Storage interface {
+	Put(key string, src io.Reader, size int64) error
+	Open(key string) (io.ReadSeekCloser, error)
+	Exists(key string) (bool, error)
+	Delete(key string) error
+	List() ([]string, error)
+}
+
+// store is the storage backend in use
+var store Storage
+
+// StorageDrivers contains the names of the available storage drivers
+var StorageDrivers = []string{"local", "s3"}
+
+// StorageConfig contains the settings of a storage backend
+type StorageConfig struct {
+	Driver string
+	Root   string
+	S3     S3Config
+}
+
+// Flags registers the storage settings in flags, every flag name starting with prefix
+func (cfg *StorageConfig) Flags(flags *flag.FlagSet, prefix string) {
+	flags.StringVar(&cfg.Driver, prefix+"storage", "local", fmt.Sprintf("Uploads storage driver (%s)", strings.Join(StorageDrivers, "|")))
+	flags.StringVar(&cfg.Root, prefix+"storage-root", dirs.Root, "Local storage directory")
+	flags.StringVar(&cfg.S3.Endpoint, prefix+"s3-endpoint", "", "S3 endpoint URL (e.g. http://localhost:9000 for MinIO)")
+	flags.StringVar(&cfg.S3.Region, prefix+"s3-region", "us-east-1", "S3 region")
+	flags.StringVar(&cfg.S3.Bucket, prefix+"s3-bucket", "", "S3 bucket")
+	flags.StringVar(&cfg.S3.AccessKey, prefix+"s3-access-key", "", "S3 access key")
+	flags.StringVar(&cfg.S3.SecretKey, prefix+"s3-secret-key", "", "S3 secret key")
+	flags.BoolVar(&cfg.S3.PathStyle, prefix+"s3-path-style", true, "Use path-style S3 URLs (required by MinIO)")
+}
+
+// Open creates the storage backend described by the settings
+func (cfg StorageConfig) Open() (Storage, error) {
+	switch cfg.Driver {
+	case "local":
+		return NewLocal(cfg.Root), nil
+	case "s3":
+		return NewS3(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// Local stores the uploads in a directory of the filesystem
+type Local struct {
+	root string
+}
+
+// NewLocal returns the local storage rooted at root
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// file returns the path of the file stored under key
+func (l *Local) file(key string) (string, error) {
+	key = path.Clean("/" + key)
+	if key == "/" {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes src under key, replacing the previous content atomically
+func (l *Local) Put(key string, src io.Reader, size int64) error {
+
+	name, err := l.file(key)
+	if err != nil {
+		return err
+	}
+
+	err = initDir(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".put-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), 0o644)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// Open returns the content stored under key
+func (l *Local) Open(key string) (io.ReadSeekCloser, error) {
+	name, err := l.file(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(name)
+}
+
+// Exists checks if key is stored
+func (l *Local) Exists(key string) (bool, error) {
+	name, err := l.file(key)
+	if err != nil {
+		return false, err
+	}
+	return fileExists(name), nil
+}
+
+// Delete removes key, a missing key not being an error
+func (l *Local) Delete(key string) error {
+	name, err := l.file(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// List returns all the keys stored in the blob and variant directories
+func (l *Local) List() ([]string, error) {
+
+	var keys []string
+
+	for _, prefix := range []string{blobsDir, variantsDir} {
+		err := filepath.WalkDir(filepath.Join(l.root, prefix), func(name string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".put-") {
+				return nil
+			}
+			rel, err := filepath.Rel(l.root, name)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// Migrate copies every key of src missing in dst, returning the number of copied and skipped keys
+func Migrate(src, dst Storage) (int, int, error) {
+
+	keys, err := src.List()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error listing the source files: %w", err)
+	}
+
+	var (
+		copied, skipped int
+		errs            []error
+	)
+
+	for _, key := range keys {
+
+		exists, err := dst.Exists(key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		if exists {
+			skipped++
+			continue
+		}
+
+		err = copyKey(src, dst, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		copied++
+	}
+
+	return copied, skipped, errors.Join(errs...)
+}
+
+// copyKey copies the content of key from src to dst
+func copyKey(src, dst Storage, key string) error {
+
+	f, err := src.Open(key)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// getting the size from the end of the content
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	return dst.Put(key, f, size)
+}
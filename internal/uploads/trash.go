@@ -0,0 +1,92 @@
+package uploads
+
+import (
+	"Portfolio/internal/data"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+)
+
+// Trash returns the files of the trash, the latest deleted first
+func Trash() ([]File, error) {
+
+	uploads, err := catalog.GetTrash(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, upload := range uploads {
+		files = append(files, newFile(entry{name: path.Base(upload.Path), size: upload.Size, modTime: upload.CreatedAt}, upload.Path, upload))
+	}
+
+	return files, nil
+}
+
+// Restore takes a file out of the trash, its folders being created again if they were deleted in the meantime
+func Restore(file string) error {
+
+	file, err := cleanFile(file)
+	if err != nil {
+		return err
+	}
+
+	// getting the folders of the file
+	var folders []string
+	for dir := path.Dir(file); dir != dirs.Root; dir = path.Dir(dir) {
+		folders = append(folders, dir)
+	}
+	err = catalog.EnsureFolders(folders...)
+	if err != nil {
+		return fmt.Errorf("error creating the folders: %w", err)
+	}
+
+	err = catalog.Restore(file)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return ErrFileNotFound
+		default:
+			return fmt.Errorf("error restoring file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// PurgeTrash deletes for good the files moved to the trash before the time before, and returns how many were purged
+func PurgeTrash(before time.Time) (int, error) {
+
+	uploads, err := catalog.GetTrash(before)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		purged int
+		errs   []error
+	)
+
+	for _, upload := range uploads {
+		err = purge(upload.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", upload.Path, err))
+			continue
+		}
+		purged++
+	}
+
+	// removing the contents left by the purges interrupted before their blob was deleted
+	hashes, err := catalog.GetUnusedBlobs()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, hash := range hashes {
+		if err = removeBlob(hash); err != nil {
+			errs = append(errs, fmt.Errorf("blob %s: %w", hash, err))
+		}
+	}
+
+	return purged, errors.Join(errs...)
+}
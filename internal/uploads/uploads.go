@@ -1,28 +1,56 @@
 package uploads
 
 import (
+	"Portfolio/internal/data"
 	"Portfolio/internal/validator"
 	"Portfolio/ui"
 	"bytes"
 	"errors"
 	"fmt"
 	"html/template"
+	"image"
 	"io"
+	"io/fs"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
 
-// File type with DirEntry embedded and the icon URL
+// File type with DirEntry embedded, the icon URL and the catalog metadata (nil for directories)
 type File struct {
 	os.DirEntry
-	Icon string
-	Path string
-	Type string
+	Icon   string
+	Path   string
+	Type   string
+	Upload *data.Upload
+}
+
+// entry describes a directory or an upload of the catalog as an os.DirEntry
+type entry struct {
+	name    string
+	dir     bool
+	size    int64
+	modTime time.Time
+}
+
+func (e entry) Name() string               { return e.name }
+func (e entry) IsDir() bool                { return e.dir }
+func (e entry) Type() fs.FileMode          { return e.Mode().Type() }
+func (e entry) Info() (fs.FileInfo, error) { return e, nil }
+func (e entry) Size() int64                { return e.size }
+func (e entry) ModTime() time.Time         { return e.modTime }
+func (e entry) Sys() any                   { return nil }
+
+func (e entry) Mode() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
 }
 
 type icon struct {
@@ -32,12 +60,31 @@ type icon struct {
 
 type Browser struct {
 	Dirname string
+	Search  string
+	Type    string
+	Sort    string
 	Files   []File
+	Folders []string
+	Trash   bool
+	Orphans bool
+}
+
+// FileTypes contains the MIME type LIKE patterns of each type filter of the file browser
+var FileTypes = map[string][]string{
+	"image":    {"image/%"},
+	"video":    {"video/%"},
+	"audio":    {"audio/%", "application/ogg"},
+	"pdf":      {"application/pdf", "application/postscript"},
+	"document": {"text/%", "application/x-ole-storage"},
+	"archive":  {"application/zip", "application/x-gzip", "application/x-rar-compressed", "application/x-7z-compressed"},
 }
 
 var functions = template.FuncMap{
-	"filename": filename,
-	"isDir":    isDir,
+	"filename":  filename,
+	"isDir":     isDir,
+	"humanSize": HumanSize,
+	"fileTypes": TypeNames,
+	"isBuiltin": IsBuiltinFolder,
 }
 
 func filename(file File) string {
@@ -48,14 +95,48 @@ func isDir(file File) bool {
 	return file.IsDir()
 }
 
+// HumanSize formats a size in bytes with its unit
+func HumanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// TypeNames returns the sorted type filters of the file browser
+func TypeNames() []string {
+	types := make([]string, 0, len(FileTypes))
+	for t := range FileTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// defaultAlt returns the alt text given to an image from its file name
+func defaultAlt(name string) string {
+	name = strings.TrimSuffix(path.Base(name), path.Ext(name))
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.' || r == ' '
+	}), " ")
+}
+
 var (
 	ErrForbiddenDirectory = errors.New("invalid or forbidden directory")
 	ErrFileNotFound       = errors.New("file not found")
 	ErrEmptyFileName      = errors.New("empty file name")
 	ErrFileName           = errors.New("invalid file name")
+	ErrFileReferenced     = errors.New("file used by posts or projects")
 
-	// dirList contains all the allowed directories to list and access from the web app
-	dirList = []string{dirs.Root, dirs.Image, dirs.PDF}
+	// dirList contains the built-in folders the uploads are saved to, which can't be renamed, moved or deleted
+	// (the other folders are created from the file browser and recorded in the catalog)
+	dirList = []string{dirs.Root, dirs.Image, dirs.PDF, dirs.Private}
 
 	// appExt contains all known application extensions
 	appExt = []string{".exe", ".apk", ".app", ".class", ".dll", ".jar", ".war", ".obj"}
@@ -117,17 +198,17 @@ var fileType = struct {
 	Video:        icon{"video", "/static/img/icons/files/video-icon.svg"},
 }
 
-// dirs is the enum-like containing all directories
-//
-// WHEN ADDING NEW VALUES, ADD THEM TO dirList VARIABLE TOO!
+// dirs is the enum-like containing the built-in directories
 var dirs = struct {
-	Root  string
-	Image string
-	PDF   string
+	Root    string
+	Image   string
+	PDF     string
+	Private string
 }{
-	Root:  "uploads",
-	Image: "uploads/img",
-	PDF:   "uploads/docs",
+	Root:    "uploads",
+	Image:   "uploads/img",
+	PDF:     "uploads/docs",
+	Private: "uploads/private",
 }
 
 func fileExists(filename string) bool {
@@ -147,22 +228,63 @@ func initDir(dirname string) error {
 	return nil
 }
 
-// Init initializes the uploads directory and subdirectories
-func Init() error {
-	for _, dir := range dirList {
-		err := initDir(dir)
-		if err != nil {
-			return err
-		}
+// Init sets the storage keeping the uploads and the catalog mapping their names to the blobs,
+// and moves the files uploaded in the local directories before the blob store to the storage
+func Init(model *data.UploadModel, storage Storage) error {
+
+	catalog = model
+	store = storage
+
+	// making sure the uploads can always be saved to the built-in folders
+	err := catalog.EnsureFolders(dirList...)
+	if err != nil {
+		return fmt.Errorf("error creating the built-in folders: %w", err)
 	}
-	return nil
+
+	return adopt()
 }
 
-// Add uploads a file in the uploads directory
-func Add(file multipart.File, header *multipart.FileHeader) (string, error) {
+// Add uploads a file in the uploads directory on behalf of the user userID,
+// and returns the recorded upload with a message reporting the files with the same content
+func Add(file multipart.File, header *multipart.FileHeader, userID int) (*data.Upload, string, error) {
+
+	// checking the announced size first
+	if header.Size > policy.MaxFileSize {
+		return nil, "", fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, policy.MaxFileSize)
+	}
+
+	// saving the file under its client name
+	upload, duplicates, err := Save(header.Filename, file, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// return the message, reporting the files with the same content
+	switch {
+	case validator.PermittedValue(upload.Path, duplicates...):
+		return upload, fmt.Sprintf("duplicate of %s, no bytes copied", upload.Path), nil
+	case len(duplicates) > 0:
+		return upload, fmt.Sprintf("%s added as a duplicate of %s, no bytes copied", upload.Path, strings.Join(duplicates, ", ")), nil
+	default:
+		return upload, fmt.Sprintf("%d bytes copied to %s", upload.Size, upload.Path), nil
+	}
+}
+
+// Save stores the content of src in the uploads directory matching the extension of name,
+// recording userID as its uploader (0 when unknown).
+//
+// The content is stored once under its SHA-256 hash: when a file of the same directory already has it,
+// that file is returned instead of a new one. The files already holding the content are returned with the upload.
+func Save(name string, src io.Reader, userID int) (*data.Upload, []string, error) {
+	return save(name, "", src, userID)
+}
+
+// save stores the content of src like Save, in the folder dir when it is set,
+// the content being still checked against the policy of the directory matching the extension
+func save(name, dir string, src io.Reader, userID int) (*data.Upload, []string, error) {
 
 	// extracting the file name and extension
-	_, filename := path.Split(header.Filename)
+	_, filename := path.Split(name)
 	ext := path.Ext(filename)
 	filename = strings.TrimSuffix(filename, ext)
 	if !validator.CheckFileName(filename) {
@@ -170,97 +292,306 @@ func Add(file multipart.File, header *multipart.FileHeader) (string, error) {
 	}
 
 	// setting the appropriate directory according to the file extension
-	var dir string
+	var kind string
 	switch {
-	case validator.PermittedValue(ext, imgExt...):
-		dir = dirs.Image
-	case validator.PermittedValue(ext, pdfExt...):
-		dir = dirs.PDF
+	case validator.PermittedValue(strings.ToLower(ext), imgExt...):
+		kind = dirs.Image
+	case validator.PermittedValue(strings.ToLower(ext), pdfExt...):
+		kind = dirs.PDF
 	default:
-		dir = dirs.Root
+		kind = dirs.Root
+	}
+	if dir == "" {
+		dir = kind
+	}
+
+	// checking the real content of the file
+	mime, src, err := verify(kind, ext, src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// writing the content to a temporary file (within the size limit)
+	tmp, err := os.CreateTemp("", "upload-*"+ext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating file: %w", err)
+	}
+	nbBytes, err := io.Copy(tmp, io.LimitReader(src, policy.MaxFileSize+1))
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, nil, fmt.Errorf("error copying file: %w", err)
+	}
+	if nbBytes > policy.MaxFileSize {
+		os.Remove(tmp.Name())
+		return nil, nil, fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, policy.MaxFileSize)
+	}
+
+	// stripping the metadata of the images before hashing them
+	var img image.Image
+	if kind == dirs.Image {
+		img, err = cleanImage(tmp.Name(), mime)
+		if err != nil {
+			os.Remove(tmp.Name())
+			return nil, nil, err
+		}
+	}
+
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, nil, err
+	}
+
+	hash, err := hashFile(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, nil, fmt.Errorf("error hashing file: %w", err)
+	}
+
+	// looking for the files already holding the content
+	duplicates, err := catalog.GetByHash(hash)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, nil, err
+	}
+	for _, duplicate := range duplicates {
+		if path.Dir(duplicate) == dir {
+			os.Remove(tmp.Name())
+			upload, err := catalog.Get(duplicate)
+			return upload, duplicates, err
+		}
 	}
 
-	// creating the destination file
-	filename = filepath.Join(dir, fmt.Sprint(filename, "_", time.Now().Format("2006-01-02T15:04"), ext))
-	dst, err := os.Create(filename)
+	// setting a destination name that doesn't overwrite an existing file
+	base := fmt.Sprint(filename, "_", time.Now().Format("2006-01-02T15:04"))
+	filename = path.Join(dir, base+ext)
+	for i := 1; ; i++ {
+		exists, err := catalog.Exists(filename)
+		if err != nil {
+			os.Remove(tmp.Name())
+			return nil, nil, err
+		}
+		if !exists {
+			break
+		}
+		filename = path.Join(dir, fmt.Sprint(base, "_", i, ext))
+	}
+
+	// recording the name with the metadata
+	upload := &data.Upload{
+		Path:         filename,
+		Hash:         hash,
+		Size:         info.Size(),
+		MIME:         mime,
+		OriginalName: path.Base(name),
+		UploadedBy:   userID,
+	}
+	if img != nil {
+		upload.Width = img.Bounds().Dx()
+		upload.Height = img.Bounds().Dy()
+	}
+	if kind == dirs.Image {
+		upload.Alt = defaultAlt(name)
+	}
+	err = catalog.Insert(upload)
 	if err != nil {
-		return "", fmt.Errorf("error creating file: %w", err)
+		os.Remove(tmp.Name())
+		return nil, nil, err
 	}
-	defer dst.Close()
 
-	// upload the file to destination path
-	nbBytes, err := io.Copy(dst, file)
+	// storing the content once, with the variants of the images
+	isNew, err := storeBlob(tmp.Name(), hash)
+	if err == nil && isNew && img != nil {
+		err = writeVariants(hash, img, mime)
+	}
 	if err != nil {
-		return "", fmt.Errorf("error copying file: %w", err)
+		os.Remove(tmp.Name())
+		if catalog.Trash(upload.Path, userID) == nil {
+			purge(upload.Path)
+		}
+		return nil, nil, err
 	}
 
-	// return the message
-	return fmt.Sprintf("%d bytes copied to %s", nbBytes, filename), nil
+	return upload, duplicates, nil
 }
 
-// Remove deletes a file
-func Remove(file string) error {
+// cleanFile returns file as a slash-separated path of the uploads directory, checking its folder and name formats
+func cleanFile(file string) (string, error) {
 
 	// cleaning the file path
-	file = filepath.Clean(file)
+	file = path.Clean(filepath.ToSlash(file))
 
 	// checking the directory
-	if !validator.PermittedValue(filepath.Dir(file), dirList...) {
-		return ErrForbiddenDirectory
+	dir, err := cleanFolder(path.Dir(file))
+	if err != nil {
+		return "", err
 	}
 
 	// checking the filename format
-	if !validator.CheckFileName(filepath.Base(file)) {
-		return ErrFileName
+	if !validator.CheckFileName(path.Base(file)) {
+		return "", ErrFileName
 	}
 
-	// checking if the file exists
-	if !fileExists(file) {
-		return ErrFileNotFound
+	return path.Join(dir, path.Base(file)), nil
+}
+
+// Remove moves a file to the trash on behalf of the user userID, from where it can be restored until it is purged.
+// A file still used by posts or projects is only removed when force is set.
+func Remove(file string, userID int, force bool) error {
+
+	file, err := cleanFile(file)
+	if err != nil {
+		return err
 	}
 
-	err := os.Remove(file)
+	// checking that no post nor project uses the file anymore
+	if !force {
+		upload, err := catalog.Get(file)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				return ErrFileNotFound
+			default:
+				return err
+			}
+		}
+		if len(upload.UsedIn) > 0 {
+			return referencedError(upload.UsedIn)
+		}
+	}
+
+	err = catalog.Trash(file, userID)
 	if err != nil {
-		return fmt.Errorf("error removing file: %w", err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return ErrFileNotFound
+		default:
+			return fmt.Errorf("error removing file: %w", err)
+		}
 	}
+	forgetImage(file)
 
 	return nil
 }
 
-// Get lists the files and directories in a directory
-func Get(dirname string) ([]File, error) {
+// referencedError returns the error listing the posts and the projects of usages using a file
+func referencedError(usages []data.UsageLink) error {
+	titles := make([]string, len(usages))
+	for i, usage := range usages {
+		titles[i] = fmt.Sprintf("%q", usage.Title)
+	}
+	return fmt.Errorf("%w: %s", ErrFileReferenced, strings.Join(titles, ", "))
+}
+
+// purge deletes a file of the trash for good, its content being deleted when no other file points to it
+func purge(file string) error {
+
+	// removing the name
+	hash, orphan, err := catalog.Delete(file)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return ErrFileNotFound
+		default:
+			return fmt.Errorf("error removing file: %w", err)
+		}
+	}
+	forgetImage(file)
 
-	// checking if dirname corresponds to an allowed directory
-	if !validator.PermittedValue(dirname, dirList...) {
-		return nil, ErrForbiddenDirectory
+	// removing the content if nothing points to it anymore
+	if orphan {
+		return removeBlob(hash)
+	}
+
+	return nil
+}
+
+// Serve returns the handler serving the public uploaded files from their blobs,
+// and the downscaled variants of the images when the w query parameter is set
+func Serve() http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		file := path.Join(dirs.Root, path.Clean("/"+r.URL.Path))
+
+		// the private files are only served through signed URLs
+		if IsPrivate(file) {
+			http.NotFound(w, r)
+			return
+		}
+
+		serveFile(w, r, file, "public, max-age=604800")
+	})
+}
+
+// Get lists the files and directories in a directory,
+// the files being filtered on search and on the type filter fileType when they are set
+func Get(dirname, search, fileType string, filters *data.Filters) ([]File, error) {
+
+	// checking if dirname corresponds to a folder of the catalog
+	dirname, err := checkFolder(dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	// checking the type filter
+	mimes, ok := FileTypes[fileType]
+	if fileType != "" && !ok {
+		return nil, fmt.Errorf("unknown file type %q", fileType)
 	}
 
 	// creating the File list
 	var files []File
 
-	// reading the directory asked for
-	entries, err := os.ReadDir(dirname)
+	// listing the subdirectories when nothing is filtered
+	if search == "" && fileType == "" {
+		folders, err := catalog.ListFolders(dirname)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range folders {
+			files = append(files, newFile(entry{name: path.Base(dir), dir: true}, dir, nil))
+		}
+	}
+
+	// reading the files of the directory from the catalog
+	uploads, err := catalog.Search(dirname, search, mimes, filters)
 	if err != nil {
 		return nil, err
 	}
 
-	// converting the DirEntries to File types (adding the icon)
-	for _, entry := range entries {
+	for _, upload := range uploads {
+		files = append(files, newFile(entry{name: path.Base(upload.Path), size: upload.Size, modTime: upload.CreatedAt}, upload.Path, upload))
+	}
+
+	// returning the files/directories
+	return files, nil
+}
+
+// Orphans lists the files no post nor project uses (the avatars and the CV file aside), the largest first
+func Orphans() ([]File, error) {
 
-		// creating the file with the DirEntry
-		var file File
-		file.DirEntry = entry
-		file.addIconType()
-		file.Path = path.Join(dirname, entry.Name())
+	uploads, err := catalog.GetOrphans()
+	if err != nil {
+		return nil, err
+	}
 
-		// appending the file to the list
-		files = append(files, file)
+	var files []File
+	for _, upload := range uploads {
+		files = append(files, newFile(entry{name: path.Base(upload.Path), size: upload.Size, modTime: upload.CreatedAt}, upload.Path, upload))
 	}
 
-	// returning the files/directories
 	return files, nil
 }
 
+// newFile creates a File from its entry (adding the icon)
+func newFile(e entry, filePath string, upload *data.Upload) File {
+	file := File{DirEntry: e, Path: filePath, Upload: upload}
+	file.addIconType()
+	return file
+}
+
 // addIconType assigns an icon and a file Type to a File according to its extension
 func (file *File) addIconType() {
 
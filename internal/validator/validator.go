@@ -3,6 +3,7 @@ package validator
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"regexp"
 	"slices"
 	"strings"
@@ -139,6 +140,16 @@ func (v *Validator) ValidateToken(token string) {
 	v.Check(len(token) == 86, "token", "invalid link")
 }
 
+// CheckURL checks that rawURL is empty or an absolute http(s) URL of 250 bytes at most
+func (v *Validator) CheckURL(rawURL, key string) {
+	if rawURL == "" {
+		return
+	}
+	u, err := url.Parse(rawURL)
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", key, "must be a valid http(s) URL")
+	v.Check(len(rawURL) <= 250, key, "must not be more than 250 bytes long")
+}
+
 func CheckFileName(filename string) bool {
 	if PrintableRX.MatchString(filename) {
 		return FileRX.MatchString(filename)
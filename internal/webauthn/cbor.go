@@ -0,0 +1,157 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// maxDepth is the nesting of the arrays and the maps decoded, the attestation objects only going three levels deep
+const maxDepth = 8
+
+var errCBOR = errors.New("malformed CBOR")
+
+// decodeCBOR decodes the first CBOR item of data (RFC 8949), returning it with the rest of data.
+// Only the items sent by the authenticators are supported: integers (int64), byte strings ([]byte),
+// text strings (string), arrays ([]any), maps (map[any]any, keyed by integers or text strings),
+// booleans and null, of definite lengths, the tags being ignored
+func decodeCBOR(data []byte) (any, []byte, error) {
+	return decodeItem(data, 0)
+}
+
+func decodeItem(data []byte, depth int) (any, []byte, error) {
+
+	if depth > maxDepth {
+		return nil, nil, errCBOR
+	}
+
+	major, argument, data, err := decodeHead(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+
+	// unsigned and negative integers
+	case 0, 1:
+		if argument > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		if major == 1 {
+			return -1 - int64(argument), data, nil
+		}
+		return int64(argument), data, nil
+
+	// byte and text strings
+	case 2, 3:
+		if argument > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		value, rest := data[:argument], data[argument:]
+		if major == 3 {
+			return string(value), rest, nil
+		}
+		return value, rest, nil
+
+	// arrays, each item taking a byte at least
+	case 4:
+		if argument > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		array := make([]any, argument)
+		for i := range array {
+			array[i], data, err = decodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return array, data, nil
+
+	// maps, each pair taking two bytes at least
+	case 5:
+		if argument > uint64(len(data))/2 {
+			return nil, nil, errCBOR
+		}
+		items := make(map[any]any, argument)
+		for range argument {
+			var key, value any
+			key, data, err = decodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBOR
+			}
+			if _, exists := items[key]; exists {
+				return nil, nil, errCBOR
+			}
+			value, data, err = decodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items[key] = value
+		}
+		return items, data, nil
+
+	// tagged items
+	case 6:
+		return decodeItem(data, depth+1)
+
+	// simple values (false, true, null and undefined)
+	default:
+		switch argument {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		default:
+			return nil, nil, errCBOR
+		}
+	}
+}
+
+// decodeHead returns the major type of the item starting data and its argument (value, length or tag number)
+func decodeHead(data []byte) (byte, uint64, []byte, error) {
+
+	if len(data) == 0 {
+		return 0, 0, nil, errCBOR
+	}
+	major, info, data := data[0]>>5, data[0]&0x1f, data[1:]
+
+	// the argument being in the additional information, or in the following 1, 2, 4 or 8 bytes
+	switch {
+	case info < 24:
+		return major, uint64(info), data, nil
+	case info > 27:
+		// indefinite lengths and reserved values
+		return 0, 0, nil, errCBOR
+	}
+
+	size := 1 << (info - 24)
+	if len(data) < size {
+		return 0, 0, nil, errCBOR
+	}
+
+	var argument uint64
+	switch size {
+	case 1:
+		argument = uint64(data[0])
+	case 2:
+		argument = uint64(binary.BigEndian.Uint16(data))
+	case 4:
+		argument = uint64(binary.BigEndian.Uint32(data))
+	default:
+		argument = binary.BigEndian.Uint64(data)
+	}
+
+	// the simple values on more than a byte being floats
+	if major == 7 && size > 1 {
+		return 0, 0, nil, errCBOR
+	}
+
+	return major, argument, data[size:], nil
+}
@@ -0,0 +1,132 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithms (RFC 9053) of the passkeys, in order of preference
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// COSE key parameters and values
+const (
+	coseKty = 1
+	coseAlg = 3
+
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+
+	// minRSABits is the size of the smallest RSA key accepted
+	minRSABits = 2048
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported public key")
+	ErrSignature      = errors.New("invalid signature")
+)
+
+// publicKey is the public key of a passkey, with the algorithm of its signatures
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parsePublicKey parses the public key of a passkey encoded as a COSE_Key
+func parsePublicKey(raw []byte) (*publicKey, error) {
+
+	item, rest, err := decodeCBOR(raw)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrUnsupportedKey
+	}
+	params, ok := item.(map[any]any)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	kty, _ := params[int64(coseKty)].(int64)
+	alg, _ := params[int64(coseAlg)].(int64)
+	crv, _ := params[int64(coseCrv)].(int64)
+
+	switch {
+
+	// ECDSA with the P-256 curve, the point being checked
+	case alg == AlgES256 && kty == ktyEC2 && crv == crvP256:
+		x, _ := params[int64(coseX)].([]byte)
+		y, _ := params[int64(coseY)].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, ErrUnsupportedKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return &publicKey{alg: alg, key: key}, nil
+
+	// Ed25519
+	case alg == AlgEdDSA && kty == ktyOKP && crv == crvEd25519:
+		x, _ := params[int64(coseX)].([]byte)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+
+	// RSASSA-PKCS1-v1_5 with SHA-256
+	case alg == AlgRS256 && kty == ktyRSA:
+		n, _ := params[int64(coseN)].([]byte)
+		e, _ := params[int64(coseE)].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}
+		if key.N.BitLen() < minRSABits || exponent < 3 || exponent%2 == 0 {
+			return nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: key}, nil
+	}
+
+	return nil, ErrUnsupportedKey
+}
+
+// verify checks the signature of data, returning ErrSignature if it is invalid
+func (k *publicKey) verify(data, signature []byte) error {
+
+	hash := sha256.Sum256(data)
+
+	valid := false
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, hash[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil
+	}
+
+	if !valid {
+		return ErrSignature
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+// Package webauthn implements the registration and the authentication ceremonies of the passkeys
+// (Web Authentication, level 2) for a single relying party. The passkeys are discoverable credentials verifying
+// their user (PIN, biometrics), their attestation not being requested ("none" conveyance) nor checked.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// ChallengeSize is the size in bytes of the challenges
+	ChallengeSize = 32
+
+	// Timeout is the time the user has to complete a ceremony
+	Timeout = 5 * time.Minute
+)
+
+// flags of the authenticator data
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+)
+
+var (
+	ErrInvalidResponse = errors.New("invalid authenticator response")
+	ErrClonedPasskey   = errors.New("signature counter went backwards, the passkey may have been cloned")
+)
+
+// URLEncoded is binary data encoded in base64url without padding in JSON, as handled by the client script
+type URLEncoded []byte
+
+func (u URLEncoded) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(u))
+}
+
+func (u *URLEncoded) UnmarshalJSON(data []byte) error {
+	var encoded string
+	err := json.Unmarshal(data, &encoded)
+	if err != nil {
+		return err
+	}
+	*u, err = base64.RawURLEncoding.DecodeString(encoded)
+	return err
+}
+
+// RelyingParty is the site the passkeys are registered for, ID being its domain and Origin its URL
+// ("https://example.com")
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+// User is the account a passkey is registered for, ID being the user handle stored by the authenticator
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// Credential is a passkey once registered, PublicKey being its COSE_Key
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+// NewChallenge returns a new random challenge
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, ChallengeSize)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+type relyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          URLEncoded `json:"id"`
+	Name        string     `json:"name"`
+	DisplayName string     `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type credentialDescriptor struct {
+	Type string     `json:"type"`
+	ID   URLEncoded `json:"id"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// CreationOptions are the options of navigator.credentials.create() to register a passkey
+type CreationOptions struct {
+	Challenge              URLEncoded             `json:"challenge"`
+	RP                     relyingPartyEntity     `json:"rp"`
+	User                   userEntity             `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options of navigator.credentials.get() to sign in with a passkey,
+// the authenticator offering the passkeys it holds for the relying party
+type RequestOptions struct {
+	Challenge        URLEncoded `json:"challenge"`
+	Timeout          int64      `json:"timeout"`
+	RPID             string     `json:"rpId"`
+	UserVerification string     `json:"userVerification"`
+}
+
+// AttestationResponse is the response of the authenticator to the registration, sent by the client script
+type AttestationResponse struct {
+	ID                URLEncoded `json:"id"`
+	ClientDataJSON    URLEncoded `json:"clientDataJSON"`
+	AttestationObject URLEncoded `json:"attestationObject"`
+}
+
+// AssertionResponse is the response of the authenticator to the authentication, sent by the client script
+type AssertionResponse struct {
+	ID                URLEncoded `json:"id"`
+	ClientDataJSON    URLEncoded `json:"clientDataJSON"`
+	AuthenticatorData URLEncoded `json:"authenticatorData"`
+	Signature         URLEncoded `json:"signature"`
+	UserHandle        URLEncoded `json:"userHandle"`
+}
+
+// CreationOptions returns the options to register a passkey for user, the passkeys in exclude being
+// those they already registered
+func (rp RelyingParty) CreationOptions(challenge []byte, user User, exclude [][]byte) CreationOptions {
+
+	options := CreationOptions{
+		Challenge: challenge,
+		RP:        relyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:      userEntity{ID: user.ID, Name: user.Name, DisplayName: user.DisplayName},
+		PubKeyCredParams: []credentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            Timeout.Milliseconds(),
+		ExcludeCredentials: []credentialDescriptor{},
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}
+
+	for _, id := range exclude {
+		options.ExcludeCredentials = append(options.ExcludeCredentials, credentialDescriptor{Type: "public-key", ID: id})
+	}
+
+	return options
+}
+
+// RequestOptions returns the options to sign in with a passkey
+func (rp RelyingParty) RequestOptions(challenge []byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		UserVerification: "required",
+	}
+}
+
+// VerifyRegistration checks the response of the authenticator to the registration of challenge,
+// returning the passkey to store
+func (rp RelyingParty) VerifyRegistration(challenge []byte, response AttestationResponse) (*Credential, error) {
+
+	err := rp.verifyClientData(response.ClientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	// decoding the attestation object, its statement being ignored
+	item, rest, err := decodeCBOR(response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidResponse)
+	}
+	attestation, _ := item.(map[any]any)
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing authenticator data", ErrInvalidResponse)
+	}
+
+	authData, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedCredData == 0 {
+		return nil, fmt.Errorf("%w: missing credential", ErrInvalidResponse)
+	}
+	if !bytes.Equal(authData.credentialID, response.ID) {
+		return nil, fmt.Errorf("%w: credential id mismatch", ErrInvalidResponse)
+	}
+
+	// checking that the algorithm of the public key is supported
+	_, err = parsePublicKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        authData.credentialID,
+		PublicKey: authData.publicKey,
+		SignCount: authData.signCount,
+	}, nil
+}
+
+// VerifyAssertion checks the response of the authenticator to the authentication of challenge with the passkey
+// credential, returning its new signature counter
+func (rp RelyingParty) VerifyAssertion(challenge []byte, response AssertionResponse, credential *Credential) (uint32, error) {
+
+	if !bytes.Equal(credential.ID, response.ID) {
+		return 0, fmt.Errorf("%w: credential id mismatch", ErrInvalidResponse)
+	}
+
+	err := rp.verifyClientData(response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	authData, err := rp.parseAuthenticatorData(response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	// checking the signature of the authenticator data and of the hash of the client data
+	key, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(response.ClientDataJSON)
+	signed := append(bytes.Clone(response.AuthenticatorData), clientDataHash[:]...)
+	err = key.verify(signed, response.Signature)
+	if err != nil {
+		return 0, err
+	}
+
+	// checking the signature counter, the synced passkeys always sending 0
+	if (authData.signCount != 0 || credential.SignCount != 0) && authData.signCount <= credential.SignCount {
+		return 0, ErrClonedPasskey
+	}
+
+	return authData.signCount, nil
+}
+
+// clientData is the data the browser passed to the authenticator
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// verifyClientData checks that the client data is from the ceremony of type for challenge on the site
+func (rp RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+
+	var data clientData
+	err := json.Unmarshal(raw, &data)
+	if err != nil {
+		return fmt.Errorf("%w: malformed client data", ErrInvalidResponse)
+	}
+
+	switch {
+	case data.Type != ceremony:
+		return fmt.Errorf("%w: unexpected ceremony %q", ErrInvalidResponse, data.Type)
+	case data.Origin != rp.Origin || data.CrossOrigin:
+		return fmt.Errorf("%w: unexpected origin %q", ErrInvalidResponse, data.Origin)
+	}
+
+	received, err := base64.RawURLEncoding.DecodeString(data.Challenge)
+	if err != nil || len(challenge) == 0 || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalidResponse)
+	}
+
+	return nil
+}
+
+// authenticatorData is the data signed by the authenticator, with the passkey it created on registration
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+// parseAuthenticatorData parses the authenticator data, checking that it is for the site and that the user
+// was present and verified
+func (rp RelyingParty) parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+
+	// rpIdHash (32 bytes), flags (1 byte), signCount (4 bytes)
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidResponse)
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(data[:32], rpIDHash[:]) != 1 {
+		return nil, fmt.Errorf("%w: relying party mismatch", ErrInvalidResponse)
+	}
+
+	authData := &authenticatorData{
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if authData.flags&flagUserPresent == 0 || authData.flags&flagUserVerified == 0 {
+		return nil, fmt.Errorf("%w: user not verified", ErrInvalidResponse)
+	}
+
+	// attested credential data: aaguid (16 bytes), credentialIdLength (2 bytes), credentialId, credentialPublicKey
+	if authData.flags&flagAttestedCredData != 0 {
+		data = data[37:]
+		if len(data) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidResponse)
+		}
+		length := int(binary.BigEndian.Uint16(data[16:18]))
+		data = data[18:]
+		if length == 0 || length > 1023 || len(data) < length {
+			return nil, fmt.Errorf("%w: malformed credential id", ErrInvalidResponse)
+		}
+		authData.credentialID, data = data[:length], data[length:]
+
+		_, rest, err := decodeCBOR(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed public key", ErrInvalidResponse)
+		}
+		authData.publicKey = data[:len(data)-len(rest)]
+	}
+
+	return authData, nil
+}
@@ -0,0 +1,184 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// the fixtures are the responses of an authenticator holding a P-256 (ES256) and an Ed25519 passkey
+// registered for example.com, for the challenge 0x00, 0x01... 0x1f and the credential id 0x01, 0x02... 0x10
+const (
+	fixtureES256Key   = "a501020326200121582049614c1423ee8eac0ff0a77de56e0785035fd60af5671b91008e4c3c3d4d7743225820f875c8cfc571fe8abe159d8939184a4c19dbcd5ef59fa21450a7b0d6db69ca51"
+	fixtureEd25519Key = "a401010327200621582035611085c4a243964f2b517044b88df8d50d8311afd48202d5a35d6c87152c47"
+
+	// authenticator data of the assertion: user present and verified, signature counter 5
+	fixtureAuthData = "a379a6f6eeafb9a55e378c118034e2751e682fab9f2d30ab13d2125586ce19470500000005"
+
+	fixtureES256Signature   = "3044022008284febb4a9110750b74e10cc6b5b4ab1f967277efc130ca626a3230bab1697022061ff44df180553bb5cdbaa93188470eb6f345075aace66f3e76383bdbab45996"
+	fixtureEd25519Signature = "90c19edf9f222390365b7900eb8316f0902a9c3976f6fe9ad7f5bd50f17f2dabf8e65d31ab3001ac6662305618d35e0d1dce867733a0403ce4dd5b6293f81006"
+
+	fixtureGetClientData    = `{"type":"webauthn.get","challenge":"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8","origin":"https://example.com","crossOrigin":false}`
+	fixtureCreateClientData = `{"type":"webauthn.create","challenge":"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8","origin":"https://example.com","crossOrigin":false}`
+
+	// attestation object ("none" format) of the registration of the ES256 passkey
+	fixtureAttestation = "a363666d74646e6f6e656761747453746d74a06861757468446174615894a379a6f6eeafb9a55e378c118034e2751e682fab9f2d30ab13d2125586ce194745000000000000000000000000000000000000000000100102030405060708090a0b0c0d0e0f10a501020326200121582049614c1423ee8eac0ff0a77de56e0785035fd60af5671b91008e4c3c3d4d7743225820f875c8cfc571fe8abe159d8939184a4c19dbcd5ef59fa21450a7b0d6db69ca51"
+)
+
+var (
+	fixtureRP           = RelyingParty{ID: "example.com", Name: "Example", Origin: "https://example.com"}
+	fixtureCredentialID = []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	fixtureChallenge    = []byte{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
+	}
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestVerifyRegistration(t *testing.T) {
+
+	response := AttestationResponse{
+		ID:                fixtureCredentialID,
+		ClientDataJSON:    []byte(fixtureCreateClientData),
+		AttestationObject: mustHex(t, fixtureAttestation),
+	}
+
+	credential, err := fixtureRP.VerifyRegistration(fixtureChallenge, response)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	if !bytes.Equal(credential.ID, fixtureCredentialID) {
+		t.Errorf("credential id = %x, want %x", credential.ID, fixtureCredentialID)
+	}
+	if !bytes.Equal(credential.PublicKey, mustHex(t, fixtureES256Key)) {
+		t.Errorf("public key = %x, want %s", credential.PublicKey, fixtureES256Key)
+	}
+	if credential.SignCount != 0 {
+		t.Errorf("signature counter = %d, want 0", credential.SignCount)
+	}
+
+	// the assertion client data is refused for a registration
+	response.ClientDataJSON = []byte(fixtureGetClientData)
+	_, err = fixtureRP.VerifyRegistration(fixtureChallenge, response)
+	if !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("VerifyRegistration with the assertion client data: got %v, want %v", err, ErrInvalidResponse)
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+
+	tamper := func(raw []byte, i int) []byte {
+		raw = bytes.Clone(raw)
+		raw[i] ^= 0x01
+		return raw
+	}
+
+	tests := []struct {
+		name      string
+		rp        RelyingParty
+		key       string
+		signature string
+		signCount uint32
+		edit      func(*AssertionResponse)
+		challenge []byte
+		wantCount uint32
+		wantErr   error
+	}{
+		{name: "ES256", key: fixtureES256Key, signature: fixtureES256Signature, wantCount: 5},
+		{name: "Ed25519", key: fixtureEd25519Key, signature: fixtureEd25519Signature, wantCount: 5},
+		{name: "counter increased", key: fixtureES256Key, signature: fixtureES256Signature, signCount: 4, wantCount: 5},
+		{name: "counter replayed", key: fixtureES256Key, signature: fixtureES256Signature, signCount: 5, wantErr: ErrClonedPasskey},
+		{name: "other challenge", key: fixtureES256Key, signature: fixtureES256Signature, challenge: make([]byte, ChallengeSize), wantErr: ErrInvalidResponse},
+		{
+			name: "other relying party", key: fixtureES256Key, signature: fixtureES256Signature,
+			rp:      RelyingParty{ID: "example.org", Origin: "https://example.com"},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "other origin", key: fixtureES256Key, signature: fixtureES256Signature,
+			rp:      RelyingParty{ID: "example.com", Origin: "https://evil.example.com"},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "other credential", key: fixtureES256Key, signature: fixtureES256Signature,
+			edit:    func(r *AssertionResponse) { r.ID = tamper(r.ID, 0) },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "registration client data", key: fixtureES256Key, signature: fixtureES256Signature,
+			edit:    func(r *AssertionResponse) { r.ClientDataJSON = []byte(fixtureCreateClientData) },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "user not verified", key: fixtureES256Key, signature: fixtureES256Signature,
+			edit:    func(r *AssertionResponse) { r.AuthenticatorData[32] = flagUserPresent },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "tampered counter", key: fixtureES256Key, signature: fixtureES256Signature,
+			edit:    func(r *AssertionResponse) { r.AuthenticatorData = tamper(r.AuthenticatorData, 36) },
+			wantErr: ErrSignature,
+		},
+		{
+			name: "tampered ES256 signature", key: fixtureES256Key, signature: fixtureES256Signature,
+			edit:    func(r *AssertionResponse) { r.Signature = tamper(r.Signature, len(r.Signature)-1) },
+			wantErr: ErrSignature,
+		},
+		{
+			name: "tampered Ed25519 signature", key: fixtureEd25519Key, signature: fixtureEd25519Signature,
+			edit:    func(r *AssertionResponse) { r.Signature = tamper(r.Signature, 0) },
+			wantErr: ErrSignature,
+		},
+		{name: "signature of the other key", key: fixtureEd25519Key, signature: fixtureES256Signature, wantErr: ErrSignature},
+		{name: "unsupported key", key: strings.Replace(fixtureEd25519Key, "0327", "0326", 1), signature: fixtureEd25519Signature, wantErr: ErrUnsupportedKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			rp := tt.rp
+			if rp.ID == "" {
+				rp = fixtureRP
+			}
+			challenge := tt.challenge
+			if challenge == nil {
+				challenge = fixtureChallenge
+			}
+
+			response := AssertionResponse{
+				ID:                bytes.Clone(fixtureCredentialID),
+				ClientDataJSON:    []byte(fixtureGetClientData),
+				AuthenticatorData: mustHex(t, fixtureAuthData),
+				Signature:         mustHex(t, tt.signature),
+			}
+			if tt.edit != nil {
+				tt.edit(&response)
+			}
+			credential := &Credential{ID: fixtureCredentialID, PublicKey: mustHex(t, tt.key), SignCount: tt.signCount}
+
+			count, err := rp.VerifyAssertion(challenge, response, credential)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifyAssertion: got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyAssertion: %v", err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("signature counter = %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
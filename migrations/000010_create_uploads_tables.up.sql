@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS upload_blobs (
+    hash char(64) PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    size bigint NOT NULL,
+    mime text NOT NULL,
+    ref_count integer NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS upload_files (
+    path text PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    hash char(64) NOT NULL REFERENCES upload_blobs ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS upload_files_hash_idx ON upload_files (hash);
@@ -0,0 +1,9 @@
+ALTER TABLE upload_files
+    DROP COLUMN IF EXISTS caption,
+    DROP COLUMN IF EXISTS alt,
+    DROP COLUMN IF EXISTS uploaded_by,
+    DROP COLUMN IF EXISTS original_name;
+
+ALTER TABLE upload_blobs
+    DROP COLUMN IF EXISTS height,
+    DROP COLUMN IF EXISTS width;
@@ -0,0 +1,9 @@
+ALTER TABLE upload_blobs
+    ADD COLUMN IF NOT EXISTS width integer,
+    ADD COLUMN IF NOT EXISTS height integer;
+
+ALTER TABLE upload_files
+    ADD COLUMN IF NOT EXISTS original_name text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS uploaded_by bigint REFERENCES users ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS alt text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS caption text NOT NULL DEFAULT '';
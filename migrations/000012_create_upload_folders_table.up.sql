@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS upload_folders (
+    path text PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO upload_folders (path)
+VALUES ('uploads'), ('uploads/img'), ('uploads/docs')
+ON CONFLICT (path) DO NOTHING;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS upload_files_deleted_at_idx;
+
+ALTER TABLE upload_files
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,5 @@
+ALTER TABLE upload_files
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone,
+    ADD COLUMN IF NOT EXISTS deleted_by bigint REFERENCES users ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS upload_files_deleted_at_idx ON upload_files (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS upload_references (
+    path text NOT NULL,
+    post_id bigint NOT NULL REFERENCES posts ON DELETE CASCADE,
+    PRIMARY KEY (path, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS upload_references_post_id_idx ON upload_references (post_id);
+
+-- indexing the links of the existing posts (the posts written afterward are indexed by the application)
+INSERT INTO upload_references (path, post_id)
+SELECT DISTINCT substr(m[1], 2), p.id
+FROM posts p,
+     regexp_matches(p.content || ' ' || array_to_string(p.images, ' '), '(?:^|[\s("''=])(/uploads/[^\s)"''<>?#]+)', 'g') AS m
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,9 @@
+ALTER TABLE author ADD COLUMN IF NOT EXISTS formations text[];
+ALTER TABLE author ADD COLUMN IF NOT EXISTS experiences text[];
+
+UPDATE author a
+SET formations = ARRAY(SELECT f.degree FROM formations f WHERE f.author_id = a.id ORDER BY f.position, f.start_date DESC NULLS LAST),
+    experiences = ARRAY(SELECT e.role FROM experiences e WHERE e.author_id = a.id ORDER BY e.position, e.start_date DESC NULLS LAST);
+
+DROP TABLE IF EXISTS formations;
+DROP TABLE IF EXISTS experiences;
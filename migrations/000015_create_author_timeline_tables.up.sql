@@ -0,0 +1,56 @@
+CREATE TABLE IF NOT EXISTS formations (
+    id bigserial PRIMARY KEY,
+    author_id bigint NOT NULL REFERENCES author ON DELETE CASCADE,
+    organization text NOT NULL DEFAULT '',
+    degree text NOT NULL,
+    location text NOT NULL DEFAULT '',
+    start_date date,
+    end_date date,
+    description text NOT NULL DEFAULT '',
+    url text NOT NULL DEFAULT '',
+    position integer NOT NULL DEFAULT 0,
+    CONSTRAINT formations_dates_check CHECK (end_date IS NULL OR start_date IS NULL OR end_date >= start_date)
+);
+
+CREATE TABLE IF NOT EXISTS experiences (
+    id bigserial PRIMARY KEY,
+    author_id bigint NOT NULL REFERENCES author ON DELETE CASCADE,
+    organization text NOT NULL DEFAULT '',
+    role text NOT NULL,
+    location text NOT NULL DEFAULT '',
+    start_date date,
+    end_date date,
+    description text NOT NULL DEFAULT '',
+    url text NOT NULL DEFAULT '',
+    position integer NOT NULL DEFAULT 0,
+    CONSTRAINT experiences_dates_check CHECK (end_date IS NULL OR start_date IS NULL OR end_date >= start_date)
+);
+
+CREATE INDEX IF NOT EXISTS formations_author_id_idx ON formations (author_id);
+CREATE INDEX IF NOT EXISTS experiences_author_id_idx ON experiences (author_id);
+
+-- moving the free-text entries to the tables, the first two years found in an entry becoming its dates
+INSERT INTO formations (author_id, degree, start_date, end_date, position)
+SELECT a.id, e.text,
+       make_date(LEAST(y.start_year, y.end_year), 1, 1),
+       CASE WHEN y.end_year IS NOT NULL THEN make_date(GREATEST(y.start_year, y.end_year), 1, 1) END,
+       e.ord
+FROM author a,
+     unnest(a.formations) WITH ORDINALITY AS e(text, ord),
+     LATERAL (SELECT substring(e.text FROM '\m((?:19|20)\d{2})\M')::int AS start_year,
+                     substring(e.text FROM '\m(?:19|20)\d{2}\M\D+\m((?:19|20)\d{2})\M')::int AS end_year) AS y
+WHERE trim(e.text) <> '';
+
+INSERT INTO experiences (author_id, role, start_date, end_date, position)
+SELECT a.id, e.text,
+       make_date(LEAST(y.start_year, y.end_year), 1, 1),
+       CASE WHEN y.end_year IS NOT NULL THEN make_date(GREATEST(y.start_year, y.end_year), 1, 1) END,
+       e.ord
+FROM author a,
+     unnest(a.experiences) WITH ORDINALITY AS e(text, ord),
+     LATERAL (SELECT substring(e.text FROM '\m((?:19|20)\d{2})\M')::int AS start_year,
+                     substring(e.text FROM '\m(?:19|20)\d{2}\M\D+\m((?:19|20)\d{2})\M')::int AS end_year) AS y
+WHERE trim(e.text) <> '';
+
+ALTER TABLE author DROP COLUMN IF EXISTS formations;
+ALTER TABLE author DROP COLUMN IF EXISTS experiences;
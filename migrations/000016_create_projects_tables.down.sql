@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS project_upload_references;
+
+DROP TABLE IF EXISTS projects;
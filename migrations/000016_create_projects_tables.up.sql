@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS projects (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    title text NOT NULL UNIQUE,
+    summary text NOT NULL DEFAULT '',
+    description text NOT NULL DEFAULT '',
+    role text NOT NULL DEFAULT '',
+    tech_stack text[] NOT NULL DEFAULT '{}',
+    repository_url text NOT NULL DEFAULT '',
+    demo_url text NOT NULL DEFAULT '',
+    screenshots text[] NOT NULL DEFAULT '{}',
+    status text NOT NULL DEFAULT 'in-progress',
+    started_on date,
+    ended_on date,
+    featured boolean NOT NULL DEFAULT false,
+    position integer NOT NULL DEFAULT 0,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT projects_status_check CHECK (status IN ('planned', 'in-progress', 'completed', 'archived')),
+    CONSTRAINT projects_dates_check CHECK (ended_on IS NULL OR started_on IS NULL OR ended_on >= started_on)
+);
+
+CREATE INDEX IF NOT EXISTS projects_tech_stack_idx ON projects USING GIN (tech_stack);
+
+CREATE TABLE IF NOT EXISTS project_upload_references (
+    path text NOT NULL,
+    project_id bigint NOT NULL REFERENCES projects ON DELETE CASCADE,
+    PRIMARY KEY (path, project_id)
+);
+
+CREATE INDEX IF NOT EXISTS project_upload_references_project_id_idx ON project_upload_references (project_id);
@@ -0,0 +1,7 @@
+ALTER TABLE author ADD COLUMN IF NOT EXISTS tags text[];
+
+UPDATE author a
+SET tags = ARRAY(SELECT s.name FROM skills s WHERE s.author_id = a.id ORDER BY s.level DESC, s.position, s.id LIMIT 5);
+
+DROP TABLE IF EXISTS skill_links;
+DROP TABLE IF EXISTS skills;
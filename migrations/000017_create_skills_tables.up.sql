@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS skills (
+    id bigserial PRIMARY KEY,
+    author_id bigint NOT NULL REFERENCES author ON DELETE CASCADE,
+    name text NOT NULL,
+    category text NOT NULL DEFAULT 'others',
+    level smallint NOT NULL DEFAULT 3,
+    years smallint NOT NULL DEFAULT 0,
+    position integer NOT NULL DEFAULT 0,
+    CONSTRAINT skills_category_check CHECK (category IN ('languages', 'frameworks', 'tools', 'others')),
+    CONSTRAINT skills_level_check CHECK (level BETWEEN 1 AND 5),
+    CONSTRAINT skills_years_check CHECK (years BETWEEN 0 AND 60),
+    CONSTRAINT skills_name_key UNIQUE (author_id, name)
+);
+
+-- the projects and the posts showing a skill
+CREATE TABLE IF NOT EXISTS skill_links (
+    skill_id bigint NOT NULL REFERENCES skills ON DELETE CASCADE,
+    project_id bigint REFERENCES projects ON DELETE CASCADE,
+    post_id bigint REFERENCES posts ON DELETE CASCADE,
+    CONSTRAINT skill_links_target_check CHECK ((project_id IS NULL) <> (post_id IS NULL)),
+    CONSTRAINT skill_links_project_key UNIQUE (skill_id, project_id),
+    CONSTRAINT skill_links_post_key UNIQUE (skill_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS skills_author_id_idx ON skills (author_id);
+CREATE INDEX IF NOT EXISTS skill_links_skill_id_idx ON skill_links (skill_id);
+
+-- moving the tags to the skills, left for the author to sort in the categories
+INSERT INTO skills (author_id, name, position)
+SELECT DISTINCT ON (a.id, trim(t.name)) a.id, trim(t.name), t.ord
+FROM author a,
+     unnest(a.tags) WITH ORDINALITY AS t(name, ord)
+WHERE trim(t.name) <> ''
+ORDER BY a.id, trim(t.name), t.ord;
+
+ALTER TABLE author DROP COLUMN IF EXISTS tags;
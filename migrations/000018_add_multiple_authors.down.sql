@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS posts_author_id_idx;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS author_id;
+
+-- only the default author is kept
+DELETE FROM author
+WHERE id <> (SELECT min(id) FROM author);
+
+ALTER TABLE author
+    DROP COLUMN IF EXISTS slug,
+    DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,29 @@
+-- every author profile belongs to a user, the first author staying the default one (the home page)
+ALTER TABLE author
+    ADD COLUMN IF NOT EXISTS user_id bigint UNIQUE REFERENCES users ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS slug text;
+
+UPDATE author
+SET slug = 'author-' || id;
+
+UPDATE author
+SET slug = coalesce(nullif(trim(BOTH '-' FROM lower(regexp_replace(name, '[^a-zA-Z0-9]+', '-', 'g'))), ''), slug),
+    user_id = (SELECT id FROM users ORDER BY id LIMIT 1)
+WHERE id = (SELECT min(id) FROM author);
+
+ALTER TABLE author
+    ALTER COLUMN slug SET NOT NULL,
+    ADD CONSTRAINT author_slug_key UNIQUE (slug),
+    ADD CONSTRAINT author_slug_check CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$');
+
+-- the existing posts are attributed to the default author
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS author_id bigint REFERENCES author;
+
+UPDATE posts
+SET author_id = (SELECT min(id) FROM author);
+
+ALTER TABLE posts
+    ALTER COLUMN author_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS posts_author_id_idx ON posts (author_id);
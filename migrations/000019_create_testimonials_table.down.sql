@@ -0,0 +1,7 @@
+DELETE FROM tokens WHERE testimonial_id IS NOT NULL;
+
+ALTER TABLE tokens DROP CONSTRAINT IF EXISTS tokens_owner_check;
+ALTER TABLE tokens DROP COLUMN IF EXISTS testimonial_id;
+ALTER TABLE tokens ALTER COLUMN user_id SET NOT NULL;
+
+DROP TABLE IF EXISTS testimonials;
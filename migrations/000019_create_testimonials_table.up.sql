@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS testimonials (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    author_id bigint NOT NULL REFERENCES author ON DELETE CASCADE,
+    name text NOT NULL,
+    email citext NOT NULL,
+    role text NOT NULL DEFAULT '',
+    company text NOT NULL DEFAULT '',
+    relationship text NOT NULL,
+    message text NOT NULL,
+    status text NOT NULL DEFAULT 'unverified',
+    verified_at timestamp(0) with time zone,
+    reviewed_at timestamp(0) with time zone,
+    CONSTRAINT testimonials_status_check CHECK (status IN ('unverified', 'pending', 'approved', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS testimonials_author_id_status_idx ON testimonials (author_id, status);
+
+-- the email of the submitters of the testimonials is verified with the tokens, which then belong to no user
+ALTER TABLE tokens ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS testimonial_id bigint REFERENCES testimonials ON DELETE CASCADE;
+ALTER TABLE tokens ADD CONSTRAINT tokens_owner_check CHECK ((user_id IS NULL) <> (testimonial_id IS NULL));
@@ -0,0 +1,11 @@
+DELETE FROM tokens WHERE booking_id IS NOT NULL;
+
+ALTER TABLE tokens DROP CONSTRAINT IF EXISTS tokens_owner_check;
+ALTER TABLE tokens DROP COLUMN IF EXISTS booking_id;
+ALTER TABLE tokens ADD CONSTRAINT tokens_owner_check CHECK ((user_id IS NULL) <> (testimonial_id IS NULL));
+
+DROP TABLE IF EXISTS bookings;
+
+DROP TABLE IF EXISTS blackout_dates;
+
+DROP TABLE IF EXISTS availabilities;
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS availabilities (
+    id bigserial PRIMARY KEY,
+    author_id bigint NOT NULL REFERENCES author ON DELETE CASCADE,
+    weekday smallint NOT NULL,
+    start_minute smallint NOT NULL,
+    end_minute smallint NOT NULL,
+    CONSTRAINT availabilities_weekday_check CHECK (weekday BETWEEN 0 AND 6),
+    CONSTRAINT availabilities_minutes_check CHECK (start_minute >= 0 AND end_minute <= 1440 AND start_minute < end_minute)
+);
+
+CREATE INDEX IF NOT EXISTS availabilities_author_id_idx ON availabilities (author_id);
+
+CREATE TABLE IF NOT EXISTS blackout_dates (
+    id bigserial PRIMARY KEY,
+    author_id bigint NOT NULL REFERENCES author ON DELETE CASCADE,
+    day date NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    CONSTRAINT blackout_dates_author_id_day_key UNIQUE (author_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS bookings (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    author_id bigint NOT NULL REFERENCES author ON DELETE CASCADE,
+    name text NOT NULL,
+    email citext NOT NULL,
+    message text NOT NULL DEFAULT '',
+    starts_at timestamp(0) with time zone NOT NULL,
+    ends_at timestamp(0) with time zone NOT NULL,
+    status text NOT NULL DEFAULT 'confirmed',
+    cancelled_at timestamp(0) with time zone,
+    CONSTRAINT bookings_status_check CHECK (status IN ('confirmed', 'cancelled')),
+    CONSTRAINT bookings_dates_check CHECK (ends_at > starts_at)
+);
+
+-- a slot can only be booked once, the cancelled bookings freeing it
+CREATE UNIQUE INDEX IF NOT EXISTS bookings_author_id_starts_at_key ON bookings (author_id, starts_at) WHERE status = 'confirmed';
+
+-- the cancellation links of the bookings are tokens belonging to no user
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS booking_id bigint REFERENCES bookings ON DELETE CASCADE;
+ALTER TABLE tokens DROP CONSTRAINT IF EXISTS tokens_owner_check;
+ALTER TABLE tokens ADD CONSTRAINT tokens_owner_check CHECK (num_nonnulls(user_id, testimonial_id, booking_id) = 1);
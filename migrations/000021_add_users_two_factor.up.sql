@@ -0,0 +1,12 @@
+-- the secret is kept while the enrollment is pending, the two-factor authentication being enabled once totp_enabled_at is set
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret bytea;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at timestamp(0) with time zone;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_counter bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    hash bytea NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    CONSTRAINT recovery_codes_user_id_hash_key UNIQUE (user_id, hash)
+);
@@ -0,0 +1,13 @@
+-- public_key is the COSE_Key of the passkey, sign_count its signature counter (0 for the synced passkeys)
+CREATE TABLE IF NOT EXISTS passkeys (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    credential_id bytea NOT NULL UNIQUE,
+    public_key bytea NOT NULL,
+    sign_count bigint NOT NULL DEFAULT 0,
+    name text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_used_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS passkeys_user_id_idx ON passkeys (user_id);
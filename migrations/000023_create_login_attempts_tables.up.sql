@@ -0,0 +1,25 @@
+-- failed attempts to sign in (password or code of the authenticator app), to reset a password and password reset requests,
+-- cleared once the account signs in or is unlocked
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    action text NOT NULL,
+    email citext NOT NULL DEFAULT '',
+    ip text NOT NULL,
+    cleared boolean NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS login_attempts_email_idx ON login_attempts (email, created_at);
+CREATE INDEX IF NOT EXISTS login_attempts_ip_idx ON login_attempts (ip, created_at);
+
+-- accounts (by email) and client IPs locked out after too many failed attempts
+CREATE TABLE IF NOT EXISTS lockouts (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    scope text NOT NULL,
+    value citext NOT NULL,
+    locked_until timestamp(0) with time zone NOT NULL,
+    attempts integer NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS lockouts_scope_value_idx ON lockouts (scope, value, locked_until);
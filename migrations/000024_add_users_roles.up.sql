@@ -0,0 +1,3 @@
+-- the users sign up by invitation with the role given by an admin, the existing users being the admins of the site
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'contributor';
+UPDATE users SET role = 'admin';
@@ -0,0 +1,13 @@
+-- the authenticated sessions of the users, token being the one of their session in the sessions table (ended by deleting it)
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    token text UNIQUE NOT NULL,
+    device text NOT NULL,
+    ip text NOT NULL,
+    user_agent text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS user_sessions_user_id_idx ON user_sessions (user_id);